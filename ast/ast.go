@@ -52,9 +52,19 @@ func (p *Program) String() string {
 }
 
 type LetStatement struct {
-	Token token.Token // token.LET 词法单元
-	Name  *Identifier
-	Value Expression
+	Token   token.Token // token.LET 词法单元
+	Name    *Identifier
+	Pattern Pattern  // 解构赋值时的模式, 例如 let [a, b] = xs; 此时 Name 为 nil
+	Type    TypeExpr // 类型注解, 例如 let x: int = 1; 中的 int, 可以为 nil
+	Value   Expression
+}
+
+// Target 返回 let 绑定的目标, 普通的 let 返回 Name, 解构赋值返回 Pattern
+func (ls *LetStatement) Target() Pattern {
+	if ls.Pattern != nil {
+		return ls.Pattern
+	}
+	return ls.Name
 }
 
 func (ls *LetStatement) statementNode() {
@@ -72,7 +82,10 @@ func (ls *LetStatement) String() string {
 	// 写入 Token 的内容,即let
 	out.WriteString(ls.TokenLiteral() + " ")
 	// 写入"="之前的信息
-	out.WriteString(ls.Name.String())
+	out.WriteString(ls.Target().String())
+	if ls.Type != nil {
+		out.WriteString(": " + ls.Type.String())
+	}
 	out.WriteString(" = ")
 	// 写入"="后的内容
 	if ls.Value != nil {
@@ -90,6 +103,7 @@ type Identifier struct {
 }
 
 func (i *Identifier) expressionNode() {}
+func (i *Identifier) patternNode()    {}
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
@@ -155,6 +169,16 @@ func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+// FloatLiteral 浮点数字面值
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 // PrefixExpression 前缀解析结构体
 type PrefixExpression struct {
 	Token token.Token // 前缀词法单元,如"!","-"
@@ -240,10 +264,52 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// ThrowStatement 抛出异常, 例如 throw "boom";
+type ThrowStatement struct {
+	Token token.Token // throw 词法单元
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) String() string {
+	return "throw " + ts.Value.String() + ";"
+}
+
+// TryExpression try { } catch (e) { } finally { }, catch 和 finally 至少出现一个
+type TryExpression struct {
+	Token   token.Token     // try 词法单元
+	Block   *BlockStatement // try 区块
+	Param   *Identifier     // catch 绑定的变量, 可以为 nil
+	Catch   *BlockStatement // 可以为 nil
+	Finally *BlockStatement // 可以为 nil
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+	if te.Catch != nil {
+		out.WriteString(" catch ")
+		if te.Param != nil {
+			out.WriteString("(" + te.Param.String() + ") ")
+		}
+		out.WriteString(te.Catch.String())
+	}
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+	return out.String()
+}
+
 // BlockStatement "{}"区块语句
 type BlockStatement struct {
 	Token      token.Token // "{"词法单元
 	Statements []Statement
+	Close      token.Token // "}"词法单元
 }
 
 func (bs *BlockStatement) statementNode()       {}
@@ -260,8 +326,10 @@ func (bs *BlockStatement) String() string {
 // FunctionLiteral 函数子面值解析
 type FunctionLiteral struct {
 	Token      token.Token
-	Parameters []*Identifier
+	Parameters []Pattern // 参数可以是标识符、解构模式、带默认值或类型注解的参数, 最后一个可以是 ...rest
+	ReturnType TypeExpr  // 返回值的类型注解, 可以为 nil
 	Body       *BlockStatement
+	Name       string // 通过 let 绑定时的名字, 用于错误信息, 匿名函数为空
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -277,16 +345,61 @@ func (fl *FunctionLiteral) String() string {
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
+	if fl.ReturnType != nil {
+		out.WriteString("-> " + fl.ReturnType.String() + " ")
+	}
 	out.WriteString(fl.Body.String())
 
 	return out.String()
 }
 
+// DefaultPattern 带默认值的参数, 例如 fn(a, b = 10) 中的 b = 10
+type DefaultPattern struct {
+	Token   token.Token // "="词法单元
+	Target  Pattern
+	Default Expression
+}
+
+func (dp *DefaultPattern) expressionNode()      {}
+func (dp *DefaultPattern) patternNode()         {}
+func (dp *DefaultPattern) TokenLiteral() string { return dp.Token.Literal }
+func (dp *DefaultPattern) String() string {
+	return dp.Target.String() + " = " + dp.Default.String()
+}
+
+// TypedPattern 带类型注解的参数, 例如 fn(a: int, ...rest: [string]) 中的 a: int 和 ...rest: [string]
+type TypedPattern struct {
+	Token  token.Token // ":"词法单元
+	Target Pattern
+	Type   TypeExpr
+}
+
+func (tp *TypedPattern) expressionNode()      {}
+func (tp *TypedPattern) patternNode()         {}
+func (tp *TypedPattern) TokenLiteral() string { return tp.Token.Literal }
+func (tp *TypedPattern) String() string {
+	return tp.Target.String() + ": " + tp.Type.String()
+}
+
+// NamedArgument 调用时的具名参数, 例如 f(b: 2, a: 1) 中的 b: 2
+type NamedArgument struct {
+	Token token.Token // 参数名的词法单元
+	Name  *Identifier
+	Value Expression
+}
+
+func (na *NamedArgument) expressionNode()      {}
+func (na *NamedArgument) TokenLiteral() string { return na.Token.Literal }
+func (na *NamedArgument) String() string {
+	return na.Name.String() + ": " + na.Value.String()
+}
+
 // CallExpression 表达式字面值解析
 type CallExpression struct {
 	Token     token.Token
 	Function  Expression
 	Arguments []Expression
+	Close     token.Token // ")"词法单元
 }
 
 func (ce *CallExpression) expressionNode()      {}
@@ -318,6 +431,7 @@ func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 type ArrayLiteral struct {
 	Token    token.Token
 	Elements []Expression
+	Close    token.Token // "]"词法单元
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
@@ -339,6 +453,7 @@ type IndexExpression struct {
 	Token token.Token
 	Left  Expression
 	Index Expression
+	Close token.Token // "]"词法单元
 }
 
 func (ie *IndexExpression) expressionNode()      {}
@@ -354,9 +469,56 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression 切片 a[start:end:step], 省略的部分为 nil
+type SliceExpression struct {
+	Token token.Token // "["词法单元
+	Left  Expression
+	Start Expression
+	End   Expression
+	Step  Expression
+	Close token.Token // "]"词法单元
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	if se.Step != nil {
+		out.WriteString(":")
+		out.WriteString(se.Step.String())
+	}
+	out.WriteString("])")
+	return out.String()
+}
+
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	Keys  []Expression // 键在源码中出现的顺序
+	Close token.Token  // "}"词法单元
+}
+
+// OrderedKeys 按源码中的顺序返回所有键, 没有记录顺序时按 map 的遍历顺序
+func (hl *HashLiteral) OrderedKeys() []Expression {
+	if len(hl.Keys) == len(hl.Pairs) {
+		return hl.Keys
+	}
+	keys := make([]Expression, 0, len(hl.Pairs))
+	for key := range hl.Pairs {
+		keys = append(keys, key)
+	}
+	return keys
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -364,11 +526,295 @@ func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.OrderedKeys() {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
 	out.WriteString("}")
 	return out.String()
 }
+
+// ImportStatement 导入模块, 例如 import "lib/math.mk" as m;
+type ImportStatement struct {
+	Token token.Token // token.IMPORT 词法单元
+	Path  *StringLiteral
+	Alias *Identifier
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) String() string {
+	return is.TokenLiteral() + " \"" + is.Path.Value + "\" as " + is.Alias.String() + ";"
+}
+
+// ExportStatement 导出模块顶层的 let 绑定, 例如 export let add = fn(a, b) { a + b };
+type ExportStatement struct {
+	Token     token.Token // token.EXPORT 词法单元
+	Statement *LetStatement
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
+// MemberExpression 成员访问, 例如 lib.name
+type MemberExpression struct {
+	Token  token.Token // "."词法单元
+	Object Expression
+	Member *Identifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(me.Object.String())
+	out.WriteString(".")
+	out.WriteString(me.Member.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+// RegexLiteral 正则字面量, 例如 /[a-z]+/i
+type RegexLiteral struct {
+	Token   token.Token // token.REGEX 词法单元, 字面量保留原始文本
+	Pattern string      // 两个"/"之间的模式
+	Flags   string      // 结尾的标志位
+}
+
+func (rl *RegexLiteral) expressionNode()      {}
+func (rl *RegexLiteral) TokenLiteral() string { return rl.Token.Literal }
+func (rl *RegexLiteral) String() string       { return "/" + rl.Pattern + "/" + rl.Flags }
+
+// Pattern 解构模式, 出现在 let 的左边和函数参数中
+// 目前有 *Identifier, *ArrayPattern, *HashPattern 以及只能出现在它们内部的 *RestPattern
+type Pattern interface {
+	Expression
+	patternNode()
+}
+
+// ArrayPattern 数组解构, 例如 [a, b, ...rest]
+type ArrayPattern struct {
+	Token    token.Token // "["词法单元
+	Elements []Pattern   // 最后一个元素可以是 *RestPattern
+	Close    token.Token // "]"词法单元
+}
+
+func (ap *ArrayPattern) expressionNode()      {}
+func (ap *ArrayPattern) patternNode()         {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashPatternPair 哈希解构中的一项, {name} 是 {name: name} 的简写
+type HashPatternPair struct {
+	Key   string
+	Value Pattern
+}
+
+// HashPattern 哈希解构, 例如 {name, age: years, ...others}
+type HashPattern struct {
+	Token token.Token // "{"词法单元
+	Pairs []*HashPatternPair
+	Rest  *RestPattern // 收集剩余的键值对, 可以为 nil
+	Close token.Token  // "}"词法单元
+}
+
+func (hp *HashPattern) expressionNode()      {}
+func (hp *HashPattern) patternNode()         {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) String() string {
+	pairs := []string{}
+	for _, pair := range hp.Pairs {
+		key := pair.Key
+		if !isIdentifierName(key) {
+			key = "\"" + key + "\""
+		}
+		if ident, ok := pair.Value.(*Identifier); ok && ident.Value == pair.Key {
+			pairs = append(pairs, key)
+			continue
+		}
+		pairs = append(pairs, key+": "+pair.Value.String())
+	}
+	if hp.Rest != nil {
+		pairs = append(pairs, hp.Rest.String())
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// RestPattern 剩余元素, 例如 [first, ...rest] 中的 ...rest
+type RestPattern struct {
+	Token token.Token // "..."词法单元
+	Name  *Identifier
+}
+
+func (rp *RestPattern) expressionNode()      {}
+func (rp *RestPattern) patternNode()         {}
+func (rp *RestPattern) TokenLiteral() string { return rp.Token.Literal }
+func (rp *RestPattern) String() string       { return "..." + rp.Name.String() }
+
+// LiteralPattern 字面量模式, 只能出现在 match 中, 值与字面量相等时匹配
+type LiteralPattern struct {
+	Token token.Token
+	Value Expression // 整数、浮点数、字符串或布尔字面量
+}
+
+func (lp *LiteralPattern) expressionNode()      {}
+func (lp *LiteralPattern) patternNode()         {}
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Token.Literal }
+func (lp *LiteralPattern) String() string {
+	if s, ok := lp.Value.(*StringLiteral); ok {
+		return "\"" + s.Value + "\""
+	}
+	return lp.Value.String()
+}
+
+// MatchArm match 中的一个分支: 模式 [if 守卫] => 结果
+type MatchArm struct {
+	Pattern Pattern
+	Guard   Expression // 可以为 nil
+	Body    Expression
+}
+
+func (ma *MatchArm) String() string {
+	var out bytes.Buffer
+	out.WriteString(ma.Pattern.String())
+	if ma.Guard != nil {
+		out.WriteString(" if ")
+		out.WriteString(ma.Guard.String())
+	}
+	out.WriteString(" => ")
+	out.WriteString(ma.Body.String())
+	return out.String()
+}
+
+// MatchExpression 模式匹配, 例如 match (x) { 0 => "zero", n if n > 0 => "positive", _ => "negative" }
+type MatchExpression struct {
+	Token   token.Token // match 词法单元
+	Subject Expression
+	Arms    []*MatchArm
+	Close   token.Token // "}"词法单元
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) String() string {
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+	return "match (" + me.Subject.String() + ") { " + strings.Join(arms, ", ") + " }"
+}
+
+// PatternNames 返回模式中绑定的所有标识符, 按出现的顺序
+func PatternNames(pattern Pattern) []*Identifier {
+	var names []*Identifier
+	switch pattern := pattern.(type) {
+	case *Identifier:
+		names = append(names, pattern)
+	case *RestPattern:
+		names = append(names, pattern.Name)
+	case *ArrayPattern:
+		for _, el := range pattern.Elements {
+			names = append(names, PatternNames(el)...)
+		}
+	case *DefaultPattern:
+		names = append(names, PatternNames(pattern.Target)...)
+	case *TypedPattern:
+		names = append(names, PatternNames(pattern.Target)...)
+	case *HashPattern:
+		for _, pair := range pattern.Pairs {
+			names = append(names, PatternNames(pair.Value)...)
+		}
+		if pattern.Rest != nil {
+			names = append(names, pattern.Rest.Name)
+		}
+	}
+	return names
+}
+
+// isIdentifierName 判断字符串能否直接作为标识符书写
+func isIdentifierName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, ch := range s {
+		if !(('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ch == '_') {
+			return false
+		}
+	}
+	return token.LookupIdent(s) == token.IDENT
+}
+
+// TypeExpr 类型注解, 出现在 let 的名字、函数参数之后以及参数列表的 -> 之后
+type TypeExpr interface {
+	Node
+	typeNode()
+}
+
+// NamedType 具名类型, 例如 int、float、string、bool、null、regex 和 any
+type NamedType struct {
+	Token token.Token // token.IDENT 词法单元
+	Name  string
+}
+
+func (nt *NamedType) typeNode()            {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) String() string       { return nt.Name }
+
+// ArrayType 元素类型相同的数组, 例如 [int]
+type ArrayType struct {
+	Token   token.Token // "["词法单元
+	Element TypeExpr
+	Close   token.Token // "]"词法单元
+}
+
+func (at *ArrayType) typeNode()            {}
+func (at *ArrayType) TokenLiteral() string { return at.Token.Literal }
+func (at *ArrayType) String() string       { return "[" + at.Element.String() + "]" }
+
+// HashType 键和值的类型各自相同的哈希, 例如 {string: int}
+type HashType struct {
+	Token token.Token // "{"词法单元
+	Key   TypeExpr
+	Value TypeExpr
+	Close token.Token // "}"词法单元
+}
+
+func (ht *HashType) typeNode()            {}
+func (ht *HashType) TokenLiteral() string { return ht.Token.Literal }
+func (ht *HashType) String() string {
+	return "{" + ht.Key.String() + ": " + ht.Value.String() + "}"
+}
+
+// FunctionType 函数类型, 例如 fn(int, int) -> bool, 省略 -> 时返回值的类型是 any
+type FunctionType struct {
+	Token      token.Token // fn 词法单元
+	Parameters []TypeExpr
+	Return     TypeExpr    // 可以为 nil
+	Close      token.Token // ")"词法单元
+}
+
+func (ft *FunctionType) typeNode()            {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) String() string {
+	params := []string{}
+	for _, p := range ft.Parameters {
+		params = append(params, p.String())
+	}
+	out := "fn(" + strings.Join(params, ", ") + ")"
+	if ft.Return != nil {
+		out += " -> " + ft.Return.String()
+	}
+	return out
+}
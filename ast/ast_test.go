@@ -26,3 +26,36 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestChildren(t *testing.T) {
+	ident := func(name string) *Identifier {
+		return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+	}
+	ifExp := &IfExpression{
+		Token:       token.Token{Type: token.IF, Literal: "if"},
+		Condition:   ident("x"),
+		Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: ident("y")}}},
+	}
+	call := &CallExpression{Function: ident("f"), Arguments: []Expression{ident("a"), ident("b")}}
+
+	tests := []struct {
+		node     Node
+		expected []string
+	}{
+		{ifExp, []string{"condition", "consequence"}},
+		{call, []string{"function", "arguments[0]", "arguments[1]"}},
+		{ident("z"), nil},
+	}
+
+	for _, tt := range tests {
+		children := Children(tt.node)
+		if len(children) != len(tt.expected) {
+			t.Fatalf("wrong number of children for %s. expected=%d, got=%d", tt.node.String(), len(tt.expected), len(children))
+		}
+		for i, name := range tt.expected {
+			if children[i].Name != name {
+				t.Errorf("wrong child name for %s. expected=%q, got=%q", tt.node.String(), name, children[i].Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package ast
+
+import "fmt"
+
+// Child 语法树中的一个子节点, Name 是子节点在父节点中的位置, 例如 "left"、"arguments[1]"、`pairs["key"]`
+type Child struct {
+	Name string
+	Node Node
+}
+
+// Children 按源码中的顺序返回节点的直接子节点, 值为 nil 的可选子节点不会出现
+func Children(node Node) []Child {
+	var children []Child
+	add := func(name string, child Node) {
+		if !isNil(child) {
+			children = append(children, Child{Name: name, Node: child})
+		}
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for i, s := range node.Statements {
+			add(fmt.Sprintf("statements[%d]", i), s)
+		}
+	case *BlockStatement:
+		for i, s := range node.Statements {
+			add(fmt.Sprintf("statements[%d]", i), s)
+		}
+	case *LetStatement:
+		if node.Pattern != nil {
+			add("pattern", node.Pattern)
+		} else {
+			add("name", node.Name)
+		}
+		add("type", node.Type)
+		add("value", node.Value)
+	case *ReturnStatement:
+		add("value", node.ReturnValue)
+	case *ExpressionStatement:
+		add("expression", node.Expression)
+	case *ThrowStatement:
+		add("value", node.Value)
+	case *ImportStatement:
+		add("path", node.Path)
+		add("alias", node.Alias)
+	case *ExportStatement:
+		add("statement", node.Statement)
+	case *PrefixExpression:
+		add("right", node.Right)
+	case *InfixExpression:
+		add("left", node.Left)
+		add("right", node.Right)
+	case *IfExpression:
+		add("condition", node.Condition)
+		add("consequence", node.Consequence)
+		add("alternative", node.Alternative)
+	case *TryExpression:
+		add("block", node.Block)
+		add("param", node.Param)
+		add("catch", node.Catch)
+		add("finally", node.Finally)
+	case *FunctionLiteral:
+		for i, p := range node.Parameters {
+			add(fmt.Sprintf("parameters[%d]", i), p)
+		}
+		add("returnType", node.ReturnType)
+		add("body", node.Body)
+	case *CallExpression:
+		add("function", node.Function)
+		for i, a := range node.Arguments {
+			add(fmt.Sprintf("arguments[%d]", i), a)
+		}
+	case *NamedArgument:
+		add("name", node.Name)
+		add("value", node.Value)
+	case *ArrayLiteral:
+		for i, e := range node.Elements {
+			add(fmt.Sprintf("elements[%d]", i), e)
+		}
+	case *HashLiteral:
+		for i, key := range node.OrderedKeys() {
+			add(fmt.Sprintf("keys[%d]", i), key)
+			add(fmt.Sprintf("values[%d]", i), node.Pairs[key])
+		}
+	case *IndexExpression:
+		add("left", node.Left)
+		add("index", node.Index)
+	case *SliceExpression:
+		add("left", node.Left)
+		add("start", node.Start)
+		add("end", node.End)
+		add("step", node.Step)
+	case *MemberExpression:
+		add("object", node.Object)
+		add("member", node.Member)
+	case *MatchExpression:
+		add("subject", node.Subject)
+		for i, arm := range node.Arms {
+			add(fmt.Sprintf("arms[%d].pattern", i), arm.Pattern)
+			add(fmt.Sprintf("arms[%d].guard", i), arm.Guard)
+			add(fmt.Sprintf("arms[%d].body", i), arm.Body)
+		}
+	case *ArrayPattern:
+		for i, e := range node.Elements {
+			add(fmt.Sprintf("elements[%d]", i), e)
+		}
+	case *HashPattern:
+		for _, pair := range node.Pairs {
+			add(fmt.Sprintf("pairs[%q]", pair.Key), pair.Value)
+		}
+		add("rest", node.Rest)
+	case *RestPattern:
+		add("name", node.Name)
+	case *DefaultPattern:
+		add("target", node.Target)
+		add("default", node.Default)
+	case *TypedPattern:
+		add("target", node.Target)
+		add("type", node.Type)
+	case *LiteralPattern:
+		add("value", node.Value)
+	case *ArrayType:
+		add("element", node.Element)
+	case *HashType:
+		add("key", node.Key)
+		add("value", node.Value)
+	case *FunctionType:
+		for i, p := range node.Parameters {
+			add(fmt.Sprintf("parameters[%d]", i), p)
+		}
+		add("return", node.Return)
+	}
+	return children
+}
+
+// isNil 判断接口中是否是 nil 指针, 例如没有 else 分支时的 Alternative
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+	switch n := node.(type) {
+	case *BlockStatement:
+		return n == nil
+	case *Identifier:
+		return n == nil
+	case *StringLiteral:
+		return n == nil
+	case *LetStatement:
+		return n == nil
+	case *RestPattern:
+		return n == nil
+	}
+	return false
+}
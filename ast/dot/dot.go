@@ -0,0 +1,132 @@
+// Package dot 把语法树渲染成 Graphviz 的 DOT 格式
+//
+//	monkey ast --dot file.mk | dot -Tpng -o ast.png
+package dot
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/token"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// HIGHLIGHT_COLOR 高亮节点的填充颜色
+const HIGHLIGHT_COLOR = "#ffe680"
+
+// Options 渲染选项
+type Options struct {
+	// Highlight 不为 nil 时, 区间完全落在其中的节点会被高亮
+	Highlight *token.Span
+	// ShowSpans 在节点的标签中显示它在源代码中的区间
+	ShowSpans bool
+}
+
+// Render 把节点及其所有子节点渲染成 DOT 图
+func Render(node ast.Node, opts Options) string {
+	var out bytes.Buffer
+	Write(&out, node, opts)
+	return out.String()
+}
+
+// Write 把 DOT 图写入 w
+func Write(w io.Writer, node ast.Node, opts Options) {
+	r := &renderer{w: w, opts: opts}
+	fmt.Fprintln(w, "digraph AST {")
+	fmt.Fprintln(w, `  node [shape=box, fontname="Helvetica"];`)
+	fmt.Fprintln(w, `  edge [fontname="Helvetica", fontsize=10];`)
+	r.node(node)
+	fmt.Fprintln(w, "}")
+}
+
+type renderer struct {
+	w    io.Writer
+	opts Options
+	next int // 下一个节点的编号
+}
+
+// node 输出一个语法树节点和它的子树, 返回节点的编号
+func (r *renderer) node(node ast.Node) string {
+	id := r.newID()
+	label := ast.Label(node)
+	span := ast.SpanOf(node)
+	if r.opts.ShowSpans && span.Start.IsValid() {
+		label += "\n" + span.String()
+	}
+	attrs := ""
+	if r.highlighted(span) {
+		attrs = fmt.Sprintf(`, style=filled, fillcolor="%s"`, HIGHLIGHT_COLOR)
+	}
+	fmt.Fprintf(r.w, "  %s [label=%s%s];\n", id, quote(label), attrs)
+
+	groups := map[string]string{} // 分组名 -> 分组节点的编号
+	for _, child := range children(node) {
+		parent, edge := id, child.Name
+		if group, part, ok := splitGroup(child.Name); ok {
+			if _, exists := groups[group]; !exists {
+				groups[group] = r.group(id, group)
+			}
+			parent, edge = groups[group], part
+		}
+		childID := r.node(child.Node)
+		fmt.Fprintf(r.w, "  %s -> %s [label=%s];\n", parent, childID, quote(edge))
+	}
+	return id
+}
+
+// group 输出一个把多个子节点归在一起的辅助节点, 例如 match 的一个分支、hash 的一个键值对
+func (r *renderer) group(parent, name string) string {
+	id := r.newID()
+	fmt.Fprintf(r.w, "  %s [label=%s, shape=ellipse];\n", id, quote(name))
+	fmt.Fprintf(r.w, "  %s -> %s;\n", parent, id)
+	return id
+}
+
+func (r *renderer) newID() string {
+	id := fmt.Sprintf("n%d", r.next)
+	r.next++
+	return id
+}
+
+func (r *renderer) highlighted(span token.Span) bool {
+	return r.opts.Highlight != nil && span.Start.IsValid() && r.opts.Highlight.Covers(span)
+}
+
+// children 返回节点的子节点, hash 字面量的 keys[i]、values[i] 改为 pairs[i].key、pairs[i].value, 渲染时每个键值对归为一组
+func children(node ast.Node) []ast.Child {
+	children := ast.Children(node)
+	if _, ok := node.(*ast.HashLiteral); !ok {
+		return children
+	}
+	for i := range children {
+		name := children[i].Name
+		switch {
+		case strings.HasPrefix(name, "keys["):
+			children[i].Name = "pairs[" + strings.TrimPrefix(name, "keys[") + ".key"
+		case strings.HasPrefix(name, "values["):
+			children[i].Name = "pairs[" + strings.TrimPrefix(name, "values[") + ".value"
+		}
+	}
+	return children
+}
+
+var groupPattern = regexp.MustCompile(`^(\w+\[\d+\])\.(\w+)$`)
+
+// splitGroup 把 "arms[0].pattern" 拆成分组 "arms[0]" 和 "pattern"
+func splitGroup(name string) (string, string, bool) {
+	m := groupPattern.FindStringSubmatch(name)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// quote 转义 DOT 字符串, 换行符保留为 DOT 中的 \n
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
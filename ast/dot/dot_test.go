@@ -0,0 +1,84 @@
+package dot
+
+import (
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/parser"
+	"github.com/fanyeke/monkey/token"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func TestRender(t *testing.T) {
+	program := parse(t, `if (x) { f("a\"b") } else { {1: y} }`)
+
+	expected := `digraph AST {
+  node [shape=box, fontname="Helvetica"];
+  edge [fontname="Helvetica", fontsize=10];
+  n0 [label="Program"];
+  n1 [label="ExpressionStatement"];
+  n2 [label="IfExpression"];
+  n3 [label="Identifier x"];
+  n2 -> n3 [label="condition"];
+  n4 [label="BlockStatement"];
+  n5 [label="ExpressionStatement"];
+  n6 [label="CallExpression"];
+  n7 [label="Identifier f"];
+  n6 -> n7 [label="function"];
+  n8 [label="StringLiteral \"a\\\"b\""];
+  n6 -> n8 [label="arguments[0]"];
+  n5 -> n6 [label="expression"];
+  n4 -> n5 [label="statements[0]"];
+  n2 -> n4 [label="consequence"];
+  n9 [label="BlockStatement"];
+  n10 [label="ExpressionStatement"];
+  n11 [label="HashLiteral"];
+  n12 [label="pairs[0]", shape=ellipse];
+  n11 -> n12;
+  n13 [label="IntegerLiteral 1"];
+  n12 -> n13 [label="key"];
+  n14 [label="Identifier y"];
+  n12 -> n14 [label="value"];
+  n10 -> n11 [label="expression"];
+  n9 -> n10 [label="statements[0]"];
+  n2 -> n9 [label="alternative"];
+  n1 -> n2 [label="expression"];
+  n0 -> n1 [label="statements[0]"];
+}
+`
+	if got := Render(program, Options{}); got != expected {
+		t.Errorf("wrong DOT output.\nexpected=%s\ngot=%s", expected, got)
+	}
+}
+
+func TestRenderOptions(t *testing.T) {
+	program := parse(t, "let a = 1 + 2;\nmatch (a) { 3 => b }")
+
+	highlight := token.Span{Start: token.Position{Line: 1, Column: 9}, End: token.Position{Line: 1, Column: 14}}
+	got := Render(program, Options{Highlight: &highlight, ShowSpans: true})
+
+	for _, line := range []string{
+		`n3 [label="InfixExpression +\n1:9-1:14", style=filled, fillcolor="#ffe680"];`,
+		`n4 [label="IntegerLiteral 1\n1:9-1:10", style=filled, fillcolor="#ffe680"];`,
+		`n1 [label="LetStatement\n1:1-1:14"];`,
+		`n9 [label="arms[0]", shape=ellipse];`,
+		`n9 -> n10 [label="pattern"];`,
+		`n9 -> n12 [label="body"];`,
+	} {
+		if !strings.Contains(got, "  "+line+"\n") {
+			t.Errorf("DOT output does not contain %q.\ngot=%s", line, got)
+		}
+	}
+	if strings.Count(got, "fillcolor") != 3 {
+		t.Errorf("expected 3 highlighted nodes, got %d", strings.Count(got, "fillcolor"))
+	}
+}
@@ -0,0 +1,512 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/fanyeke/monkey/token"
+	"strconv"
+	"strings"
+)
+
+// JSONNode 语法树节点的 JSON 表示, 所有节点类型共用同一种结构
+//
+//	{"kind": "InfixExpression", "span": {...}, "operator": "+", "children": [{"field": "left", ...}, ...]}
+type JSONNode struct {
+	Field    string      `json:"field,omitempty"`    // 在父节点中的位置, 与 Children 返回的名字相同
+	Kind     string      `json:"kind"`               // 节点类型, 例如 "LetStatement"
+	Span     token.Span  `json:"span"`               // 源代码中的区间
+	Operator string      `json:"operator,omitempty"` // 前缀和中缀表达式的运算符
+	Literal  string      `json:"literal,omitempty"`  // 字面量和标识符的值
+	Name     string      `json:"name,omitempty"`     // 通过 let 绑定的函数的名字
+	Children []*JSONNode `json:"children,omitempty"`
+}
+
+// ToJSON 把语法树转换成 JSONNode
+func ToJSON(node Node) *JSONNode {
+	j := &JSONNode{Kind: Kind(node), Span: SpanOf(node), Operator: operator(node)}
+	j.Literal, _ = literal(node)
+	if fn, ok := node.(*FunctionLiteral); ok {
+		j.Name = fn.Name
+	}
+	for _, child := range Children(node) {
+		c := ToJSON(child.Node)
+		c.Field = child.Name
+		j.Children = append(j.Children, c)
+	}
+	return j
+}
+
+func marshalNode(node Node) ([]byte, error) {
+	return json.Marshal(ToJSON(node))
+}
+
+func (p *Program) MarshalJSON() ([]byte, error)              { return marshalNode(p) }
+func (ls *LetStatement) MarshalJSON() ([]byte, error)        { return marshalNode(ls) }
+func (i *Identifier) MarshalJSON() ([]byte, error)           { return marshalNode(i) }
+func (rs *ReturnStatement) MarshalJSON() ([]byte, error)     { return marshalNode(rs) }
+func (es *ExpressionStatement) MarshalJSON() ([]byte, error) { return marshalNode(es) }
+func (il *IntegerLiteral) MarshalJSON() ([]byte, error)      { return marshalNode(il) }
+func (fl *FloatLiteral) MarshalJSON() ([]byte, error)        { return marshalNode(fl) }
+func (pe *PrefixExpression) MarshalJSON() ([]byte, error)    { return marshalNode(pe) }
+func (ie *InfixExpression) MarshalJSON() ([]byte, error)     { return marshalNode(ie) }
+func (b *Boolean) MarshalJSON() ([]byte, error)              { return marshalNode(b) }
+func (ie *IfExpression) MarshalJSON() ([]byte, error)        { return marshalNode(ie) }
+func (ts *ThrowStatement) MarshalJSON() ([]byte, error)      { return marshalNode(ts) }
+func (te *TryExpression) MarshalJSON() ([]byte, error)       { return marshalNode(te) }
+func (bs *BlockStatement) MarshalJSON() ([]byte, error)      { return marshalNode(bs) }
+func (fl *FunctionLiteral) MarshalJSON() ([]byte, error)     { return marshalNode(fl) }
+func (dp *DefaultPattern) MarshalJSON() ([]byte, error)      { return marshalNode(dp) }
+func (na *NamedArgument) MarshalJSON() ([]byte, error)       { return marshalNode(na) }
+func (ce *CallExpression) MarshalJSON() ([]byte, error)      { return marshalNode(ce) }
+func (sl *StringLiteral) MarshalJSON() ([]byte, error)       { return marshalNode(sl) }
+func (al *ArrayLiteral) MarshalJSON() ([]byte, error)        { return marshalNode(al) }
+func (ie *IndexExpression) MarshalJSON() ([]byte, error)     { return marshalNode(ie) }
+func (se *SliceExpression) MarshalJSON() ([]byte, error)     { return marshalNode(se) }
+func (hl *HashLiteral) MarshalJSON() ([]byte, error)         { return marshalNode(hl) }
+func (is *ImportStatement) MarshalJSON() ([]byte, error)     { return marshalNode(is) }
+func (es *ExportStatement) MarshalJSON() ([]byte, error)     { return marshalNode(es) }
+func (me *MemberExpression) MarshalJSON() ([]byte, error)    { return marshalNode(me) }
+func (rl *RegexLiteral) MarshalJSON() ([]byte, error)        { return marshalNode(rl) }
+func (ap *ArrayPattern) MarshalJSON() ([]byte, error)        { return marshalNode(ap) }
+func (hp *HashPattern) MarshalJSON() ([]byte, error)         { return marshalNode(hp) }
+func (rp *RestPattern) MarshalJSON() ([]byte, error)         { return marshalNode(rp) }
+func (lp *LiteralPattern) MarshalJSON() ([]byte, error)      { return marshalNode(lp) }
+func (me *MatchExpression) MarshalJSON() ([]byte, error)     { return marshalNode(me) }
+func (tp *TypedPattern) MarshalJSON() ([]byte, error)        { return marshalNode(tp) }
+func (nt *NamedType) MarshalJSON() ([]byte, error)           { return marshalNode(nt) }
+func (at *ArrayType) MarshalJSON() ([]byte, error)           { return marshalNode(at) }
+func (ht *HashType) MarshalJSON() ([]byte, error)            { return marshalNode(ht) }
+func (ft *FunctionType) MarshalJSON() ([]byte, error)        { return marshalNode(ft) }
+
+// FromJSON 从 ToJSON 产生的 JSON 中还原语法树
+// 外部工具可以用它生成 Monkey 程序, 还原出的节点的 String() 与原来的相同
+func FromJSON(data []byte) (Node, error) {
+	var j JSONNode
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	return j.Node()
+}
+
+// Node 把 JSONNode 还原成语法树节点
+func (j *JSONNode) Node() (Node, error) {
+	b := &builder{j: j}
+	node := b.build()
+	if b.err != nil {
+		return nil, b.err
+	}
+	return node, nil
+}
+
+// builder 还原一个节点, 遇到的第一个错误保存在 err 中, 之后的操作都不再执行
+type builder struct {
+	j   *JSONNode
+	err error
+}
+
+func (b *builder) fail(format string, a ...interface{}) {
+	if b.err == nil {
+		b.err = fmt.Errorf("%s: %s", b.j.Kind, fmt.Sprintf(format, a...))
+	}
+}
+
+// tok 构造节点自身的词法单元, 位置取节点区间的开头, 没有子节点的节点取整个区间
+func (b *builder) tok(t token.TokenType, literal string) token.Token {
+	end := b.j.Span.Start
+	if len(b.j.Children) == 0 {
+		end = b.j.Span.End
+	}
+	return token.Token{Type: t, Literal: literal, Pos: b.j.Span.Start, End: end}
+}
+
+// close 构造结尾括号的词法单元, 位置取节点区间的末尾
+func (b *builder) close(t token.TokenType) token.Token {
+	return token.Token{Type: t, Literal: string(t), Pos: b.j.Span.End, End: b.j.Span.End}
+}
+
+// child 还原指定位置的子节点, 不存在时返回 nil
+func (b *builder) child(field string) Node {
+	for _, c := range b.j.Children {
+		if c.Field == field {
+			return b.convert(c)
+		}
+	}
+	return nil
+}
+
+// list 按顺序还原 field[0], field[1], ... 子节点
+func (b *builder) list(field string) []Node {
+	var nodes []Node
+	for _, c := range b.j.Children {
+		if strings.HasPrefix(c.Field, field+"[") {
+			nodes = append(nodes, b.convert(c))
+		}
+	}
+	return nodes
+}
+
+func (b *builder) convert(c *JSONNode) Node {
+	if b.err != nil {
+		return nil
+	}
+	node, err := c.Node()
+	if err != nil {
+		b.err = err
+		return nil
+	}
+	return node
+}
+
+func (b *builder) expression(field string, required bool) Expression {
+	node := b.child(field)
+	if node == nil {
+		if required {
+			b.fail("missing child %q", field)
+		}
+		return nil
+	}
+	exp, ok := node.(Expression)
+	if !ok {
+		b.fail("child %q must be an expression, got %s", field, Kind(node))
+	}
+	return exp
+}
+
+func (b *builder) pattern(node Node, field string) Pattern {
+	if node == nil {
+		b.fail("missing child %q", field)
+		return nil
+	}
+	pattern, ok := node.(Pattern)
+	if !ok {
+		b.fail("child %q must be a pattern, got %s", field, Kind(node))
+	}
+	return pattern
+}
+
+func (b *builder) identifier(field string, required bool) *Identifier {
+	node := b.child(field)
+	if node == nil {
+		if required {
+			b.fail("missing child %q", field)
+		}
+		return nil
+	}
+	ident, ok := node.(*Identifier)
+	if !ok {
+		b.fail("child %q must be an Identifier, got %s", field, Kind(node))
+	}
+	return ident
+}
+
+func (b *builder) block(field string, required bool) *BlockStatement {
+	node := b.child(field)
+	if node == nil {
+		if required {
+			b.fail("missing child %q", field)
+		}
+		return nil
+	}
+	block, ok := node.(*BlockStatement)
+	if !ok {
+		b.fail("child %q must be a BlockStatement, got %s", field, Kind(node))
+	}
+	return block
+}
+
+func (b *builder) statements() []Statement {
+	var statements []Statement
+	for _, node := range b.list("statements") {
+		stmt, ok := node.(Statement)
+		if !ok && b.err == nil {
+			b.fail("statements must be statements, got %s", Kind(node))
+		}
+		statements = append(statements, stmt)
+	}
+	return statements
+}
+
+func (b *builder) expressions(field string) []Expression {
+	expressions := []Expression{}
+	for _, node := range b.list(field) {
+		exp, ok := node.(Expression)
+		if !ok && b.err == nil {
+			b.fail("%s must be expressions, got %s", field, Kind(node))
+		}
+		expressions = append(expressions, exp)
+	}
+	return expressions
+}
+
+func (b *builder) patterns(field string) []Pattern {
+	var patterns []Pattern
+	for _, node := range b.list(field) {
+		patterns = append(patterns, b.pattern(node, field))
+	}
+	return patterns
+}
+
+func (b *builder) typeExpr(field string, required bool) TypeExpr {
+	node := b.child(field)
+	if node == nil {
+		if required {
+			b.fail("missing child %q", field)
+		}
+		return nil
+	}
+	t, ok := node.(TypeExpr)
+	if !ok {
+		b.fail("child %q must be a type, got %s", field, Kind(node))
+	}
+	return t
+}
+
+func (b *builder) types(field string) []TypeExpr {
+	types := []TypeExpr{}
+	for _, node := range b.list(field) {
+		t, ok := node.(TypeExpr)
+		if !ok && b.err == nil {
+			b.fail("%s must be types, got %s", field, Kind(node))
+		}
+		types = append(types, t)
+	}
+	return types
+}
+
+func (b *builder) build() Node {
+	j := b.j
+	switch j.Kind {
+	case "Program":
+		return &Program{Statements: b.statements()}
+	case "LetStatement":
+		stmt := &LetStatement{Token: b.tok(token.LET, "let"), Type: b.typeExpr("type", false), Value: b.expression("value", true)}
+		if node := b.child("pattern"); node != nil {
+			stmt.Pattern = b.pattern(node, "pattern")
+		} else {
+			stmt.Name = b.identifier("name", true)
+		}
+		return stmt
+	case "ReturnStatement":
+		return &ReturnStatement{Token: b.tok(token.RETURN, "return"), ReturnValue: b.expression("value", false)}
+	case "ExpressionStatement":
+		exp := b.expression("expression", true)
+		stmt := &ExpressionStatement{Expression: exp}
+		if exp != nil {
+			stmt.Token = b.tok(token.ILLEGAL, exp.TokenLiteral())
+		}
+		return stmt
+	case "ThrowStatement":
+		return &ThrowStatement{Token: b.tok(token.THROW, "throw"), Value: b.expression("value", true)}
+	case "ImportStatement":
+		stmt := &ImportStatement{Token: b.tok(token.IMPORT, "import"), Alias: b.identifier("alias", true)}
+		path, ok := b.expression("path", true).(*StringLiteral)
+		if !ok {
+			b.fail("child %q must be a StringLiteral", "path")
+		}
+		stmt.Path = path
+		return stmt
+	case "ExportStatement":
+		let, ok := b.child("statement").(*LetStatement)
+		if !ok {
+			b.fail("child %q must be a LetStatement", "statement")
+		}
+		return &ExportStatement{Token: b.tok(token.EXPORT, "export"), Statement: let}
+	case "BlockStatement":
+		return &BlockStatement{Token: b.tok(token.LBRACE, "{"), Statements: b.statements(), Close: b.close(token.RBRACE)}
+	case "Identifier":
+		return &Identifier{Token: b.tok(token.IDENT, j.Literal), Value: j.Literal}
+	case "IntegerLiteral":
+		value, err := strconv.ParseInt(j.Literal, 10, 64)
+		if err != nil {
+			b.fail("invalid integer %q", j.Literal)
+		}
+		return &IntegerLiteral{Token: b.tok(token.INT, j.Literal), Value: value}
+	case "FloatLiteral":
+		value, err := strconv.ParseFloat(j.Literal, 64)
+		if err != nil {
+			b.fail("invalid float %q", j.Literal)
+		}
+		return &FloatLiteral{Token: b.tok(token.FLOAT, j.Literal), Value: value}
+	case "StringLiteral":
+		return &StringLiteral{Token: b.tok(token.STRING, j.Literal), Value: j.Literal}
+	case "Boolean":
+		if j.Literal != "true" && j.Literal != "false" {
+			b.fail("invalid boolean %q", j.Literal)
+		}
+		return &Boolean{Token: b.tok(token.LookupIdent(j.Literal), j.Literal), Value: j.Literal == "true"}
+	case "RegexLiteral":
+		end := strings.LastIndex(j.Literal, "/")
+		if !strings.HasPrefix(j.Literal, "/") || end <= 0 {
+			b.fail("invalid regex literal %q", j.Literal)
+			return nil
+		}
+		return &RegexLiteral{Token: b.tok(token.REGEX, j.Literal), Pattern: j.Literal[1:end], Flags: j.Literal[end+1:]}
+	case "PrefixExpression":
+		return &PrefixExpression{Token: b.tok(token.TokenType(j.Operator), j.Operator), Operator: j.Operator, Right: b.expression("right", true)}
+	case "InfixExpression":
+		return &InfixExpression{
+			Token:    b.tok(token.TokenType(j.Operator), j.Operator),
+			Left:     b.expression("left", true),
+			Operator: j.Operator,
+			Right:    b.expression("right", true),
+		}
+	case "IfExpression":
+		return &IfExpression{
+			Token:       b.tok(token.IF, "if"),
+			Condition:   b.expression("condition", true),
+			Consequence: b.block("consequence", true),
+			Alternative: b.block("alternative", false),
+		}
+	case "TryExpression":
+		return &TryExpression{
+			Token:   b.tok(token.TRY, "try"),
+			Block:   b.block("block", true),
+			Param:   b.identifier("param", false),
+			Catch:   b.block("catch", false),
+			Finally: b.block("finally", false),
+		}
+	case "FunctionLiteral":
+		return &FunctionLiteral{
+			Token:      b.tok(token.FUNCTION, "fn"),
+			Parameters: b.patterns("parameters"),
+			ReturnType: b.typeExpr("returnType", false),
+			Body:       b.block("body", true),
+			Name:       j.Name,
+		}
+	case "CallExpression":
+		return &CallExpression{
+			Token:     b.tok(token.LPAREN, "("),
+			Function:  b.expression("function", true),
+			Arguments: b.expressions("arguments"),
+			Close:     b.close(token.RPAREN),
+		}
+	case "NamedArgument":
+		name := b.identifier("name", true)
+		arg := &NamedArgument{Name: name, Value: b.expression("value", true)}
+		if name != nil {
+			arg.Token = name.Token
+		}
+		return arg
+	case "ArrayLiteral":
+		return &ArrayLiteral{Token: b.tok(token.LBRACKET, "["), Elements: b.expressions("elements"), Close: b.close(token.RBRACKET)}
+	case "HashLiteral":
+		hash := &HashLiteral{Token: b.tok(token.LBRACE, "{"), Pairs: make(map[Expression]Expression), Close: b.close(token.RBRACE)}
+		keys, values := b.expressions("keys"), b.expressions("values")
+		if len(keys) != len(values) {
+			b.fail("got %d keys and %d values", len(keys), len(values))
+			return nil
+		}
+		for i, key := range keys {
+			hash.Pairs[key] = values[i]
+		}
+		hash.Keys = keys
+		return hash
+	case "IndexExpression":
+		return &IndexExpression{
+			Token: b.tok(token.LBRACKET, "["),
+			Left:  b.expression("left", true),
+			Index: b.expression("index", true),
+			Close: b.close(token.RBRACKET),
+		}
+	case "SliceExpression":
+		return &SliceExpression{
+			Token: b.tok(token.LBRACKET, "["),
+			Left:  b.expression("left", true),
+			Start: b.expression("start", false),
+			End:   b.expression("end", false),
+			Step:  b.expression("step", false),
+			Close: b.close(token.RBRACKET),
+		}
+	case "MemberExpression":
+		return &MemberExpression{Token: b.tok(token.DOT, "."), Object: b.expression("object", true), Member: b.identifier("member", true)}
+	case "MatchExpression":
+		return b.buildMatch()
+	case "ArrayPattern":
+		return &ArrayPattern{Token: b.tok(token.LBRACKET, "["), Elements: b.patterns("elements"), Close: b.close(token.RBRACKET)}
+	case "HashPattern":
+		pattern := &HashPattern{Token: b.tok(token.LBRACE, "{"), Close: b.close(token.RBRACE)}
+		for _, c := range j.Children {
+			if c.Field == "rest" {
+				rest, ok := b.convert(c).(*RestPattern)
+				if !ok {
+					b.fail("child %q must be a RestPattern", "rest")
+				}
+				pattern.Rest = rest
+				continue
+			}
+			key, err := strconv.Unquote(strings.TrimSuffix(strings.TrimPrefix(c.Field, "pairs["), "]"))
+			if err != nil {
+				b.fail("invalid hash pattern field %q", c.Field)
+				return nil
+			}
+			pattern.Pairs = append(pattern.Pairs, &HashPatternPair{Key: key, Value: b.pattern(b.convert(c), c.Field)})
+		}
+		return pattern
+	case "RestPattern":
+		return &RestPattern{Token: b.tok(token.ELLIPSIS, "..."), Name: b.identifier("name", true)}
+	case "DefaultPattern":
+		return &DefaultPattern{Token: b.tok(token.ASSIGN, "="), Target: b.pattern(b.child("target"), "target"), Default: b.expression("default", true)}
+	case "TypedPattern":
+		return &TypedPattern{Token: b.tok(token.COLON, ":"), Target: b.pattern(b.child("target"), "target"), Type: b.typeExpr("type", true)}
+	case "NamedType":
+		return &NamedType{Token: b.tok(token.IDENT, j.Literal), Name: j.Literal}
+	case "ArrayType":
+		return &ArrayType{Token: b.tok(token.LBRACKET, "["), Element: b.typeExpr("element", true), Close: b.close(token.RBRACKET)}
+	case "HashType":
+		return &HashType{Token: b.tok(token.LBRACE, "{"), Key: b.typeExpr("key", true), Value: b.typeExpr("value", true), Close: b.close(token.RBRACE)}
+	case "FunctionType":
+		return &FunctionType{
+			Token:      b.tok(token.FUNCTION, "fn"),
+			Parameters: b.types("parameters"),
+			Return:     b.typeExpr("return", false),
+			Close:      b.close(token.RPAREN),
+		}
+	case "LiteralPattern":
+		value := b.expression("value", true)
+		pattern := &LiteralPattern{Value: value}
+		if value != nil {
+			pattern.Token = b.tok(token.ILLEGAL, value.TokenLiteral())
+		}
+		return pattern
+	default:
+		b.fail("unknown node kind")
+		return nil
+	}
+}
+
+// buildMatch 还原 match 表达式, 分支的子节点命名为 arms[i].pattern、arms[i].guard、arms[i].body
+func (b *builder) buildMatch() Node {
+	exp := &MatchExpression{Token: b.tok(token.IDENT, "match"), Subject: b.expression("subject", true), Close: b.close(token.RBRACE)}
+	for _, c := range b.j.Children {
+		var index int
+		var part string
+		if _, err := fmt.Sscanf(c.Field, "arms[%d].%s", &index, &part); err != nil {
+			continue
+		}
+		if index != len(exp.Arms) && index != len(exp.Arms)-1 {
+			b.fail("match arms out of order at %q", c.Field)
+			return nil
+		}
+		if index == len(exp.Arms) {
+			exp.Arms = append(exp.Arms, &MatchArm{})
+		}
+		arm := exp.Arms[index]
+		node := b.convert(c)
+		switch part {
+		case "pattern":
+			arm.Pattern = b.pattern(node, c.Field)
+		case "guard":
+			arm.Guard, _ = node.(Expression)
+		case "body":
+			arm.Body, _ = node.(Expression)
+		default:
+			b.fail("unknown match arm field %q", c.Field)
+		}
+	}
+	for i, arm := range exp.Arms {
+		if arm.Pattern == nil || arm.Body == nil {
+			b.fail("match arm %d needs a pattern and a body", i)
+		}
+	}
+	return exp
+}
@@ -0,0 +1,82 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/parser"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	inputs := []string{
+		`let add = fn(a, b = 2, ...rest) { return a + b; }; add(1, b: 3);`,
+		`let [x, {y, "z-z": w, ...o}] = [1, {"y": 2}]; if (x > 1) { x } else { -y }`,
+		`import "m.mk" as m; export let q = m.f(1)[0][1:2:3];`,
+		`match (v) { 0 => "a", [x, ...r] if x > 1 => r, {type: "c", r} => r * 2.5, -1 => true, _ => /ab+/i }`,
+		`try { throw {"message": "x"}; } catch (e) { e.message } finally { puts(1) }`,
+		`{"a": 1, true: [1, 2]}["a"]`,
+		`let x: {string: [int]} = {}; let f = fn([a, b]: [int], ...r: [any]) -> fn(int, float) -> bool { a }`,
+	}
+
+	for _, input := range inputs {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", input, p.Errors())
+		}
+		data, err := json.Marshal(program)
+		if err != nil {
+			t.Fatalf("json.Marshal(%q) returned error: %s", input, err)
+		}
+
+		node, err := ast.FromJSON(data)
+		if err != nil {
+			t.Fatalf("FromJSON for %q returned error: %s", input, err)
+		}
+		if node.String() != program.String() {
+			t.Errorf("wrong String() after round trip.\nexpected=%q\ngot=%q", program.String(), node.String())
+		}
+		// 还原出的节点保留了区间, 再次导出的 JSON 完全相同
+		again, _ := json.Marshal(node)
+		if string(again) != string(data) {
+			t.Errorf("JSON changed after round trip for %q.\nexpected=%s\ngot=%s", input, data, again)
+		}
+	}
+}
+
+func TestJSONSpans(t *testing.T) {
+	program := parser.New(lexer.New("let x = foo(1,\n  [2]);")).ParseProgram()
+	j := ast.ToJSON(program)
+
+	call := j.Children[0].Children[1]
+	if call.Kind != "CallExpression" || call.Field != "value" {
+		t.Fatalf("unexpected node %s %s", call.Field, call.Kind)
+	}
+	if call.Span.String() != "1:9-2:7" {
+		t.Errorf("wrong call span. got=%s", call.Span)
+	}
+	array := call.Children[2]
+	if array.Kind != "ArrayLiteral" || array.Span.String() != "2:3-2:6" {
+		t.Errorf("wrong array node. got=%s %s", array.Kind, array.Span)
+	}
+}
+
+func TestFromJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"kind": "Nope"}`, "Nope: unknown node kind"},
+		{`{"kind": "InfixExpression", "operator": "+", "children": [{"field": "left", "kind": "IntegerLiteral", "literal": "1"}]}`, `InfixExpression: missing child "right"`},
+		{`{"kind": "IntegerLiteral", "literal": "x"}`, `IntegerLiteral: invalid integer "x"`},
+		{`{"kind": "LetStatement", "children": [{"field": "name", "kind": "IntegerLiteral", "literal": "1"}, {"field": "value", "kind": "Identifier", "literal": "a"}]}`, `LetStatement: child "name" must be an Identifier, got IntegerLiteral`},
+	}
+
+	for _, tt := range tests {
+		_, err := ast.FromJSON([]byte(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %s. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
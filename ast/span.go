@@ -0,0 +1,74 @@
+package ast
+
+import (
+	"github.com/fanyeke/monkey/token"
+	"reflect"
+)
+
+// SpanOf 返回节点在源代码中的区间, 从节点的第一个词法单元开始, 到最后一个词法单元结束
+// 分组用的括号不属于任何节点, 不计算在内; 手工构造、没有位置信息的节点返回零值
+func SpanOf(node Node) token.Span {
+	var span token.Span
+	extend := func(p token.Position) {
+		if !p.IsValid() {
+			return
+		}
+		if !span.Start.IsValid() || p.Offset < span.Start.Offset {
+			span.Start = p
+		}
+		if !span.End.IsValid() || p.Offset > span.End.Offset {
+			span.End = p
+		}
+	}
+
+	for _, tok := range ownTokens(node) {
+		extend(tok.Pos)
+		extend(tok.End)
+	}
+	for _, child := range Children(node) {
+		childSpan := SpanOf(child.Node)
+		extend(childSpan.Start)
+		extend(childSpan.End)
+	}
+	return span
+}
+
+// Path 返回从 root 到包含 pos 的最内层节点的路径, 第一个元素是 root, pos 不在 root 中时返回 nil
+func Path(root Node, pos token.Position) []Node {
+	if !SpanOf(root).Contains(pos) {
+		return nil
+	}
+	path := []Node{root}
+	for {
+		var next Node
+		for _, child := range Children(path[len(path)-1]) {
+			if SpanOf(child.Node).Contains(pos) {
+				next = child.Node
+				break
+			}
+		}
+		if next == nil {
+			return path
+		}
+		path = append(path, next)
+	}
+}
+
+// ownTokens 返回节点自身保存的词法单元, 即 Token 字段和结尾括号的 Close 字段
+// 所有节点都按这个约定命名字段, 这里用反射读取, 新增节点类型时不需要修改
+func ownTokens(node Node) []token.Token {
+	v := reflect.ValueOf(node)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil
+	}
+	v = v.Elem()
+	var tokens []token.Token
+	for _, name := range []string{"Token", "Close"} {
+		if f := v.FieldByName(name); f.IsValid() {
+			if tok, ok := f.Interface().(token.Token); ok {
+				tokens = append(tokens, tok)
+			}
+		}
+	}
+	return tokens
+}
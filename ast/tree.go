@@ -0,0 +1,80 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PrintTree 以树的形式打印语法树, 每个子节点前面是它在父节点中的位置
+func PrintTree(out io.Writer, node Node) {
+	fmt.Fprintf(out, "%s\n", Label(node))
+	printChildren(out, node, "")
+}
+
+func printChildren(out io.Writer, node Node, indent string) {
+	children := Children(node)
+	for i, child := range children {
+		branch, next := "├─ ", "│  "
+		if i == len(children)-1 {
+			branch, next = "└─ ", "   "
+		}
+		fmt.Fprintf(out, "%s%s%s: %s\n", indent, branch, child.Name, Label(child.Node))
+		printChildren(out, child.Node, indent+next)
+	}
+}
+
+// Kind 返回节点的类型名, 例如 "InfixExpression"
+func Kind(node Node) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+}
+
+// Label 节点的类型名, 字面量、标识符和运算符附带它们的值
+func Label(node Node) string {
+	name := Kind(node)
+	if op := operator(node); op != "" {
+		return name + " " + op
+	}
+	if lit, ok := literal(node); ok {
+		if _, isString := node.(*StringLiteral); isString {
+			return name + " " + fmt.Sprintf("%q", lit)
+		}
+		return name + " " + lit
+	}
+	if fn, ok := node.(*FunctionLiteral); ok && fn.Name != "" {
+		return name + " " + fn.Name
+	}
+	return name
+}
+
+// operator 返回前缀和中缀表达式的运算符
+func operator(node Node) string {
+	switch node := node.(type) {
+	case *PrefixExpression:
+		return node.Operator
+	case *InfixExpression:
+		return node.Operator
+	}
+	return ""
+}
+
+// literal 返回字面量和标识符的值, 字符串不带引号, 正则带上两边的"/"和标志位
+func literal(node Node) (string, bool) {
+	switch node := node.(type) {
+	case *Identifier:
+		return node.Value, true
+	case *IntegerLiteral:
+		return node.Token.Literal, true
+	case *FloatLiteral:
+		return node.Token.Literal, true
+	case *StringLiteral:
+		return node.Value, true
+	case *Boolean:
+		return node.String(), true
+	case *RegexLiteral:
+		return node.String(), true
+	case *NamedType:
+		return node.Name, true
+	}
+	return "", false
+}
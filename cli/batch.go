@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/fanyeke/monkey/evaluator"
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/object"
+	"github.com/fanyeke/monkey/parser"
+	"io"
+	"os"
+	"strings"
+)
+
+// batchCase 批量求值的一个程序
+// JSON 中可以直接写源代码字符串, 也可以写成 {"name": ..., "input": ..., "stdin": ...}
+type batchCase struct {
+	Name  string `json:"name,omitempty"`
+	Input string `json:"input"`
+	Stdin string `json:"stdin,omitempty"` // 程序中 input() 读取的内容
+}
+
+func (c *batchCase) UnmarshalJSON(data []byte) error {
+	var src string
+	if err := json.Unmarshal(data, &src); err == nil {
+		c.Input = src
+		return nil
+	}
+	type plain batchCase
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+// batchResult 一个程序的求值结果
+type batchResult struct {
+	Name         string   `json:"name,omitempty"`
+	Input        string   `json:"input"`
+	AST          string   `json:"ast,omitempty"`    // 规范化后的源代码, 即 Program.String(), 有解析错误时为空
+	Result       string   `json:"result,omitempty"` // 最后一个值的 Inspect(), 出错时为空
+	Output       string   `json:"output,omitempty"` // puts、print 等写出的内容
+	ParserErrors []string `json:"parser_errors"`
+	Error        string   `json:"error,omitempty"` // 运行时错误
+}
+
+// batchCommand monkey batch [-o report.json] [file], 不指定文件或文件为 - 时从标准输入读取
+func batchCommand(args []string, stdio IO) int {
+	flags := flag.NewFlagSet("batch", flag.ContinueOnError)
+	flags.SetOutput(stdio.Err)
+	output := flags.String("o", "", "write the report to `file` instead of stdout")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 1 {
+		return usageError(stdio, "batch")
+	}
+
+	var data []byte
+	var err error
+	if path := flags.Arg(0); path == "" || path == "-" {
+		data, err = io.ReadAll(stdio.In)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintf(stdio.Err, "monkey: %s\n", err)
+		return ExitUsage
+	}
+
+	var cases []batchCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		fmt.Fprintf(stdio.Err, "monkey: batch input must be a JSON array of programs: %s\n", err)
+		return ExitUsage
+	}
+	results := make([]batchResult, len(cases))
+	for i, c := range cases {
+		results[i] = evaluateCase(c)
+	}
+
+	report, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		fmt.Fprintf(stdio.Err, "monkey: %s\n", err)
+		return ExitRuntimeError
+	}
+	report = append(report, '\n')
+	if *output != "" {
+		if err := os.WriteFile(*output, report, 0644); err != nil {
+			fmt.Fprintf(stdio.Err, "monkey: %s\n", err)
+			return ExitRuntimeError
+		}
+		return ExitOK
+	}
+	stdio.Out.Write(report)
+	return ExitOK
+}
+
+// evaluateCase 在独立的环境中求值一个程序, 程序之间不共享变量、模块和输入输出
+// 解释器的内部错误只记录为这个程序的错误, 不影响其他程序
+func evaluateCase(c batchCase) (result batchResult) {
+	result = batchResult{Name: c.Name, Input: c.Input, ParserErrors: []string{}}
+	defer func() {
+		if r := recover(); r != nil {
+			result.Error = fmt.Sprintf("internal error: %v", r)
+		}
+	}()
+
+	p := parser.New(lexer.New(c.Input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		result.ParserErrors = p.Errors()
+		return result
+	}
+	result.AST = program.String()
+
+	var out bytes.Buffer
+	env := object.NewEnvironment()
+	env.SetContext(object.NewContext(strings.NewReader(c.Stdin), &out, &out))
+	evaluated := evaluator.Eval(program, env)
+	result.Output = out.String()
+
+	if errObj, ok := evaluated.(*object.Error); ok {
+		result.Error = errObj.Message
+	} else if evaluated != nil {
+		result.Result = evaluated.Inspect()
+	}
+	return result
+}
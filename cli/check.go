@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/fanyeke/monkey/resolver"
+	"github.com/fanyeke/monkey/types"
+	"sort"
+)
+
+// checkCommand monkey check [--infer] [file], 不运行程序, 静态地检查其中的名字和类型
+// --infer 不根据注解检查, 而是推断类型并先打印顶层函数的签名
+// 有错误级别的诊断时返回 ExitRuntimeError, 只有警告时返回 ExitOK
+func checkCommand(args []string, stdio IO) int {
+	flags := newFlagSet("check", stdio)
+	infer := flags.Bool("infer", false, "infer types Hindley-Milner style and print the signatures of top-level functions")
+	src, code := readSource(flags, args, stdio)
+	if code != ExitOK {
+		return code
+	}
+	name := flags.Arg(0)
+	if name == "" || name == "-" {
+		name = "<stdin>"
+	}
+
+	program, ok := parseSource(name, src, stdio)
+	if !ok {
+		return ExitParseError
+	}
+	diagnostics := resolver.Resolve(program)
+	if *infer {
+		signatures, errs := types.Infer(program)
+		for _, s := range signatures {
+			fmt.Fprintln(stdio.Out, s)
+		}
+		diagnostics = append(diagnostics, errs...)
+	} else {
+		diagnostics = append(diagnostics, types.Check(program)...)
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Span.Start.Before(diagnostics[j].Span.Start)
+	})
+	code = ExitOK
+	for _, d := range diagnostics {
+		fmt.Fprintf(stdio.Out, "%s:%s\n", name, d)
+		if d.Severity == resolver.Error {
+			code = ExitRuntimeError
+		}
+	}
+	return code
+}
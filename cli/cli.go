@@ -0,0 +1,142 @@
+// Package cli 实现 monkey 命令行工具的各个子命令
+package cli
+
+import (
+	"fmt"
+	"github.com/fanyeke/monkey/lsp"
+	"github.com/fanyeke/monkey/repl"
+	"io"
+	"os"
+	"os/user"
+	"sort"
+)
+
+// 进程的退出码
+const (
+	ExitOK           = 0
+	ExitRuntimeError = 1  // 求值时出错
+	ExitParseError   = 2  // 语法分析出错
+	ExitUsage        = 64 // 命令行参数错误
+)
+
+// IO 子命令使用的标准输入输出
+type IO struct {
+	In  io.Reader
+	Out io.Writer
+	Err io.Writer
+}
+
+// subcommand monkey 的一个子命令, run 返回进程的退出码
+type subcommand struct {
+	usage string
+	help  string
+	run   func(args []string, stdio IO) int
+}
+
+var subcommands map[string]*subcommand
+
+func init() {
+	subcommands = map[string]*subcommand{
+		"run":    {"run <file> [args...]", "run a script, use - to read it from stdin", runCommand},
+		"repl":   {"repl", "start the interactive interpreter", replCommand},
+		"batch":  {"batch [-o report.json] [file]", "evaluate a JSON array of programs and print a JSON report", batchCommand},
+		"tokens": {"tokens [--json] [file]", "print the tokens of a program", tokensCommand},
+		"parse":  {"parse [--json] [file]", "print the syntax tree of a program", parseCommand},
+		"ast":    {"ast [--dot] [--spans] [--highlight L:C[-L:C]] [file]", "print the syntax tree, or render it as a Graphviz graph", astCommand},
+		"lsp":    {"lsp", "run the language server on stdin and stdout", lspCommand},
+		"check":  {"check [--infer] [file]", "report name and type errors without running the program", checkCommand},
+		"debug":  {"debug [-b LINE[:COND]]... <file> [args...]", "run a script in the interactive debugger", debugCommand},
+		"dap":    {"dap", "run the debug adapter (DAP) on stdin and stdout", dapCommand},
+		"vet":    {"vet [-enable rules] [-disable rules] [--json] [--rules] [file]", "report suspicious code such as unreachable statements", vetCommand},
+		"help":   {"help", "show this help", helpCommand},
+	}
+}
+
+// Run 解析命令行参数并执行对应的子命令, 返回进程的退出码
+//
+//	monkey                  标准输入是终端时启动 repl, 否则运行从标准输入读取的脚本
+//	monkey -e <expr> [args] 求值表达式并打印结果
+//	monkey <subcommand> ...
+func Run(args []string, stdio IO) int {
+	if len(args) == 0 {
+		if isTerminal(stdio.In) {
+			return replCommand(nil, stdio)
+		}
+		return runCommand([]string{"-"}, stdio)
+	}
+
+	switch args[0] {
+	case "-e":
+		return evalCommand(args[1:], stdio)
+	case "-h", "--help":
+		return helpCommand(nil, stdio)
+	}
+	cmd, ok := subcommands[args[0]]
+	if !ok {
+		fmt.Fprintf(stdio.Err, "monkey: unknown command %q\n", args[0])
+		printUsage(stdio.Err)
+		return ExitUsage
+	}
+	return cmd.run(args[1:], stdio)
+}
+
+func replCommand(args []string, stdio IO) int {
+	if len(args) != 0 {
+		return usageError(stdio, "repl")
+	}
+	name := "there"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	fmt.Fprintf(stdio.Out, "Hello %s!This is the Monkey programming language!\n", name)
+	fmt.Fprintf(stdio.Out, "Feel free to type in commands\n")
+	repl.Start(stdio.In, stdio.Out)
+	return ExitOK
+}
+
+func lspCommand(args []string, stdio IO) int {
+	if len(args) != 0 {
+		return usageError(stdio, "lsp")
+	}
+	if err := lsp.Serve(stdio.In, stdio.Out); err != nil {
+		fmt.Fprintf(stdio.Err, "monkey lsp: %s\n", err)
+		return ExitRuntimeError
+	}
+	return ExitOK
+}
+
+func helpCommand(args []string, stdio IO) int {
+	printUsage(stdio.Out)
+	return ExitOK
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "usage:\n")
+	fmt.Fprintf(w, "  %-28s %s\n", "monkey", "start the repl, or run the script piped to stdin")
+	fmt.Fprintf(w, "  %-28s %s\n", "monkey -e <expr> [args...]", "evaluate an expression and print its value")
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := subcommands[name]
+		fmt.Fprintf(w, "  %-28s %s\n", "monkey "+cmd.usage, cmd.help)
+	}
+}
+
+// usageError 打印子命令的用法, 返回 ExitUsage
+func usageError(stdio IO, name string) int {
+	fmt.Fprintf(stdio.Err, "usage: monkey %s\n", subcommands[name].usage)
+	return ExitUsage
+}
+
+// isTerminal 判断输入是否是终端, 管道和文件都不是
+func isTerminal(in io.Reader) bool {
+	f, ok := in.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,341 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/fanyeke/monkey/ast"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runCLI(args []string, stdin string) (string, string, int) {
+	var out, errOut bytes.Buffer
+	code := Run(args, IO{In: strings.NewReader(stdin), Out: &out, Err: &errOut})
+	return out.String(), errOut.String(), code
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "main.mk")
+	os.WriteFile(script, []byte(`import "lib.mk" as lib; puts(lib.greet(ARGS[0])); puts(len(input()));`), 0644)
+	os.WriteFile(filepath.Join(dir, "lib.mk"), []byte(`export let greet = fn(name) { "hello " + name };`), 0644)
+	failing := filepath.Join(dir, "fail.mk")
+	os.WriteFile(failing, []byte("let inner = fn() { 1 + true }; let outer = fn() { let r = inner(); r }; outer();"), 0644)
+
+	tests := []struct {
+		args     []string
+		stdin    string
+		expected string
+		errOut   string
+		code     int
+	}{
+		{[]string{"run", script, "monkey"}, "abc\n", "hello monkey\n3\n", "", ExitOK},
+		{[]string{"run", failing}, "", "", failing + ": ERROR:type mismatch: INTEGER + BOOLEAN\n\tat inner\n\tat outer\n", ExitRuntimeError},
+		{[]string{"run", "-", "a", "b"}, "puts(ARGS)", "[a, b]\n", "", ExitOK},
+		{nil, "puts(1 + 1); exit(3); puts(4);", "2\n", "", 3},
+		{[]string{"-e", "1 + 2"}, "", "3\n", "", ExitOK},
+		{[]string{"-e", "puts(ARGS[1])", "x", "y"}, "", "y\n", "", ExitOK},
+		{[]string{"-e", "let x = 1;"}, "", "", "", ExitOK},
+		{[]string{"-e", "exit()"}, "", "", "", ExitOK},
+		{[]string{"-e", "try { exit(5) } catch (e) { 1 }"}, "", "", "", 5},
+		{[]string{"-e", "1 +* 2"}, "", "", "-e: no prefox parse function for * found\n", ExitParseError},
+		{[]string{"-e", "nope"}, "", "", "-e: ERROR:identifier not found: nope\n", ExitRuntimeError},
+		{[]string{"run", filepath.Join(dir, "missing.mk")}, "", "", "", ExitUsage},
+		{[]string{"run"}, "", "", "usage: monkey run <file> [args...]\n", ExitUsage},
+		{[]string{"-e"}, "", "", "usage: monkey -e <expr> [args...]\n", ExitUsage},
+	}
+
+	for _, tt := range tests {
+		out, errOut, code := runCLI(tt.args, tt.stdin)
+		if code != tt.code {
+			t.Errorf("wrong exit code for %q. expected=%d, got=%d (stderr=%q)", tt.args, tt.code, code, errOut)
+		}
+		if out != tt.expected {
+			t.Errorf("wrong stdout for %q. expected=%q, got=%q", tt.args, tt.expected, out)
+		}
+		if tt.errOut != "" && errOut != tt.errOut {
+			t.Errorf("wrong stderr for %q. expected=%q, got=%q", tt.args, tt.errOut, errOut)
+		}
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	_, errOut, code := runCLI([]string{"bogus"}, "")
+	if code != ExitUsage {
+		t.Errorf("wrong exit code. expected=%d, got=%d", ExitUsage, code)
+	}
+	if !strings.HasPrefix(errOut, "monkey: unknown command \"bogus\"\nusage:\n") {
+		t.Errorf("wrong stderr. got=%q", errOut)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	input := `["let a = 1; a + 2", {"name": "mismatch", "input": "1 + true"}, "let = 1;", "fn(1){}", {"input": "puts(input())", "stdin": "hi\n"}, "a"]`
+	expected := `[
+  {
+    "input": "let a = 1; a + 2",
+    "ast": "let a = 1;(a + 2)",
+    "result": "3",
+    "parser_errors": []
+  },
+  {
+    "name": "mismatch",
+    "input": "1 + true",
+    "ast": "(1 + true)",
+    "parser_errors": [],
+    "error": "type mismatch: INTEGER + BOOLEAN"
+  },
+  {
+    "input": "let = 1;",
+    "parser_errors": [
+      "expected next token to be IDENT, got = install",
+      "no prefox parse function for = found"
+    ]
+  },
+  {
+    "input": "fn(1){}",
+    "parser_errors": [
+      "unexpected INT in pattern",
+      "expected next token to be {, got ) install",
+      "no prefox parse function for ) found"
+    ]
+  },
+  {
+    "input": "puts(input())",
+    "ast": "puts(input())",
+    "result": "null",
+    "output": "hi\n",
+    "parser_errors": []
+  },
+  {
+    "input": "a",
+    "ast": "a",
+    "parser_errors": [],
+    "error": "identifier not found: a"
+  }
+]
+`
+	out, errOut, code := runCLI([]string{"batch"}, input)
+	if code != ExitOK {
+		t.Fatalf("wrong exit code. expected=%d, got=%d (stderr=%q)", ExitOK, code, errOut)
+	}
+	if out != expected {
+		t.Errorf("wrong report.\nexpected=%s\ngot=%s", expected, out)
+	}
+
+	dir := t.TempDir()
+	inFile, outFile := filepath.Join(dir, "input.json"), filepath.Join(dir, "json_out.txt")
+	os.WriteFile(inFile, []byte(input), 0644)
+	if _, errOut, code := runCLI([]string{"batch", "-o", outFile, inFile}, ""); code != ExitOK {
+		t.Fatalf("wrong exit code. expected=%d, got=%d (stderr=%q)", ExitOK, code, errOut)
+	}
+	if written, _ := os.ReadFile(outFile); string(written) != expected {
+		t.Errorf("wrong report file.\nexpected=%s\ngot=%s", expected, written)
+	}
+
+	if _, _, code := runCLI([]string{"batch"}, `{"input": "1"}`); code != ExitUsage {
+		t.Errorf("expected usage error for non-array input, got %d", code)
+	}
+}
+
+func TestTokensAndParse(t *testing.T) {
+	out, _, code := runCLI([]string{"tokens", "--json"}, "x + 1")
+	expected := `[
+  {
+    "type": "IDENT",
+    "literal": "x",
+    "span": {
+      "start": {
+        "offset": 0,
+        "line": 1,
+        "column": 1
+      },
+      "end": {
+        "offset": 1,
+        "line": 1,
+        "column": 2
+      }
+    }
+  },`
+	if code != ExitOK || !strings.HasPrefix(out, expected) {
+		t.Errorf("wrong tokens --json output. code=%d, got=%s", code, out)
+	}
+
+	out, _, code = runCLI([]string{"tokens"}, "let x")
+	if expected := "1:1      LET        \"let\"\n1:5      IDENT      \"x\"\n"; code != ExitOK || out != expected {
+		t.Errorf("wrong tokens output. expected=%q, got=%q", expected, out)
+	}
+
+	out, _, code = runCLI([]string{"parse", "--json"}, "-a")
+	var node map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &node); code != ExitOK || err != nil || node["kind"] != "Program" {
+		t.Errorf("wrong parse --json output. code=%d, got=%s", code, out)
+	}
+	program, err := ast.FromJSON([]byte(out))
+	if err != nil || program.String() != "(-a)" {
+		t.Errorf("parse --json output does not round trip. got=%v, %v", program, err)
+	}
+
+	out, _, code = runCLI([]string{"parse"}, "f(1)")
+	expected = "Program\n└─ statements[0]: ExpressionStatement\n   └─ expression: CallExpression\n      ├─ function: Identifier f\n      └─ arguments[0]: IntegerLiteral 1\n"
+	if code != ExitOK || out != expected {
+		t.Errorf("wrong parse output.\nexpected=%s\ngot=%s", expected, out)
+	}
+
+	if _, errOut, code := runCLI([]string{"parse", "--json"}, "let = 1"); code != ExitParseError || errOut == "" {
+		t.Errorf("expected parse error, got code=%d stderr=%q", code, errOut)
+	}
+}
+
+func TestASTCommand(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.mk")
+	os.WriteFile(file, []byte("let x = f(1, 2);"), 0644)
+
+	out, errOut, code := runCLI([]string{"ast", "--dot", "--highlight", "1:14", file}, "")
+	if code != ExitOK {
+		t.Fatalf("wrong exit code. expected=%d, got=%d (stderr=%q)", ExitOK, code, errOut)
+	}
+	if !strings.HasPrefix(out, "digraph AST {\n") || strings.Count(out, "fillcolor") != 1 ||
+		!strings.Contains(out, `[label="IntegerLiteral 2", style=filled, fillcolor="#ffe680"]`) {
+		t.Errorf("wrong DOT output. got=%s", out)
+	}
+
+	out, _, _ = runCLI([]string{"ast", "--dot", "--highlight", "1:9-1:16", file}, "")
+	if strings.Count(out, "fillcolor") != 4 {
+		t.Errorf("expected the call and its children to be highlighted. got=%s", out)
+	}
+
+	out, _, code = runCLI([]string{"ast"}, "x")
+	if code != ExitOK || out != "Program\n└─ statements[0]: ExpressionStatement\n   └─ expression: Identifier x\n" {
+		t.Errorf("wrong tree output. got=%q", out)
+	}
+
+	for _, highlight := range []string{"nope", "9:1"} {
+		if _, errOut, code := runCLI([]string{"ast", "--dot", "--highlight", highlight, file}, ""); code != ExitUsage || errOut == "" {
+			t.Errorf("expected usage error for --highlight %s, got code=%d stderr=%q", highlight, code, errOut)
+		}
+	}
+}
+
+func TestLSPCommand(t *testing.T) {
+	frame := func(body string) string {
+		return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	stdin := frame(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`) +
+		frame(`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`) +
+		frame(`{"jsonrpc":"2.0","method":"exit"}`)
+	out, errOut, code := runCLI([]string{"lsp"}, stdin)
+	if code != ExitOK || errOut != "" {
+		t.Fatalf("lsp failed with code %d: %s", code, errOut)
+	}
+	if !strings.Contains(out, `"serverInfo":{"name":"monkey-lsp"}`) || !strings.Contains(out, `{"jsonrpc":"2.0","id":2,"result":null}`) {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	// 没有 shutdown 就断开连接
+	_, errOut, code = runCLI([]string{"lsp"}, "")
+	if code != ExitRuntimeError || !strings.Contains(errOut, "exit without shutdown") {
+		t.Errorf("expected failure without shutdown, got code %d, stderr %q", code, errOut)
+	}
+}
+
+func TestDebugCommand(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "main.mk")
+	os.WriteFile(script, []byte("let double = fn(n) {\n  n * 2\n};\nlet x = input();\nputs(double(len(x)));\n"), 0644)
+
+	// 调试命令和脚本的 input() 共用标准输入
+	out, errOut, code := runCLI([]string{"debug", "-b", "2:n > 1", script}, "c\nabc\np n\nc\n")
+	if code != ExitOK || errOut != "" {
+		t.Fatalf("debug failed with code %d: %s", code, errOut)
+	}
+	expected := "stopped at line 1 (entry)\n=>    1  let double = fn(n) {\n(mdb) " +
+		"breakpoint 1 hit at line 2\n=>    2    n * 2\n(mdb) 3\n(mdb) 6\n"
+	if out != expected {
+		t.Errorf("wrong debug output.\nexpected=%q\ngot=%q", expected, out)
+	}
+
+	// 输入结束时中止程序
+	_, _, code = runCLI([]string{"debug", script}, "")
+	if code != ExitRuntimeError {
+		t.Errorf("expected the program to be aborted, got code %d", code)
+	}
+
+	_, errOut, code = runCLI([]string{"debug", "-b", "x", script}, "")
+	if code != ExitUsage || !strings.Contains(errOut, "invalid line") {
+		t.Errorf("expected a usage error, got code %d, stderr %q", code, errOut)
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	tests := []struct {
+		args     []string
+		stdin    string
+		expected string
+		code     int
+	}{
+		{[]string{"check"}, "let x = 1; puts(x);", "", ExitOK},
+		{[]string{"check"}, "let x = 1;", "<stdin>:1:5: warning: x declared and not used\n", ExitOK},
+		{[]string{"check", "-"}, "if (false) { nope }", "<stdin>:1:14: error: identifier not found: nope\n", ExitRuntimeError},
+		{[]string{"check"}, `let x: int = "a"; x - 1`, "<stdin>:1:14: error: cannot use string as int in let x\n", ExitRuntimeError},
+		{[]string{"check", "--infer"}, "let id = fn(x) { x }; let add = fn(a, b) { a + b }; add(id(1), \"a\")",
+			"id: fn('a) -> 'a\nadd: fn('a, 'a) -> 'a where 'a: number | string\n<stdin>:1:64: error: cannot use string as int in argument 2 to add\n", ExitRuntimeError},
+		{[]string{"check", "--infer"}, `let sub = fn(a, b) { a - b }; sub("x", 1)`,
+			"sub: fn('a, 'a) -> 'a where 'a: number\n<stdin>:1:35: error: cannot use string as 'a in argument 1 to sub where 'a: number\n", ExitRuntimeError},
+		{[]string{"check", "--infer"}, `let x: int = "a";`, "<stdin>:1:5: warning: x declared and not used\n<stdin>:1:14: error: cannot use string as int in let x\n", ExitRuntimeError},
+		{[]string{"check"}, "let = 1;", "", ExitParseError},
+	}
+	for _, tt := range tests {
+		out, _, code := runCLI(tt.args, tt.stdin)
+		if out != tt.expected || code != tt.code {
+			t.Errorf("check %q: expected (%q, %d), got (%q, %d)", tt.stdin, tt.expected, tt.code, out, code)
+		}
+	}
+}
+
+func TestVetCommand(t *testing.T) {
+	src := "let f = fn() { return 1; f() };\nif (f == f) { len() } // vet:ignore builtin-arity\n"
+	tests := []struct {
+		args     []string
+		expected string
+		code     int
+	}{
+		{[]string{"vet"}, "<stdin>:1:26: unreachable code after return (unreachable)\n" +
+			"<stdin>:2:5: comparing a function with ==; functions are only equal to themselves (function-compare)\n", ExitRuntimeError},
+		{[]string{"vet", "-enable", "unreachable", "--json"}, `[
+  {
+    "file": "\u003cstdin\u003e",
+    "rule": "unreachable",
+    "message": "unreachable code after return",
+    "span": {
+      "start": {
+        "offset": 25,
+        "line": 1,
+        "column": 26
+      },
+      "end": {
+        "offset": 28,
+        "line": 1,
+        "column": 29
+      }
+    }
+  }
+]
+`, ExitRuntimeError},
+		{[]string{"vet", "-disable", "unreachable,function-compare"}, "", ExitOK},
+		{[]string{"vet", "-enable", "nope"}, "", ExitUsage},
+	}
+	for _, tt := range tests {
+		out, _, code := runCLI(tt.args, src)
+		if out != tt.expected || code != tt.code {
+			t.Errorf("%v: expected (%q, %d), got (%q, %d)", tt.args, tt.expected, tt.code, out, code)
+		}
+	}
+
+	out, _, code := runCLI([]string{"vet", "--rules"}, "")
+	if code != ExitOK || !strings.Contains(out, "builtin-arity ") {
+		t.Errorf("expected the rule list, got %q (%d)", out, code)
+	}
+}
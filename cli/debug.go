@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/fanyeke/monkey/debugger"
+	"github.com/fanyeke/monkey/debugger/dap"
+	"github.com/fanyeke/monkey/object"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// breakpointFlag 一个 -b 参数, 格式为 LINE 或 LINE:COND
+type breakpointFlag struct {
+	line      int
+	condition string
+}
+
+// debugCommand 在命令行调试器中运行脚本, 程序在第一条语句之前暂停
+// 调试命令和脚本的 input() 共用标准输入
+func debugCommand(args []string, stdio IO) int {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	flags.SetOutput(stdio.Err)
+	var breakpoints []breakpointFlag
+	flags.Func("b", "set a breakpoint at `LINE[:COND]`, may be repeated", func(value string) error {
+		lineArg, condition, _ := strings.Cut(value, ":")
+		line, err := strconv.Atoi(lineArg)
+		if err != nil {
+			return fmt.Errorf("invalid line %q", lineArg)
+		}
+		breakpoints = append(breakpoints, breakpointFlag{line, condition})
+		return nil
+	})
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		return usageError(stdio, "debug")
+	}
+	path, scriptArgs := flags.Arg(0), flags.Args()[1:]
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(stdio.Err, "monkey: %s\n", err)
+		return ExitUsage
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	program, ok := parseSource(path, string(src), stdio)
+	if !ok {
+		return ExitParseError
+	}
+
+	in := bufio.NewReader(stdio.In)
+	env := scriptEnv(abs, scriptArgs, object.NewContext(in, stdio.Out, stdio.Err))
+	console := debugger.NewConsole(in, stdio.Out, string(src))
+	d := debugger.New(console.Handle)
+	d.StopOnEntry = true
+	for _, bp := range breakpoints {
+		if _, err := d.SetBreakpoint(bp.line, bp.condition); err != nil {
+			fmt.Fprintf(stdio.Err, "monkey: %s\n", err)
+			return ExitUsage
+		}
+	}
+	return exitStatus(path, d.Run(program, env), stdio, false)
+}
+
+func dapCommand(args []string, stdio IO) int {
+	if len(args) != 0 {
+		return usageError(stdio, "dap")
+	}
+	if err := dap.Serve(stdio.In, stdio.Out); err != nil {
+		fmt.Fprintf(stdio.Err, "monkey dap: %s\n", err)
+		return ExitRuntimeError
+	}
+	return ExitOK
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/fanyeke/monkey/evaluator"
+	"github.com/fanyeke/monkey/object"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// runCommand monkey run <file> [args...], file 为 - 时从标准输入读取脚本
+func runCommand(args []string, stdio IO) int {
+	if len(args) == 0 {
+		return usageError(stdio, "run")
+	}
+	path, scriptArgs := args[0], args[1:]
+
+	if path == "-" {
+		src, err := io.ReadAll(stdio.In)
+		if err != nil {
+			fmt.Fprintf(stdio.Err, "monkey: %s\n", err)
+			return ExitUsage
+		}
+		return execute("<stdin>", string(src), "", scriptArgs, stdio, false)
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(stdio.Err, "monkey: %s\n", err)
+		return ExitUsage
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	return execute(path, string(src), abs, scriptArgs, stdio, false)
+}
+
+// evalCommand monkey -e <expr> [args...], 结果不是 null 时打印出来
+func evalCommand(args []string, stdio IO) int {
+	if len(args) == 0 {
+		fmt.Fprintf(stdio.Err, "usage: monkey -e <expr> [args...]\n")
+		return ExitUsage
+	}
+	return execute("-e", args[0], "", args[1:], stdio, true)
+}
+
+// execute 解析并求值一段脚本, 返回进程的退出码
+// name 用于错误信息, file 是脚本的绝对路径(用于解析相对路径的 import), 脚本参数通过 ARGS 数组传入
+func execute(name, src, file string, scriptArgs []string, stdio IO, printResult bool) int {
+	program, ok := parseSource(name, src, stdio)
+	if !ok {
+		return ExitParseError
+	}
+
+	env := scriptEnv(file, scriptArgs, object.NewContext(stdio.In, stdio.Out, stdio.Err))
+	return exitStatus(name, evaluator.Eval(program, env), stdio, printResult)
+}
+
+// scriptEnv 创建运行脚本的最外层环境, 脚本参数通过 ARGS 数组传入
+func scriptEnv(file string, scriptArgs []string, ctx *object.Context) *object.Environment {
+	env := object.NewEnvironment()
+	env.SetContext(ctx)
+	env.SetFile(file)
+	env.Set("ARGS", stringArray(scriptArgs))
+	return env
+}
+
+// exitStatus 根据求值结果返回退出码, 运行时错误连同调用栈打印到标准错误
+func exitStatus(name string, result object.Object, stdio IO, printResult bool) int {
+	if code, ok := evaluator.ExitCode(result); ok {
+		return code
+	}
+	if errObj, ok := result.(*object.Error); ok {
+		fmt.Fprintf(stdio.Err, "%s: %s\n", name, errObj.Inspect())
+		for _, frame := range errObj.Stack {
+			fmt.Fprintf(stdio.Err, "\tat %s\n", frame)
+		}
+		return ExitRuntimeError
+	}
+	if printResult && result != nil && result.Type() != object.NULL_OBJ {
+		fmt.Fprintf(stdio.Out, "%s\n", result.Inspect())
+	}
+	return ExitOK
+}
+
+func stringArray(values []string) *object.Array {
+	elements := make([]object.Object, len(values))
+	for i, v := range values {
+		elements[i] = &object.String{Value: v}
+	}
+	return &object.Array{Elements: elements}
+}
@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/ast/dot"
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/parser"
+	"github.com/fanyeke/monkey/token"
+	"io"
+	"os"
+	"strings"
+)
+
+// jsonToken tokens --json 输出的一个词法单元
+type jsonToken struct {
+	Type    token.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+	Span    token.Span      `json:"span"`
+}
+
+// tokensCommand monkey tokens [--json] [file], 打印词法分析的结果
+func tokensCommand(args []string, stdio IO) int {
+	flags := newFlagSet("tokens", stdio)
+	asJSON := flags.Bool("json", false, "print JSON instead of text")
+	src, code := readSource(flags, args, stdio)
+	if code != ExitOK {
+		return code
+	}
+
+	tokens := []jsonToken{}
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		tokens = append(tokens, jsonToken{Type: tok.Type, Literal: tok.Literal, Span: token.Span{Start: tok.Pos, End: tok.End}})
+	}
+	if *asJSON {
+		return writeJSON(stdio, tokens)
+	}
+	for _, tok := range tokens {
+		fmt.Fprintf(stdio.Out, "%-8s %-10s %q\n", tok.Span.Start, tok.Type, tok.Literal)
+	}
+	return ExitOK
+}
+
+// parseCommand monkey parse [--json] [file], 打印语法树
+func parseCommand(args []string, stdio IO) int {
+	flags := newFlagSet("parse", stdio)
+	asJSON := flags.Bool("json", false, "print JSON instead of text")
+	src, code := readSource(flags, args, stdio)
+	if code != ExitOK {
+		return code
+	}
+
+	program, ok := parseSource("parse", src, stdio)
+	if !ok {
+		return ExitParseError
+	}
+	if *asJSON {
+		return writeJSON(stdio, program)
+	}
+	ast.PrintTree(stdio.Out, program)
+	return ExitOK
+}
+
+// newFlagSet 创建子命令的参数解析器, 错误信息写到标准错误
+func newFlagSet(name string, stdio IO) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stdio.Err)
+	return flags
+}
+
+// readSource 解析参数并读取源代码, 没有指定文件或文件为 - 时从标准输入读取
+func readSource(flags *flag.FlagSet, args []string, stdio IO) (string, int) {
+	if err := flags.Parse(args); err != nil || flags.NArg() > 1 {
+		return "", usageError(stdio, flags.Name())
+	}
+
+	var data []byte
+	var err error
+	if path := flags.Arg(0); path == "" || path == "-" {
+		data, err = io.ReadAll(stdio.In)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintf(stdio.Err, "monkey: %s\n", err)
+		return "", ExitUsage
+	}
+	return string(data), ExitOK
+}
+
+// astCommand monkey ast [--dot] [--spans] [--highlight L:C[-L:C]] [file]
+// --highlight 为一个位置时高亮包含它的最内层节点, 为一个区间时高亮区间内的所有节点
+func astCommand(args []string, stdio IO) int {
+	flags := newFlagSet("ast", stdio)
+	asDot := flags.Bool("dot", false, "print a Graphviz DOT graph instead of a tree")
+	spans := flags.Bool("spans", false, "show node spans in the DOT graph")
+	highlight := flags.String("highlight", "", "highlight the node at `line:col`, or all nodes within line:col-line:col")
+	src, code := readSource(flags, args, stdio)
+	if code != ExitOK {
+		return code
+	}
+
+	program, ok := parseSource("ast", src, stdio)
+	if !ok {
+		return ExitParseError
+	}
+	if !*asDot {
+		ast.PrintTree(stdio.Out, program)
+		return ExitOK
+	}
+
+	opts := dot.Options{ShowSpans: *spans}
+	if *highlight != "" {
+		span, err := highlightSpan(program, *highlight)
+		if err != nil {
+			fmt.Fprintf(stdio.Err, "monkey: %s\n", err)
+			return ExitUsage
+		}
+		opts.Highlight = &span
+	}
+	dot.Write(stdio.Out, program, opts)
+	return ExitOK
+}
+
+func highlightSpan(program *ast.Program, s string) (token.Span, error) {
+	start, end, isRange := strings.Cut(s, "-")
+	startPos, err := token.ParsePosition(start)
+	if err != nil {
+		return token.Span{}, err
+	}
+	if isRange {
+		endPos, err := token.ParsePosition(end)
+		if err != nil {
+			return token.Span{}, err
+		}
+		return token.Span{Start: startPos, End: endPos}, nil
+	}
+	path := ast.Path(program, startPos)
+	if len(path) == 0 {
+		return token.Span{}, fmt.Errorf("no node at %s", startPos)
+	}
+	return ast.SpanOf(path[len(path)-1]), nil
+}
+
+// parseSource 解析源代码, 出错时把错误打印到标准错误
+func parseSource(name, src string, stdio IO) (*ast.Program, bool) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(stdio.Err, "%s: %s\n", name, msg)
+		}
+		return nil, false
+	}
+	return program, true
+}
+
+func writeJSON(stdio IO, v interface{}) int {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintf(stdio.Err, "monkey: %s\n", err)
+		return ExitRuntimeError
+	}
+	stdio.Out.Write(append(data, '\n'))
+	return ExitOK
+}
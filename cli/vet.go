@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/fanyeke/monkey/lint"
+	"strings"
+)
+
+// vetDiagnostic vet --json 输出的一条诊断
+type vetDiagnostic struct {
+	File string `json:"file"`
+	lint.Diagnostic
+}
+
+// vetCommand monkey vet [-enable rules] [-disable rules] [--json] [--rules] [file]
+// 发现问题时返回 ExitRuntimeError
+func vetCommand(args []string, stdio IO) int {
+	flags := newFlagSet("vet", stdio)
+	enable := flags.String("enable", "", "run only these comma separated `rules`")
+	disable := flags.String("disable", "", "do not run these comma separated `rules`")
+	asJSON := flags.Bool("json", false, "print JSON instead of text")
+	listRules := flags.Bool("rules", false, "list the available rules and exit")
+	src, code := readSource(flags, args, stdio)
+	if *listRules {
+		for _, rule := range lint.Rules() {
+			fmt.Fprintf(stdio.Out, "%-20s %s\n", rule.Name, rule.Doc)
+		}
+		return ExitOK
+	}
+	if code != ExitOK {
+		return code
+	}
+	name := flags.Arg(0)
+	if name == "" || name == "-" {
+		name = "<stdin>"
+	}
+
+	if _, ok := parseSource(name, src, stdio); !ok {
+		return ExitParseError
+	}
+	diagnostics, err := lint.Source(src, lint.Options{Enable: splitList(*enable), Disable: splitList(*disable)})
+	if err != nil {
+		fmt.Fprintf(stdio.Err, "monkey vet: %s\n", err)
+		return ExitUsage
+	}
+
+	if *asJSON {
+		report := make([]vetDiagnostic, len(diagnostics))
+		for i, d := range diagnostics {
+			report[i] = vetDiagnostic{File: name, Diagnostic: d}
+		}
+		if code := writeJSON(stdio, report); code != ExitOK {
+			return code
+		}
+	} else {
+		for _, d := range diagnostics {
+			fmt.Fprintf(stdio.Out, "%s:%s\n", name, d)
+		}
+	}
+	if len(diagnostics) > 0 {
+		return ExitRuntimeError
+	}
+	return ExitOK
+}
+
+// splitList 拆分逗号分隔的列表, 忽略空项
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
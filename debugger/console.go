@@ -0,0 +1,240 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/fanyeke/monkey/object"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CONSOLE_PROMPT 命令行调试器的提示符
+const CONSOLE_PROMPT = "(mdb) "
+
+// Console 命令行调试器前端, 程序暂停时从 in 读取命令, 直到遇到继续执行的命令
+type Console struct {
+	in     *bufio.Reader
+	out    io.Writer
+	source []string // 被调试程序的各行, 用于显示源码
+	last   string   // 上一条命令, 输入空行时重复执行
+}
+
+// NewConsole 创建命令行前端, in 可以与被调试程序的 input() 共享同一个缓冲
+func NewConsole(in *bufio.Reader, out io.Writer, source string) *Console {
+	return &Console{in: in, out: out, source: strings.Split(source, "\n")}
+}
+
+// consoleCommand 一条调试命令, run 返回 true 时程序按 action 继续执行
+type consoleCommand struct {
+	names []string
+	usage string
+	help  string
+	run   func(c *Console, d *Debugger, arg string) (Action, bool)
+}
+
+var consoleCommands []*consoleCommand
+
+func init() {
+	resume := func(action Action) func(*Console, *Debugger, string) (Action, bool) {
+		return func(*Console, *Debugger, string) (Action, bool) { return action, true }
+	}
+	consoleCommands = []*consoleCommand{
+		{[]string{"continue", "c"}, "continue", "run until the next breakpoint", resume(Continue)},
+		{[]string{"step", "s"}, "step", "run to the next line, entering called functions", resume(StepIn)},
+		{[]string{"next", "n"}, "next", "run to the next line of the current function", resume(StepOver)},
+		{[]string{"finish", "f"}, "finish", "run until the current function returns", resume(StepOut)},
+		{[]string{"break", "b"}, "break LINE [if COND]", "set a breakpoint, optionally with a condition", (*Console).breakCommand},
+		{[]string{"delete", "d"}, "delete ID", "delete a breakpoint", (*Console).deleteCommand},
+		{[]string{"breakpoints", "bl"}, "breakpoints", "list breakpoints", (*Console).breakpointsCommand},
+		{[]string{"backtrace", "bt"}, "backtrace", "print the call stack", (*Console).backtraceCommand},
+		{[]string{"env", "e"}, "env [FRAME]", "print the environment chain of a frame", (*Console).envCommand},
+		{[]string{"print", "p"}, "print EXPR", "evaluate an expression in the current frame", (*Console).printCommand},
+		{[]string{"list", "l"}, "list", "show the source around the current line", (*Console).listCommand},
+		{[]string{"quit", "q"}, "quit", "abort the program", resume(Quit)},
+		{[]string{"help", "h"}, "help", "show this help", (*Console).helpCommand},
+	}
+}
+
+func lookupConsoleCommand(name string) *consoleCommand {
+	for _, cmd := range consoleCommands {
+		for _, n := range cmd.names {
+			if n == name {
+				return cmd
+			}
+		}
+	}
+	return nil
+}
+
+// Handle 实现 Handler: 显示暂停的位置, 然后执行命令直到继续执行; 输入结束时中止程序
+func (c *Console) Handle(d *Debugger, stop Stop) Action {
+	switch stop.Reason {
+	case ReasonBreakpoint:
+		fmt.Fprintf(c.out, "breakpoint %d hit at line %d\n", stop.Breakpoint.ID, stop.Line)
+	default:
+		fmt.Fprintf(c.out, "stopped at line %d (%s)\n", stop.Line, stop.Reason)
+	}
+	c.printLine(stop.Line, true)
+
+	for {
+		fmt.Fprint(c.out, CONSOLE_PROMPT)
+		line, err := c.in.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintln(c.out)
+			return Quit
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			line = c.last
+		}
+		if line == "" {
+			continue
+		}
+		c.last = line
+
+		name, arg := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			name, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		cmd := lookupConsoleCommand(name)
+		if cmd == nil {
+			fmt.Fprintf(c.out, "unknown command %q, type help for a list of commands\n", name)
+			continue
+		}
+		if action, resume := cmd.run(c, d, arg); resume {
+			return action
+		}
+	}
+}
+
+// printLine 显示源码中的一行, current 为 true 时用 "=>" 标出
+func (c *Console) printLine(line int, current bool) {
+	if line < 1 || line > len(c.source) {
+		return
+	}
+	marker := "  "
+	if current {
+		marker = "=>"
+	}
+	fmt.Fprintf(c.out, "%s %4d  %s\n", marker, line, c.source[line-1])
+}
+
+func (c *Console) breakCommand(d *Debugger, arg string) (Action, bool) {
+	lineArg, condition := arg, ""
+	if i := strings.Index(arg, " if "); i >= 0 {
+		lineArg, condition = arg[:i], arg[i+len(" if "):]
+	}
+	line, err := strconv.Atoi(strings.TrimSpace(lineArg))
+	if err != nil {
+		fmt.Fprintln(c.out, "usage: break LINE [if COND]")
+		return 0, false
+	}
+	bp, err := d.SetBreakpoint(line, condition)
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+		return 0, false
+	}
+	if bp.Condition != "" {
+		fmt.Fprintf(c.out, "breakpoint %d at line %d if %s\n", bp.ID, bp.Line, bp.Condition)
+	} else {
+		fmt.Fprintf(c.out, "breakpoint %d at line %d\n", bp.ID, bp.Line)
+	}
+	return 0, false
+}
+
+func (c *Console) deleteCommand(d *Debugger, arg string) (Action, bool) {
+	id, err := strconv.Atoi(arg)
+	if err != nil || !d.ClearBreakpoint(id) {
+		fmt.Fprintf(c.out, "no breakpoint %q\n", arg)
+	}
+	return 0, false
+}
+
+func (c *Console) breakpointsCommand(d *Debugger, arg string) (Action, bool) {
+	bps := d.Breakpoints()
+	if len(bps) == 0 {
+		fmt.Fprintln(c.out, "no breakpoints")
+	}
+	for _, bp := range bps {
+		fmt.Fprintf(c.out, "%d: line %d", bp.ID, bp.Line)
+		if bp.Condition != "" {
+			fmt.Fprintf(c.out, " if %s", bp.Condition)
+		}
+		fmt.Fprintf(c.out, " (hits %d)\n", bp.Hits)
+	}
+	return 0, false
+}
+
+func (c *Console) backtraceCommand(d *Debugger, arg string) (Action, bool) {
+	for i, frame := range d.Stack() {
+		fmt.Fprintf(c.out, "#%d %s at line %d\n", i, frame.Name, frame.Line)
+	}
+	return 0, false
+}
+
+// envCommand 从内到外打印环境链中每一层定义的名字
+func (c *Console) envCommand(d *Debugger, arg string) (Action, bool) {
+	stack := d.Stack()
+	n := 0
+	if arg != "" {
+		var err error
+		if n, err = strconv.Atoi(arg); err != nil || n < 0 || n >= len(stack) {
+			fmt.Fprintf(c.out, "no frame %q\n", arg)
+			return 0, false
+		}
+	}
+	level := 0
+	for env := stack[n].Env; env != nil; env = env.Outer() {
+		name := fmt.Sprintf("scope %d", level)
+		if env.Outer() == nil {
+			name = "globals"
+		}
+		fmt.Fprintf(c.out, "%s:\n", name)
+		locals := env.Locals()
+		names := make([]string, 0, len(locals))
+		for name := range locals {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(c.out, "  %s = %s\n", name, Summary(locals[name]))
+		}
+		level++
+	}
+	return 0, false
+}
+
+func (c *Console) printCommand(d *Debugger, arg string) (Action, bool) {
+	if arg == "" {
+		fmt.Fprintln(c.out, "usage: print EXPR")
+		return 0, false
+	}
+	fmt.Fprintln(c.out, d.Evaluate(arg, 0).Inspect())
+	return 0, false
+}
+
+func (c *Console) listCommand(d *Debugger, arg string) (Action, bool) {
+	current := d.Stack()[0].Line
+	for line := current - 3; line <= current+3; line++ {
+		c.printLine(line, line == current)
+	}
+	return 0, false
+}
+
+func (c *Console) helpCommand(d *Debugger, arg string) (Action, bool) {
+	for _, cmd := range consoleCommands {
+		fmt.Fprintf(c.out, "  %-22s %-4s %s\n", cmd.usage, cmd.names[1], cmd.help)
+	}
+	return 0, false
+}
+
+// Summary 值的单行表示, 多行的值(例如函数)只显示第一行
+func Summary(obj object.Object) string {
+	s := obj.Inspect()
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i] + " ..."
+	}
+	return s
+}
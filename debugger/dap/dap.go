@@ -0,0 +1,569 @@
+// Package dap 通过 Debug Adapter Protocol 在标准输入输出上提供调试器, 编辑器可以用它调试 Monkey 程序
+// 只有一个线程(id 为 1); 被调试程序的输出通过 output 事件转发给编辑器
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/debugger"
+	"github.com/fanyeke/monkey/evaluator"
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/object"
+	"github.com/fanyeke/monkey/parser"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// THREAD_ID 被调试程序唯一的线程
+const THREAD_ID = 1
+
+// request 客户端发来的请求
+type request struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type response struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Command    string      `json:"command"`
+	Success    bool        `json:"success"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type event struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+type handler func(s *Server, args json.RawMessage) (interface{}, error)
+
+var handlers map[string]handler
+
+func init() {
+	handlers = map[string]handler{
+		"initialize":        (*Server).initialize,
+		"launch":            (*Server).launch,
+		"setBreakpoints":    (*Server).setBreakpoints,
+		"configurationDone": (*Server).configurationDone,
+		"threads":           (*Server).threads,
+		"stackTrace":        (*Server).stackTrace,
+		"scopes":            (*Server).scopes,
+		"variables":         (*Server).variables,
+		"evaluate":          (*Server).evaluate,
+		"continue":          resumeWith(debugger.Continue),
+		"next":              resumeWith(debugger.StepOver),
+		"stepIn":            resumeWith(debugger.StepIn),
+		"stepOut":           resumeWith(debugger.StepOut),
+		"pause":             (*Server).pause,
+	}
+}
+
+// Server 调试适配器, 在一个连接上调试一个程序
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	writeMu sync.Mutex
+	seq     int
+
+	dbg     *debugger.Debugger
+	program *ast.Program
+	env     *object.Environment
+	path    string
+
+	launched   bool
+	configured bool
+	done       chan struct{} // 程序结束时关闭, 程序还没有启动时为 nil
+	resume     chan debugger.Action
+	next       *debugger.Action // 响应当前请求之后程序继续执行的方式
+
+	mu          sync.Mutex
+	stopped     bool
+	terminating bool
+	handles     []interface{} // variablesReference - 1 对应的环境或复合值, 每次暂停时重置
+}
+
+// NewServer 创建在 in 和 out 上通信的调试适配器
+func NewServer(in io.Reader, out io.Writer) *Server {
+	s := &Server{in: bufio.NewReader(in), out: out, resume: make(chan debugger.Action)}
+	s.dbg = debugger.New(s.onStop)
+	return s
+}
+
+// Serve 在 in 和 out 上运行调试适配器, 直到客户端断开连接
+func Serve(in io.Reader, out io.Writer) error {
+	return NewServer(in, out).Run()
+}
+
+// Run 处理请求直到收到 disconnect 或 terminate 请求, 或者输入结束
+func (s *Server) Run() error {
+	for {
+		req, err := s.read()
+		if err == io.EOF {
+			s.terminate()
+			return nil
+		}
+		if err != nil {
+			s.terminate()
+			return err
+		}
+
+		if req.Command == "disconnect" || req.Command == "terminate" {
+			s.terminate()
+			s.respond(req, nil, nil)
+			return nil
+		}
+		h, ok := handlers[req.Command]
+		if !ok {
+			s.respond(req, nil, fmt.Errorf("unsupported request %q", req.Command))
+			continue
+		}
+		body, err := h(s, req.Arguments)
+		s.respond(req, body, err)
+		if s.next != nil {
+			action := *s.next
+			s.next = nil
+			s.resumeProgram(action)
+		}
+		// initialize 的响应发出之后才能发送 initialized 事件
+		if req.Command == "initialize" && err == nil {
+			s.send(&event{Type: "event", Event: "initialized"})
+		}
+		s.maybeStart()
+	}
+}
+
+func (s *Server) read() (*request, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+	req := &request{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// send 发送响应或事件, 自动填写 seq
+func (s *Server) send(msg interface{}) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.seq++
+	switch msg := msg.(type) {
+	case *response:
+		msg.Seq = s.seq
+	case *event:
+		msg.Seq = s.seq
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *Server) respond(req *request, body interface{}, err error) {
+	resp := &response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+	s.send(resp)
+}
+
+func (s *Server) emit(name string, body interface{}) {
+	s.send(&event{Type: "event", Event: name, Body: body})
+}
+
+func decode(args json.RawMessage, v interface{}) error {
+	if len(args) == 0 {
+		return nil
+	}
+	return json.Unmarshal(args, v)
+}
+
+func (s *Server) initialize(args json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"supportsConfigurationDoneRequest": true,
+		"supportsConditionalBreakpoints":   true,
+		"supportsEvaluateForHovers":        true,
+		"supportsTerminateRequest":         true,
+	}, nil
+}
+
+// launch 加载程序, 程序在 configurationDone 之后才开始运行
+func (s *Server) launch(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Program     string   `json:"program"`
+		Args        []string `json:"args"`
+		StopOnEntry bool     `json:"stopOnEntry"`
+	}
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if s.launched {
+		return nil, errors.New("a program is already launched")
+	}
+	if a.Program == "" {
+		return nil, errors.New("launch needs a program")
+	}
+	path, err := filepath.Abs(a.Program)
+	if err != nil {
+		return nil, err
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if errs := p.ParseErrors(); len(errs) != 0 {
+		return nil, fmt.Errorf("%s:%s: %s", a.Program, errs[0].Span.Start, errs[0].Message)
+	}
+
+	ctx := object.NewContext(strings.NewReader(""), &output{s, "stdout"}, &output{s, "stderr"})
+	env := object.NewEnvironment()
+	env.SetContext(ctx)
+	env.SetFile(path)
+	elements := make([]object.Object, len(a.Args))
+	for i, arg := range a.Args {
+		elements[i] = &object.String{Value: arg}
+	}
+	env.Set("ARGS", &object.Array{Elements: elements})
+
+	s.program, s.env, s.path = program, env, path
+	s.dbg.StopOnEntry = a.StopOnEntry
+	s.launched = true
+	return nil, nil
+}
+
+func (s *Server) configurationDone(args json.RawMessage) (interface{}, error) {
+	s.configured = true
+	return nil, nil
+}
+
+// maybeStart 程序已经加载并且客户端完成配置之后开始运行程序
+func (s *Server) maybeStart() {
+	if !s.launched || !s.configured || s.done != nil {
+		return
+	}
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		result := s.dbg.Run(s.program, s.env)
+		code := 0
+		if c, ok := evaluator.ExitCode(result); ok {
+			code = c
+		} else if errObj, ok := result.(*object.Error); ok {
+			s.emit("output", map[string]interface{}{"category": "stderr", "output": errObj.Inspect() + "\n"})
+			code = 1
+		}
+		s.emit("exited", map[string]interface{}{"exitCode": code})
+		s.emit("terminated", nil)
+	}()
+}
+
+// terminate 中止正在运行的程序并等待它结束
+func (s *Server) terminate() {
+	if s.done == nil {
+		return
+	}
+	s.mu.Lock()
+	s.terminating = true
+	stopped := s.stopped
+	s.mu.Unlock()
+	if stopped {
+		s.resumeProgram(debugger.Quit)
+	} else {
+		// 下一条语句之前暂停, onStop 发现正在终止后中止程序
+		s.dbg.Pause()
+	}
+	<-s.done
+}
+
+// onStop 程序暂停时在执行程序的 goroutine 中调用, 等待客户端的继续执行请求
+func (s *Server) onStop(d *debugger.Debugger, stop debugger.Stop) debugger.Action {
+	s.mu.Lock()
+	if s.terminating {
+		s.mu.Unlock()
+		return debugger.Quit
+	}
+	s.stopped = true
+	s.handles = nil
+	s.mu.Unlock()
+
+	body := map[string]interface{}{"reason": stop.Reason, "threadId": THREAD_ID, "allThreadsStopped": true}
+	if stop.Breakpoint != nil {
+		body["hitBreakpointIds"] = []int{stop.Breakpoint.ID}
+	}
+	s.emit("stopped", body)
+
+	return <-s.resume
+}
+
+// resumeProgram 让暂停的程序按 action 继续执行
+func (s *Server) resumeProgram(action debugger.Action) {
+	s.mu.Lock()
+	s.stopped = false
+	s.mu.Unlock()
+	s.resume <- action
+}
+
+// isStopped 查看程序状态的请求只能在程序暂停时处理
+func (s *Server) isStopped() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		return errors.New("the program is not stopped")
+	}
+	return nil
+}
+
+func resumeWith(action debugger.Action) handler {
+	return func(s *Server, args json.RawMessage) (interface{}, error) {
+		if err := s.isStopped(); err != nil {
+			return nil, err
+		}
+		// 先响应请求再继续执行, 这样响应之后才会出现下一次 stopped 事件
+		s.next = &action
+		if action == debugger.Continue {
+			return map[string]interface{}{"allThreadsContinued": true}, nil
+		}
+		return nil, nil
+	}
+}
+
+func (s *Server) pause(args json.RawMessage) (interface{}, error) {
+	s.dbg.Pause()
+	return nil, nil
+}
+
+func (s *Server) setBreakpoints(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Source struct {
+			Path string `json:"path"`
+		} `json:"source"`
+		Breakpoints []struct {
+			Line      int    `json:"line"`
+			Condition string `json:"condition"`
+		} `json:"breakpoints"`
+	}
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+
+	type breakpoint struct {
+		ID       int    `json:"id,omitempty"`
+		Verified bool   `json:"verified"`
+		Line     int    `json:"line"`
+		Message  string `json:"message,omitempty"`
+	}
+	result := []breakpoint{}
+	path, _ := filepath.Abs(a.Source.Path)
+	if s.path != "" && path != s.path {
+		// 只能在被调试的文件中设置断点
+		for _, bp := range a.Breakpoints {
+			result = append(result, breakpoint{Line: bp.Line, Message: "not the launched program"})
+		}
+		return map[string]interface{}{"breakpoints": result}, nil
+	}
+
+	s.dbg.ClearBreakpoints()
+	for _, bp := range a.Breakpoints {
+		set, err := s.dbg.SetBreakpoint(bp.Line, bp.Condition)
+		if err != nil {
+			result = append(result, breakpoint{Line: bp.Line, Message: err.Error()})
+			continue
+		}
+		result = append(result, breakpoint{ID: set.ID, Verified: true, Line: set.Line})
+	}
+	return map[string]interface{}{"breakpoints": result}, nil
+}
+
+func (s *Server) threads(args json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"threads": []map[string]interface{}{{"id": THREAD_ID, "name": "main"}},
+	}, nil
+}
+
+func (s *Server) stackTrace(args json.RawMessage) (interface{}, error) {
+	if err := s.isStopped(); err != nil {
+		return nil, err
+	}
+	source := map[string]interface{}{"name": filepath.Base(s.path), "path": s.path}
+	frames := []map[string]interface{}{}
+	for i, frame := range s.dbg.Stack() {
+		frames = append(frames, map[string]interface{}{
+			"id":     i,
+			"name":   frame.Name,
+			"line":   frame.Line,
+			"column": 1,
+			"source": source,
+		})
+	}
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+// handle 为环境或复合值分配 variablesReference, 0 表示没有子项
+func (s *Server) handle(v interface{}) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handles = append(s.handles, v)
+	return len(s.handles)
+}
+
+// scopes 帧的环境链, 从内到外每一层是一个作用域
+func (s *Server) scopes(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		FrameID int `json:"frameId"`
+	}
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if err := s.isStopped(); err != nil {
+		return nil, err
+	}
+	stack := s.dbg.Stack()
+	if a.FrameID < 0 || a.FrameID >= len(stack) {
+		return nil, fmt.Errorf("no frame %d", a.FrameID)
+	}
+
+	scopes := []map[string]interface{}{}
+	for env := stack[a.FrameID].Env; env != nil; env = env.Outer() {
+		name := "Closure"
+		switch {
+		case env.Outer() == nil:
+			name = "Globals"
+		case len(scopes) == 0:
+			name = "Locals"
+		}
+		scopes = append(scopes, map[string]interface{}{
+			"name":               name,
+			"variablesReference": s.handle(env),
+			"expensive":          false,
+		})
+	}
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+type variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+func (s *Server) variable(name string, obj object.Object) variable {
+	v := variable{Name: name, Value: debugger.Summary(obj), Type: string(obj.Type())}
+	switch obj := obj.(type) {
+	case *object.Array:
+		if len(obj.Elements) > 0 {
+			v.VariablesReference = s.handle(obj)
+		}
+	case *object.Hash:
+		if len(obj.Pairs) > 0 {
+			v.VariablesReference = s.handle(obj)
+		}
+	}
+	return v
+}
+
+func (s *Server) variables(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if err := s.isStopped(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if a.VariablesReference < 1 || a.VariablesReference > len(s.handles) {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("invalid variablesReference %d", a.VariablesReference)
+	}
+	container := s.handles[a.VariablesReference-1]
+	s.mu.Unlock()
+
+	vars := []variable{}
+	switch container := container.(type) {
+	case *object.Environment:
+		locals := container.Locals()
+		names := make([]string, 0, len(locals))
+		for name := range locals {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			vars = append(vars, s.variable(name, locals[name]))
+		}
+	case *object.Array:
+		for i, el := range container.Elements {
+			vars = append(vars, s.variable(fmt.Sprintf("[%d]", i), el))
+		}
+	case *object.Hash:
+		for _, pair := range container.Pairs {
+			vars = append(vars, s.variable(pair.Key.Inspect(), pair.Value))
+		}
+		sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	}
+	return map[string]interface{}{"variables": vars}, nil
+}
+
+func (s *Server) evaluate(args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Expression string `json:"expression"`
+		FrameID    int    `json:"frameId"`
+	}
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if err := s.isStopped(); err != nil {
+		return nil, err
+	}
+	result := s.dbg.Evaluate(a.Expression, a.FrameID)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errors.New(errObj.Message)
+	}
+	v := s.variable("", result)
+	return map[string]interface{}{"result": v.Value, "type": v.Type, "variablesReference": v.VariablesReference}, nil
+}
+
+// output 把被调试程序的输出转发为 output 事件
+type output struct {
+	s        *Server
+	category string
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.s.emit("output", map[string]interface{}{"category": o.category, "output": string(p)})
+	return len(p), nil
+}
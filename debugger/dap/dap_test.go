@@ -0,0 +1,292 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// message 客户端收到的响应或事件
+type message struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client 测试用的 DAP 客户端, 通过管道与同一进程中的调试适配器通信
+type client struct {
+	t        *testing.T
+	out      io.Writer
+	incoming chan *message
+	done     chan error
+	seq      int
+	// events 等待响应时收到的事件
+	events []*message
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{t: t, out: clientOut, incoming: make(chan *message, 64), done: make(chan error, 1)}
+	go func() {
+		err := Serve(serverIn, serverOut)
+		serverOut.Close()
+		c.done <- err
+	}()
+	go func() {
+		r := bufio.NewReader(clientIn)
+		for {
+			header, err := textproto.NewReader(r).ReadMIMEHeader()
+			if err != nil {
+				close(c.incoming)
+				return
+			}
+			length, _ := strconv.Atoi(header.Get("Content-Length"))
+			body := make([]byte, length)
+			if _, err := io.ReadFull(r, body); err != nil {
+				close(c.incoming)
+				return
+			}
+			msg := &message{}
+			if err := json.Unmarshal(body, msg); err != nil {
+				close(c.incoming)
+				return
+			}
+			c.incoming <- msg
+		}
+	}()
+	return c
+}
+
+// request 发送请求并等待响应, 成功时把 body 解析到 result 中
+func (c *client) request(command string, args interface{}, result interface{}) *message {
+	c.t.Helper()
+	c.seq++
+	data, err := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	fmt.Fprintf(c.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	for msg := range c.incoming {
+		if msg.Type == "event" {
+			c.events = append(c.events, msg)
+			continue
+		}
+		if msg.RequestSeq != c.seq || msg.Command != command {
+			c.t.Fatalf("response to %s #%d, want %s #%d", msg.Command, msg.RequestSeq, command, c.seq)
+		}
+		if msg.Success && result != nil && msg.Body != nil {
+			if err := json.Unmarshal(msg.Body, result); err != nil {
+				c.t.Fatalf("decoding %s body %s: %v", command, msg.Body, err)
+			}
+		}
+		return msg
+	}
+	c.t.Fatalf("connection closed while waiting for %s", command)
+	return nil
+}
+
+// event 等待名为 name 的事件, 先返回等待响应时已经收到的事件
+func (c *client) event(name string) *message {
+	c.t.Helper()
+	for len(c.events) > 0 {
+		msg := c.events[0]
+		c.events = c.events[1:]
+		if msg.Event == name {
+			return msg
+		}
+	}
+	for msg := range c.incoming {
+		if msg.Event == name {
+			return msg
+		}
+	}
+	c.t.Fatalf("connection closed while waiting for %s event", name)
+	return nil
+}
+
+// stopped 等待下一次暂停, 返回原因和最内层帧的名字与行号
+func (c *client) stopped() string {
+	c.t.Helper()
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	json.Unmarshal(c.event("stopped").Body, &body)
+	var trace struct {
+		StackFrames []struct {
+			Name string `json:"name"`
+			Line int    `json:"line"`
+		} `json:"stackFrames"`
+	}
+	c.request("stackTrace", map[string]int{"threadId": THREAD_ID}, &trace)
+	top := trace.StackFrames[0]
+	return fmt.Sprintf("%s %s:%d", body.Reason, top.Name, top.Line)
+}
+
+const testProgram = `let add = fn(a, b) {
+  let s = a + b;
+  s
+};
+let x = add(1, 2);
+let y = add(x, 10);
+puts([y]);`
+
+func launch(t *testing.T, c *client, stopOnEntry bool, breakpoints ...map[string]interface{}) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.mk")
+	if err := os.WriteFile(path, []byte(testProgram), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if resp := c.request("initialize", map[string]string{"adapterID": "monkey"}, nil); !resp.Success {
+		t.Fatalf("initialize failed: %s", resp.Message)
+	}
+	c.event("initialized")
+	if resp := c.request("launch", map[string]interface{}{"program": path, "stopOnEntry": stopOnEntry}, nil); !resp.Success {
+		t.Fatalf("launch failed: %s", resp.Message)
+	}
+	var set struct {
+		Breakpoints []struct {
+			Verified bool `json:"verified"`
+		} `json:"breakpoints"`
+	}
+	c.request("setBreakpoints", map[string]interface{}{"source": map[string]string{"path": path}, "breakpoints": breakpoints}, &set)
+	for i, bp := range set.Breakpoints {
+		if !bp.Verified {
+			t.Errorf("breakpoint %d not verified", i)
+		}
+	}
+	c.request("configurationDone", nil, nil)
+	return path
+}
+
+func TestSteppingAndBreakpoints(t *testing.T) {
+	c := newClient(t)
+	launch(t, c, true, map[string]interface{}{"line": 3, "condition": "a == 3"})
+
+	steps := []struct {
+		command  string
+		expected string
+	}{
+		{"", "entry <main>:1"},
+		{"next", "step <main>:5"},
+		{"stepIn", "step add:2"},
+		{"stepOut", "step <main>:6"},
+		{"continue", "breakpoint add:3"},
+	}
+	for _, step := range steps {
+		if step.command != "" {
+			if resp := c.request(step.command, map[string]int{"threadId": THREAD_ID}, nil); !resp.Success {
+				t.Fatalf("%s failed: %s", step.command, resp.Message)
+			}
+		}
+		if got := c.stopped(); got != step.expected {
+			t.Fatalf("after %q: expected %q, got %q", step.command, step.expected, got)
+		}
+	}
+
+	var evaluated struct {
+		Result string `json:"result"`
+	}
+	if resp := c.request("evaluate", map[string]interface{}{"expression": "s * 10", "frameId": 0}, &evaluated); !resp.Success || evaluated.Result != "130" {
+		t.Errorf("wrong evaluate result %q (%s)", evaluated.Result, resp.Message)
+	}
+
+	c.request("continue", map[string]int{"threadId": THREAD_ID}, nil)
+	output := c.event("output")
+	if !strings.Contains(string(output.Body), `"output":"[13]\n"`) {
+		t.Errorf("expected the program output, got %s", output.Body)
+	}
+	if exited := c.event("exited"); string(exited.Body) != `{"exitCode":0}` {
+		t.Errorf("wrong exited event %s", exited.Body)
+	}
+	c.event("terminated")
+	c.request("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("Serve returned %v", err)
+	}
+}
+
+func TestScopesAndVariables(t *testing.T) {
+	c := newClient(t)
+	launch(t, c, false, map[string]interface{}{"line": 2, "condition": "a == 3"})
+	if got := c.stopped(); got != "breakpoint add:2" {
+		t.Fatalf("expected to stop in add, got %q", got)
+	}
+
+	var scopes struct {
+		Scopes []struct {
+			Name               string `json:"name"`
+			VariablesReference int    `json:"variablesReference"`
+		} `json:"scopes"`
+	}
+	c.request("scopes", map[string]int{"frameId": 0}, &scopes)
+	var names []string
+	for _, s := range scopes.Scopes {
+		names = append(names, s.Name)
+	}
+	if strings.Join(names, ",") != "Locals,Globals" {
+		t.Fatalf("wrong scopes %v", names)
+	}
+
+	type variable struct {
+		Name               string `json:"name"`
+		Value              string `json:"value"`
+		VariablesReference int    `json:"variablesReference"`
+	}
+	var locals struct {
+		Variables []variable `json:"variables"`
+	}
+	c.request("variables", map[string]int{"variablesReference": scopes.Scopes[0].VariablesReference}, &locals)
+	if len(locals.Variables) != 2 || locals.Variables[0].Value != "3" || locals.Variables[1].Value != "10" {
+		t.Fatalf("wrong locals %+v", locals.Variables)
+	}
+
+	// 数组等复合值可以展开
+	var evaluated struct {
+		Result             string `json:"result"`
+		VariablesReference int    `json:"variablesReference"`
+	}
+	c.request("evaluate", map[string]interface{}{"expression": "[a, b]", "frameId": 0}, &evaluated)
+	var elements struct {
+		Variables []variable `json:"variables"`
+	}
+	c.request("variables", map[string]int{"variablesReference": evaluated.VariablesReference}, &elements)
+	if len(elements.Variables) != 2 || elements.Variables[1].Name != "[1]" || elements.Variables[1].Value != "10" {
+		t.Errorf("wrong array elements %+v", elements.Variables)
+	}
+
+	// 程序暂停时断开连接会中止程序
+	c.request("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("Serve returned %v", err)
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	c := newClient(t)
+	if resp := c.request("launch", map[string]string{"program": "/no/such/file.mk"}, nil); resp.Success {
+		t.Errorf("expected launch of a missing file to fail")
+	}
+	if resp := c.request("stackTrace", map[string]int{"threadId": THREAD_ID}, nil); resp.Success || resp.Message != "the program is not stopped" {
+		t.Errorf("expected stackTrace to fail, got %+v", resp)
+	}
+	if resp := c.request("restartFrame", nil, nil); resp.Success {
+		t.Errorf("expected an unsupported request to fail")
+	}
+	c.request("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("Serve returned %v", err)
+	}
+}
@@ -0,0 +1,330 @@
+// Package debugger 在求值器的 Hook 上实现调试器: 行断点、条件断点、单步执行(step in/over/out)、
+// 查看调用栈和环境链。调试器本身不做输入输出, 由前端(命令行 Console 或 DAP 服务器)决定暂停后怎样继续
+package debugger
+
+import (
+	"fmt"
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/evaluator"
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/object"
+	"github.com/fanyeke/monkey/parser"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Action 程序暂停之后前端选择的继续方式
+type Action int
+
+const (
+	Continue Action = iota // 运行到下一个断点
+	StepIn                 // 执行到下一行, 会进入被调用的函数
+	StepOver               // 执行到当前函数的下一行, 不进入被调用的函数
+	StepOut                // 执行到当前函数返回调用方之后
+	Quit                   // 中止程序
+)
+
+// 暂停的原因
+const (
+	ReasonEntry      = "entry"
+	ReasonBreakpoint = "breakpoint"
+	ReasonStep       = "step"
+	ReasonPause      = "pause"
+)
+
+// Stop 描述一次暂停, 程序停在 Statement 执行之前
+type Stop struct {
+	Reason     string
+	Line       int
+	Statement  ast.Statement
+	Breakpoint *Breakpoint // 因断点暂停时命中的断点
+}
+
+// Handler 程序暂停时调用, 返回后程序按返回的 Action 继续执行
+// Handler 在执行程序的 goroutine 中调用, 返回之前可以调用 Stack、Evaluate 等方法查看程序状态
+type Handler func(d *Debugger, stop Stop) Action
+
+// Breakpoint 行断点, Condition 不为空时只有条件为真才暂停
+type Breakpoint struct {
+	ID        int
+	Line      int
+	Condition string
+	Hits      int // 暂停的次数
+
+	condition ast.Expression
+}
+
+// Frame 调用栈中的一帧
+type Frame struct {
+	Name     string           // 函数名, 匿名函数为 "<anonymous>", 最外层为 "<main>"
+	Function *object.Function // 最外层为 nil
+	Env      *object.Environment
+	Line     int // 正在执行的语句所在的行, 还没有执行语句时为 0
+}
+
+// Debugger 调试器, 同一时间只能调试一个程序
+type Debugger struct {
+	handler Handler
+	// StopOnEntry 为 true 时在第一条语句之前暂停
+	StopOnEntry bool
+
+	mu          sync.Mutex
+	breakpoints []*Breakpoint
+	nextID      int
+
+	file      string   // 被调试的源文件, 只在这个文件的语句上暂停
+	frames    []*Frame // frames[0] 是最外层
+	action    Action
+	depth     int  // 开始单步时调用栈的深度
+	suspended bool // 调试器自己求值表达式时忽略 Hook 回调
+	started   bool
+	pause     int32 // 由其他 goroutine 请求暂停
+}
+
+// New 创建调试器, 程序暂停时调用 handler
+func New(handler Handler) *Debugger {
+	return &Debugger{handler: handler, nextID: 1}
+}
+
+// SetBreakpoint 在 line 行设置断点, condition 为空表示无条件断点
+func (d *Debugger) SetBreakpoint(line int, condition string) (*Breakpoint, error) {
+	if line < 1 {
+		return nil, fmt.Errorf("invalid line %d", line)
+	}
+	bp := &Breakpoint{Line: line, Condition: strings.TrimSpace(condition)}
+	if bp.Condition != "" {
+		expr, err := parseExpression(bp.Condition)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition %q: %s", bp.Condition, err)
+		}
+		bp.condition = expr
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	bp.ID = d.nextID
+	d.nextID++
+	d.breakpoints = append(d.breakpoints, bp)
+	return bp, nil
+}
+
+// ClearBreakpoint 删除编号为 id 的断点, 断点不存在时返回 false
+func (d *Debugger) ClearBreakpoint(id int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, bp := range d.breakpoints {
+		if bp.ID == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ClearBreakpoints 删除所有断点
+func (d *Debugger) ClearBreakpoints() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints = nil
+}
+
+// Breakpoints 返回所有断点, 按行号排序
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	bps := append([]*Breakpoint(nil), d.breakpoints...)
+	sort.SliceStable(bps, func(i, j int) bool { return bps[i].Line < bps[j].Line })
+	return bps
+}
+
+// Pause 请求在下一条语句之前暂停, 可以在其他 goroutine 中调用
+func (d *Debugger) Pause() {
+	atomic.StoreInt32(&d.pause, 1)
+}
+
+// Run 在调试器的控制下求值 program, env 的 Context 上会临时安装调试器的 Hook
+func (d *Debugger) Run(program *ast.Program, env *object.Environment) object.Object {
+	ctx := env.Context()
+	previous := ctx.Hook
+	ctx.Hook = d
+	defer func() { ctx.Hook = previous }()
+
+	d.file = env.File()
+	d.frames = []*Frame{{Name: "<main>", Env: env}}
+	d.action = Continue
+	d.started = false
+	return evaluator.Eval(program, env)
+}
+
+// Stack 返回调用栈, 第一个元素是最内层的帧; 只应在程序暂停时调用
+func (d *Debugger) Stack() []Frame {
+	stack := make([]Frame, 0, len(d.frames))
+	for i := len(d.frames) - 1; i >= 0; i-- {
+		stack = append(stack, *d.frames[i])
+	}
+	return stack
+}
+
+// Evaluate 在调用栈第 frame 帧(0 为最内层)的环境中求值表达式, 求值期间不会触发断点; 只应在程序暂停时调用
+func (d *Debugger) Evaluate(expr string, frame int) object.Object {
+	if frame < 0 || frame >= len(d.frames) {
+		return &object.Error{Message: fmt.Sprintf("no frame %d", frame), Kind: "RuntimeError"}
+	}
+	node, err := parseExpression(expr)
+	if err != nil {
+		return &object.Error{Message: err.Error(), Kind: "SyntaxError"}
+	}
+	return d.eval(node, d.frames[len(d.frames)-1-frame].Env)
+}
+
+func (d *Debugger) eval(node ast.Node, env *object.Environment) object.Object {
+	d.suspended = true
+	defer func() { d.suspended = false }()
+	result := evaluator.Eval(node, env)
+	if result == nil {
+		return evaluator.NULL
+	}
+	return result
+}
+
+// Statement 实现 object.Hook, 决定是否在语句之前暂停
+func (d *Debugger) Statement(stmt ast.Statement, env *object.Environment) *object.Error {
+	if d.action == Quit {
+		// 中止后 finally 中的语句仍会执行, 不再暂停
+		return evaluator.NewExit(1)
+	}
+	if d.suspended || env.File() != d.file {
+		return nil
+	}
+	frame := d.frames[len(d.frames)-1]
+	line := lineOf(stmt)
+	// 同一行上的多条语句(例如 if 和它的代码块)只暂停一次
+	newLine := line != frame.Line
+	frame.Env = env
+	frame.Line = line
+
+	stop := Stop{Line: line, Statement: stmt}
+	switch {
+	case !d.started:
+		d.started = true
+		if d.StopOnEntry {
+			stop.Reason = ReasonEntry
+		}
+	case atomic.CompareAndSwapInt32(&d.pause, 1, 0):
+		stop.Reason = ReasonPause
+	case d.action == StepIn && newLine,
+		d.action == StepOver && newLine && len(d.frames) <= d.depth,
+		d.action == StepOut && len(d.frames) < d.depth:
+		stop.Reason = ReasonStep
+	}
+	if stop.Reason == "" && newLine {
+		if bp := d.hitBreakpoint(line, env); bp != nil {
+			stop.Reason = ReasonBreakpoint
+			stop.Breakpoint = bp
+		}
+	}
+	if stop.Reason == "" {
+		return nil
+	}
+
+	d.action = d.handler(d, stop)
+	d.depth = len(d.frames)
+	if d.action == Quit {
+		return evaluator.NewExit(1)
+	}
+	return nil
+}
+
+// hitBreakpoint 返回 line 行上条件成立的断点, 条件求值出错时也会暂停
+func (d *Debugger) hitBreakpoint(line int, env *object.Environment) *Breakpoint {
+	d.mu.Lock()
+	var candidates []*Breakpoint
+	for _, bp := range d.breakpoints {
+		if bp.Line == line {
+			candidates = append(candidates, bp)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, bp := range candidates {
+		if bp.condition != nil {
+			result := d.eval(bp.condition, env)
+			if _, isErr := result.(*object.Error); !isErr && !truthy(result) {
+				continue
+			}
+		}
+		bp.Hits++
+		return bp
+	}
+	return nil
+}
+
+// Call 实现 object.Hook, 进入函数时压入新的一帧
+func (d *Debugger) Call(fn *object.Function, env *object.Environment) *object.Error {
+	if d.suspended {
+		return nil
+	}
+	name := fn.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	d.frames = append(d.frames, &Frame{Name: name, Function: fn, Env: env})
+	return nil
+}
+
+// Return 实现 object.Hook, 函数返回时弹出一帧
+func (d *Debugger) Return(fn *object.Function, result object.Object) {
+	if d.suspended || len(d.frames) <= 1 {
+		return
+	}
+	d.frames = d.frames[:len(d.frames)-1]
+}
+
+// lineOf 返回语句第一个词法单元所在的行
+func lineOf(stmt ast.Statement) int {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token.Pos.Line
+	case *ast.ReturnStatement:
+		return stmt.Token.Pos.Line
+	case *ast.ExpressionStatement:
+		return stmt.Token.Pos.Line
+	case *ast.ThrowStatement:
+		return stmt.Token.Pos.Line
+	case *ast.ImportStatement:
+		return stmt.Token.Pos.Line
+	case *ast.ExportStatement:
+		return stmt.Token.Pos.Line
+	case *ast.BlockStatement:
+		return stmt.Token.Pos.Line
+	}
+	return 0
+}
+
+// truthy 与求值器的规则相同: 只有 false 和 null 为假
+func truthy(obj object.Object) bool {
+	switch obj {
+	case evaluator.FALSE, evaluator.NULL:
+		return false
+	}
+	return true
+}
+
+// parseExpression 把断点条件或要查看的表达式解析为一个表达式
+func parseExpression(src string) (ast.Expression, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		return nil, fmt.Errorf("%s", errs[0])
+	}
+	if len(program.Statements) != 1 {
+		return nil, fmt.Errorf("expected a single expression")
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok || stmt.Expression == nil {
+		return nil, fmt.Errorf("expected a single expression")
+	}
+	return stmt.Expression, nil
+}
@@ -0,0 +1,157 @@
+package debugger
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/object"
+	"github.com/fanyeke/monkey/parser"
+	"strings"
+	"testing"
+)
+
+const testProgram = `let add = fn(a, b) {
+  let s = a + b;
+  s
+};
+let x = add(1, 2);
+let y = add(x, 10);
+puts(y);`
+
+// run 调试 testProgram, 每次暂停时记录 "原因 行号 栈" 并按 actions 依次继续, actions 用完后继续运行到结束
+func run(t *testing.T, d *Debugger, actions ...Action) ([]string, object.Object) {
+	t.Helper()
+	var stops []string
+	d.handler = func(d *Debugger, stop Stop) Action {
+		var names []string
+		for _, frame := range d.Stack() {
+			names = append(names, frame.Name)
+		}
+		stops = append(stops, fmt.Sprintf("%s %d %s", stop.Reason, stop.Line, strings.Join(names, "<")))
+		if len(actions) == 0 {
+			return Continue
+		}
+		action := actions[0]
+		actions = actions[1:]
+		return action
+	}
+	env := object.NewEnvironment()
+	env.SetContext(object.NewContext(strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{}))
+	return stops, d.Run(parser.New(lexer.New(testProgram)).ParseProgram(), env)
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		name        string
+		breakpoints map[int]string
+		actions     []Action
+		expected    []string
+	}{
+		{
+			"step over",
+			nil,
+			[]Action{StepOver, StepOver, StepOver},
+			[]string{"entry 1 <main>", "step 5 <main>", "step 6 <main>", "step 7 <main>"},
+		},
+		{
+			"step in and out",
+			nil,
+			[]Action{StepOver, StepIn, StepIn, StepOut},
+			[]string{"entry 1 <main>", "step 5 <main>", "step 2 add<<main>", "step 3 add<<main>", "step 6 <main>"},
+		},
+		{
+			"breakpoints",
+			map[int]string{3: ""},
+			nil,
+			[]string{"entry 1 <main>", "breakpoint 3 add<<main>", "breakpoint 3 add<<main>"},
+		},
+		{
+			"conditional breakpoint",
+			map[int]string{2: "a > 1"},
+			nil,
+			[]string{"entry 1 <main>", "breakpoint 2 add<<main>"},
+		},
+		{
+			"quit",
+			map[int]string{2: ""},
+			[]Action{Continue, Quit},
+			[]string{"entry 1 <main>", "breakpoint 2 add<<main>"},
+		},
+	}
+
+	for _, tt := range tests {
+		d := New(nil)
+		d.StopOnEntry = true
+		for line, condition := range tt.breakpoints {
+			if _, err := d.SetBreakpoint(line, condition); err != nil {
+				t.Fatalf("%s: SetBreakpoint failed: %s", tt.name, err)
+			}
+		}
+		stops, _ := run(t, d, tt.actions...)
+		if strings.Join(stops, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s: wrong stops.\nexpected=%q\ngot=%q", tt.name, tt.expected, stops)
+		}
+	}
+}
+
+func TestQuitExitsProgram(t *testing.T) {
+	d := New(nil)
+	d.StopOnEntry = true
+	_, result := run(t, d, Quit)
+	if result.Inspect() != "ERROR:exit(1)" {
+		t.Errorf("expected the program to exit, got=%q", result.Inspect())
+	}
+}
+
+func TestInvalidBreakpoints(t *testing.T) {
+	d := New(nil)
+	if _, err := d.SetBreakpoint(0, ""); err == nil {
+		t.Errorf("expected an error for line 0")
+	}
+	if _, err := d.SetBreakpoint(1, "a +"); err == nil {
+		t.Errorf("expected an error for an invalid condition")
+	}
+	bp, _ := d.SetBreakpoint(3, "")
+	if !d.ClearBreakpoint(bp.ID) || d.ClearBreakpoint(bp.ID) {
+		t.Errorf("ClearBreakpoint should succeed exactly once")
+	}
+}
+
+func TestConsole(t *testing.T) {
+	commands := "b 2 if a > 1\nbl\nc\nbt\nenv\np a * 100\nfinish\n\n"
+	var out bytes.Buffer
+	console := NewConsole(bufio.NewReader(strings.NewReader(commands)), &out, testProgram)
+	d := New(console.Handle)
+	d.StopOnEntry = true
+
+	env := object.NewEnvironment()
+	env.SetContext(object.NewContext(strings.NewReader(""), &out, &out))
+	d.Run(parser.New(lexer.New(testProgram)).ParseProgram(), env)
+
+	expected := []string{
+		"stopped at line 1 (entry)",
+		"=>    1  let add = fn(a, b) {",
+		"(mdb) breakpoint 1 at line 2 if a > 1",
+		"(mdb) 1: line 2 if a > 1 (hits 0)",
+		"(mdb) breakpoint 1 hit at line 2",
+		"=>    2    let s = a + b;",
+		"(mdb) #0 add at line 2",
+		"#1 <main> at line 6",
+		"(mdb) scope 0:",
+		"  a = 3",
+		"  b = 10",
+		"globals:",
+		"  add = fn(a, b) { ...",
+		"  x = 3",
+		"(mdb) 300",
+		"(mdb) stopped at line 7 (step)",
+		"=>    7  puts(y);",
+		// 空行重复上一条命令 finish, 最外层的 finish 运行到程序结束
+		"(mdb) 13",
+		"",
+	}
+	if out.String() != strings.Join(expected, "\n") {
+		t.Errorf("wrong console output.\nexpected=%q\ngot=%q", strings.Join(expected, "\n"), out.String())
+	}
+}
@@ -0,0 +1,129 @@
+package evaluator
+
+import (
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/object"
+)
+
+// convertType 判断值是否符合类型注解, 符合时返回绑定用的值: 用在 float 位置的整数转换为浮点数,
+// 数组和哈希中有元素被转换时返回新的数组或哈希, 不修改原来的值
+// any 符合任何值, 函数类型只检查值是不是函数; 空函数体的结果 nil 视为 null
+func convertType(t ast.TypeExpr, value object.Object) (object.Object, bool) {
+	if value == nil {
+		value = NULL
+	}
+	switch t := t.(type) {
+	case nil:
+		return value, true
+	case *ast.NamedType:
+		switch t.Name {
+		case "any":
+			return value, true
+		case "int":
+			return value, value.Type() == object.INTEGER_OBJ
+		case "float":
+			if i, ok := value.(*object.Integer); ok {
+				return &object.Float{Value: float64(i.Value)}, true
+			}
+			return value, value.Type() == object.FLOAT_OBJ
+		case "string":
+			return value, value.Type() == object.STRING_OBJ
+		case "bool":
+			return value, value.Type() == object.BOOLEAN_OBJ
+		case "null":
+			return value, value.Type() == object.NULL_OBJ
+		case "regex":
+			return value, value.Type() == object.REGEX_OBJ
+		}
+	case *ast.ArrayType:
+		arr, ok := value.(*object.Array)
+		if !ok {
+			return value, false
+		}
+		var elements []object.Object
+		for i, el := range arr.Elements {
+			converted, ok := convertType(t.Element, el)
+			if !ok {
+				return value, false
+			}
+			if converted != el && elements == nil {
+				elements = make([]object.Object, len(arr.Elements))
+				copy(elements, arr.Elements)
+			}
+			if elements != nil {
+				elements[i] = converted
+			}
+		}
+		if elements == nil {
+			return value, true
+		}
+		return &object.Array{Elements: elements}, true
+	case *ast.HashType:
+		hash, ok := value.(*object.Hash)
+		if !ok {
+			return value, false
+		}
+		var pairs map[object.HashKey]object.HashPair
+		for key, pair := range hash.Pairs {
+			_, keyOk := convertType(t.Key, pair.Key)
+			converted, valueOk := convertType(t.Value, pair.Value)
+			if !keyOk || !valueOk {
+				return value, false
+			}
+			if converted != pair.Value && pairs == nil {
+				pairs = make(map[object.HashKey]object.HashPair, len(hash.Pairs))
+				for k, p := range hash.Pairs {
+					pairs[k] = p
+				}
+			}
+			if pairs != nil {
+				pairs[key] = object.HashPair{Key: pair.Key, Value: converted}
+			}
+		}
+		if pairs == nil {
+			return value, true
+		}
+		return &object.Hash{Pairs: pairs}, true
+	case *ast.FunctionType:
+		return value, value.Type() == object.FUNCTION_OBJ || value.Type() == object.BUILTIN_OBJ
+	}
+	return value, false
+}
+
+// checkParameterType 检查传给参数 name 的值是否符合它的类型注解并返回转换后的值, 没有注解时总是符合
+func checkParameterType(fn *object.Function, name string, t ast.TypeExpr, value object.Object) (object.Object, *object.Error) {
+	if converted, ok := convertType(t, value); ok {
+		return converted, nil
+	}
+	return nil, newTypeError("%s: type mismatch for parameter %s: expected %s, got %s", functionName(fn), name, t.String(), value.Type())
+}
+
+// checkReturnTypes 检查函数的结果是否符合 fns 中每个函数的返回值类型注解并返回转换后的结果, 结果是错误时原样返回
+func checkReturnTypes(fns []*object.Function, result object.Object) object.Object {
+	if isError(result) {
+		return result
+	}
+	for _, fn := range fns {
+		converted, ok := convertType(fn.ReturnType, result)
+		if !ok {
+			got := object.ObjectType(object.NULL_OBJ)
+			if result != nil {
+				got = result.Type()
+			}
+			return newTypeError("%s: type mismatch for return value: expected %s, got %s", functionName(fn), fn.ReturnType.String(), got)
+		}
+		if fn.ReturnType != nil && result != nil {
+			result = converted
+		}
+	}
+	return result
+}
+
+func containsFunction(fns []*object.Function, fn *object.Function) bool {
+	for _, f := range fns {
+		if f == fn {
+			return true
+		}
+	}
+	return false
+}
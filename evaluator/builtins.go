@@ -3,29 +3,36 @@ package evaluator
 import (
 	"fmt"
 	"github.com/fanyeke/monkey/object"
+	"io"
+	"sort"
+	"strings"
 )
 
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments, got=%d, want=1", len(args))
+				return newArgumentError("wrong number of arguments, got=%d, want=1", len(args))
 			}
 			switch arg := args[0].(type) {
 			case *object.String:
 				return &object.Integer{Value: int64(len(arg.Value))}
 			default:
-				return newError("argument to `len` not supported, got %s", args[0].Type())
+				return newTypeError("argument to `len` not supported, got %s", args[0].Type())
 			}
 		},
 	},
 	"first": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newArgumentError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+				return newTypeError("argument to `first` must be ARRAY, got %s", args[0].Type())
 			}
 			arr := args[0].(*object.Array)
 			if len(arr.Elements) > 0 {
@@ -35,12 +42,14 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"rest": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newArgumentError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to rest must be ARRAY, got %s", args[0].Type())
+				return newTypeError("argument to rest must be ARRAY, got %s", args[0].Type())
 			}
 			arr := args[0].(*object.Array)
 			length := len(arr.Elements)
@@ -53,12 +62,14 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"push": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		MinArgs: 2,
+		MaxArgs: 2,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=2", len(args))
+				return newArgumentError("wrong number of arguments. got=%d, want=2", len(args))
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to plus must be ARRAY, got %s", args[0].Type())
+				return newTypeError("argument to plus must be ARRAY, got %s", args[0].Type())
 			}
 			arr := args[0].(*object.Array)
 			length := len(arr.Elements)
@@ -70,11 +81,113 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"puts": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		MinArgs: 0,
+		MaxArgs: -1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			out := env.Context().Out
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(out, arg.Inspect())
 			}
 			return NULL
 		},
 	},
+	"print": &object.Builtin{
+		MinArgs: 0,
+		MaxArgs: -1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			// 与 puts 不同, print 用空格连接参数并且不换行
+			fmt.Fprint(env.Context().Out, inspectJoin(args, " "))
+			return NULL
+		},
+	},
+	"eprint": &object.Builtin{
+		MinArgs: 0,
+		MaxArgs: -1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			err := env.Context().Err
+			for _, arg := range args {
+				fmt.Fprintln(err, arg.Inspect())
+			}
+			return NULL
+		},
+	},
+	"input": &object.Builtin{
+		MinArgs: 0,
+		MaxArgs: 1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newArgumentError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+			}
+			ctx := env.Context()
+			if len(args) == 1 {
+				fmt.Fprint(ctx.Out, args[0].Inspect())
+			}
+			line, err := ctx.In.ReadString('\n')
+			// 输入结束并且没有读到内容时返回 null
+			if err != nil && line == "" {
+				if err == io.EOF {
+					return NULL
+				}
+				return newError("input: %s", err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			line = strings.TrimSuffix(line, "\r")
+			return &object.String{Value: line}
+		},
+	},
+	// exit(code) 结束整个程序, 默认退出码为 0
+	"exit": &object.Builtin{
+		MinArgs: 0,
+		MaxArgs: 1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			code := &object.Integer{Value: 0}
+			switch len(args) {
+			case 0:
+			case 1:
+				c, ok := args[0].(*object.Integer)
+				if !ok {
+					return newTypeError("argument to `exit` must be INTEGER, got %s", args[0].Type())
+				}
+				code = c
+			default:
+				return newArgumentError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+			}
+			return NewExit(code.Value)
+		},
+	},
+}
+
+// BuiltinNames 返回所有内置函数的名字, 按字母顺序排列
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuiltinArity 返回内置函数接受的参数个数, max 为 -1 表示没有上限
+func BuiltinArity(name string) (min, max int, ok bool) {
+	builtin, ok := builtins[name]
+	if !ok {
+		return 0, 0, false
+	}
+	return builtin.MinArgs, builtin.MaxArgs, true
+}
+
+// registerBuiltins 把一组内置函数合并到 builtins 中, 各组内置函数在自己的文件中通过 init 注册
+func registerBuiltins(group map[string]*object.Builtin) {
+	for name, builtin := range group {
+		builtins[name] = builtin
+	}
+}
+
+// inspectJoin 用 sep 连接每个对象的 Inspect 结果
+func inspectJoin(args []object.Object, sep string) string {
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		parts = append(parts, arg.Inspect())
+	}
+	return strings.Join(parts, sep)
 }
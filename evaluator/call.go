@@ -0,0 +1,86 @@
+package evaluator
+
+import (
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/object"
+)
+
+// prepareCall 求值调用表达式中的函数和参数, 但不执行调用
+func prepareCall(node *ast.CallExpression, env *object.Environment) (object.Object, []object.Object, map[string]object.Object, *object.Error) {
+	// obj.f(args) 需要先查找成员, 找不到时按 f(obj, args) 调用
+	if member, ok := node.Function.(*ast.MemberExpression); ok {
+		return prepareMethodCall(member, node.Arguments, env)
+	}
+	function := Eval(node.Function, env)
+	if errObj, ok := function.(*object.Error); ok {
+		return nil, nil, nil, errObj
+	}
+	args, named, errObj := evalArguments(node.Arguments, env)
+	if errObj != nil {
+		return nil, nil, nil, errObj
+	}
+	return function, args, named, nil
+}
+
+// evalArguments 按顺序求值调用参数, 位置参数和具名参数分开返回
+func evalArguments(exps []ast.Expression, env *object.Environment) ([]object.Object, map[string]object.Object, *object.Error) {
+	var args []object.Object
+	var named map[string]object.Object
+	for _, e := range exps {
+		if arg, ok := e.(*ast.NamedArgument); ok {
+			value := Eval(arg.Value, env)
+			if errObj, ok := value.(*object.Error); ok {
+				return nil, nil, errObj
+			}
+			if named == nil {
+				named = make(map[string]object.Object)
+			}
+			if _, dup := named[arg.Name.Value]; dup {
+				return nil, nil, newArgumentError("named argument %s given more than once", arg.Name.Value)
+			}
+			named[arg.Name.Value] = value
+			continue
+		}
+		value := Eval(e, env)
+		if errObj, ok := value.(*object.Error); ok {
+			return nil, nil, errObj
+		}
+		args = append(args, value)
+	}
+	return args, named, nil
+}
+
+// functionName 用于错误信息的函数名
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "anonymous function"
+	}
+	return fn.Name
+}
+
+// parameterName 返回可以通过具名参数传入的参数名, 解构参数没有名字, 返回空字符串
+func parameterName(param ast.Pattern) string {
+	switch param := param.(type) {
+	case *ast.Identifier:
+		return param.Value
+	case *ast.DefaultPattern:
+		return parameterName(param.Target)
+	case *ast.TypedPattern:
+		return parameterName(param.Target)
+	}
+	return ""
+}
+
+func isDefaultPattern(param ast.Pattern) bool {
+	_, ok := param.(*ast.DefaultPattern)
+	return ok
+}
+
+func hasParameter(params []ast.Pattern, name string) bool {
+	for _, param := range params {
+		if parameterName(param) == name {
+			return true
+		}
+	}
+	return false
+}
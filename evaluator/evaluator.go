@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/fanyeke/monkey/ast"
 	"github.com/fanyeke/monkey/object"
+	"math"
 )
 
 var (
@@ -27,6 +28,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value} // 整数字面量
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value} // 浮点数字面量
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value) // 布尔字面量
 	case *ast.PrefixExpression: // 前缀表达式
@@ -60,23 +63,33 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
+		if node.Type != nil {
+			converted, ok := convertType(node.Type, val)
+			if !ok {
+				return newTypeError("type mismatch for %s: expected %s, got %s", node.Target().String(), node.Type.String(), val.Type())
+			}
+			val = converted
+		}
+		if node.Pattern != nil {
+			// 解构赋值, 值的结构与模式不符时报错
+			if errObj := destructure(node.Pattern, val, env); errObj != nil {
+				return errObj
+			}
+			return nil
+		}
 		env.Set(node.Name.Value, val)
 	case *ast.Identifier:
 		return evalIdentifier(node, env) // 标识符
 	case *ast.FunctionLiteral: // 函数字面值
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Name: node.Name, Parameters: params, ReturnType: node.ReturnType, Env: env, Body: body}
 	case *ast.CallExpression: // 调用表达式
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
-		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+		function, args, named, errObj := prepareCall(node, env)
+		if errObj != nil {
+			return errObj
 		}
-		return applyFunction(function, args)
+		return applyFunction(function, args, named, env)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 	case *ast.ArrayLiteral:
@@ -95,8 +108,32 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	case *ast.ThrowStatement:
+		return evalThrowStatement(node, env)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+	case *ast.ExportStatement:
+		return Eval(node.Statement, env)
+	case *ast.MemberExpression:
+		obj := Eval(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		return evalMemberExpression(obj, node.Member.Value)
+	case *ast.RegexLiteral:
+		re, err := compileRegex(regexPattern(node.Pattern, node.Flags))
+		if err != nil {
+			return newError("invalid regex %s: %s", node.String(), err)
+		}
+		return re
 	}
 	return nil
 }
@@ -110,7 +147,7 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 		}
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newTypeError("unusable as hash key: %s", key.Type())
 		}
 		value := Eval(valueNode, env)
 		if isError(value) {
@@ -127,10 +164,14 @@ func evalIndexExpression(left object.Object, index object.Object) object.Object
 	// 如果当前token的左部是数组, 右部是整数, 那么这就是一个数组求值
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.MODULE_OBJ && index.Type() == object.STRING_OBJ:
+		return evalModuleIndexExpression(left, index)
 	default:
-		return newError("index operation not supported: %s", left.Type())
+		return newTypeError("index operation not supported: %s", left.Type())
 	}
 }
 
@@ -139,7 +180,7 @@ func evalHashIndexExpression(hash object.Object, index object.Object) object.Obj
 
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newTypeError("unusable as hash key: %s", index.Type())
 	}
 	pair, ok := hashObject.Pairs[key.HashKey()]
 	if !ok {
@@ -148,22 +189,45 @@ func evalHashIndexExpression(hash object.Object, index object.Object) object.Obj
 	return pair.Value
 }
 
-// evalArrayIndexExpression 数组表达式求值
+// evalArrayIndexExpression 数组表达式求值, 负数索引从末尾开始计数
 func evalArrayIndexExpression(array object.Object, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
-	if idx < 0 || idx > max {
+	idx, ok := normalizeIndex(index.(*object.Integer).Value, len(arrayObject.Elements))
+	if !ok {
 		return NULL
 	}
 	return arrayObject.Elements[idx]
 }
 
+// evalStringIndexExpression 字符串索引, 返回对应位置的单个字符
+func evalStringIndexExpression(str object.Object, index object.Object) object.Object {
+	value := str.(*object.String).Value
+	idx, ok := normalizeIndex(index.(*object.Integer).Value, len(value))
+	if !ok {
+		return NULL
+	}
+	return &object.String{Value: value[idx : idx+1]}
+}
+
+// normalizeIndex 把负数索引转换为从头开始的索引, 第二个返回值表示索引是否越界
+func normalizeIndex(idx int64, length int) (int64, bool) {
+	if idx < 0 {
+		idx += int64(length)
+	}
+	if idx < 0 || idx >= int64(length) {
+		return 0, false
+	}
+	return idx, true
+}
+
 // evalProgram 解析语句, 本质是沿着ast树往下递归
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 	// 解析语句中的每一项
 	for _, statement := range program.Statements {
+		if errObj := beforeStatement(statement, env); errObj != nil {
+			return errObj
+		}
 		result = Eval(statement, env)
 		// 如果断言成功, 则表示
 		switch result := result.(type) {
@@ -193,7 +257,7 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case "-":
 		return valMinusPrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newTypeError("unknown operator: %s%s", operator, right.Type())
 	}
 }
 
@@ -213,9 +277,12 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 
 // valMinusPrefixOperatorExpression 前缀为"-"的情况
 func valMinusPrefixOperatorExpression(right object.Object) object.Object {
-	// 前缀为"-"时, 右部只能是整数, 若不是则返回错误
+	// 前缀为"-"时, 右部只能是整数或浮点数, 若不是则返回错误
+	if right.Type() == object.FLOAT_OBJ {
+		return &object.Float{Value: -right.(*object.Float).Value}
+	}
 	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+		return newTypeError("unknown operator: -%s", right.Type())
 	}
 	value := right.(*object.Integer).Value
 	return &object.Integer{
@@ -229,6 +296,9 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 	// 左右部分都是整数
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	// 有一边是浮点数时, 整数提升为浮点数计算
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, toFloat(left), toFloat(right))
 	// 符号是"==" ro "!="
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
@@ -236,11 +306,11 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 		return nativeBoolToBooleanObject(left != right)
 	// 错误处理
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newTypeError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newTypeError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
@@ -257,7 +327,41 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newZeroDivisionError()
+		}
 		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newZeroDivisionError()
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newTypeError("unknown operator:%s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalFloatInfixExpression 浮点数的运算规则
+func evalFloatInfixExpression(operator string, leftVal float64, rightVal float64) object.Object {
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
@@ -267,7 +371,7 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	case "!=":
 		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
-		return newError("unknown operator:%s %s %s", left.Type(), operator, right.Type())
+		return newTypeError("unknown operator: %s %s %s", object.FLOAT_OBJ, operator, object.FLOAT_OBJ)
 	}
 }
 
@@ -276,6 +380,9 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	var result object.Object
 
 	for _, statement := range block.Statements {
+		if errObj := beforeStatement(statement, env); errObj != nil {
+			return errObj
+		}
 		result = Eval(statement, env)
 
 		if result != nil {
@@ -313,7 +420,7 @@ func isTruthy(obj object.Object) bool {
 	case FALSE:
 		return false
 	default:
-		return false
+		return true
 	}
 }
 
@@ -325,7 +432,7 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
 	}
-	return newError("identifier not found: " + node.Value)
+	return newNameError("identifier not found: " + node.Value)
 }
 
 // evalExpressions 解析表达式
@@ -341,17 +448,46 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-// applyFunction 调用函数
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// applyFunction 调用函数, named 是具名参数, env 是调用处的环境, 内置函数通过它访问输入输出
+func applyFunction(fn object.Object, args []object.Object, named map[string]object.Object, env *object.Environment) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		// 尾调用不会递归调用 applyFunction, 而是在这个循环中继续执行, Go 的调用栈不会增长
+		// 尾调用的结果就是发起调用的函数的结果, 所以要符合途经的每个函数的返回值类型注解
+		var typed []*object.Function
+		for {
+			if fn.ReturnType != nil && !containsFunction(typed, fn) {
+				typed = append(typed, fn)
+			}
+			extendedEnv, errObj := extendFunctionEnv(fn, args, named)
+			if errObj != nil {
+				return errObj
+			}
+			if errObj := enterFunction(fn, extendedEnv); errObj != nil {
+				return errObj
+			}
+			evaluated := evalFunctionBody(fn.Body, extendedEnv)
+			leaveFunction(fn, extendedEnv, evaluated)
+			if errObj, ok := evaluated.(*object.Error); ok {
+				errObj.Stack = append(errObj.Stack, functionName(fn))
+			}
+			call, ok := evaluated.(*tailCall)
+			if !ok {
+				return checkReturnTypes(typed, unwrapReturnValue(evaluated))
+			}
+			next, ok := call.fn.(*object.Function)
+			if !ok {
+				return checkReturnTypes(typed, applyFunction(call.fn, call.args, call.named, call.env))
+			}
+			fn, args, named = next, call.args, call.named
+		}
 	case *object.Builtin:
-		return fn.Fn(args...)
+		if len(named) != 0 {
+			return newArgumentError("builtin functions do not accept named arguments")
+		}
+		return fn.Fn(env, args...)
 	default:
-		return newError("not a function:%s", fn.Type())
+		return newTypeError("not a function:%s", fn.Type())
 	}
 }
 
@@ -364,25 +500,121 @@ func unwrapReturnValue(obj object.Object) object.Object {
 }
 
 // extendFunctionEnv 设置函数传入的变量
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// 参数依次从位置参数、具名参数、默认值中取值, 默认值在调用时于函数的新环境中求值, 因此可以引用前面的参数
+// 剩余参数 ...rest 收集多出的位置参数; 缺少参数、参数过多、不符合类型注解以及解构失败时返回错误
+func extendFunctionEnv(fn *object.Function, args []object.Object, named map[string]object.Object) (*object.Environment, *object.Error) {
 	env := object.NewEnclosedEnvironment(fn.Env)
-	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+
+	params := fn.Parameters
+	var rest *ast.RestPattern
+	var restType ast.TypeExpr
+	if n := len(params); n > 0 {
+		last := params[n-1]
+		if typed, ok := last.(*ast.TypedPattern); ok {
+			last, restType = typed.Target, typed.Type
+		}
+		if r, ok := last.(*ast.RestPattern); ok {
+			rest = r
+			params = params[:n-1]
+		}
+	}
+	if rest == nil && len(args) > len(params) {
+		return nil, newArgumentError("%s: too many arguments, want at most %d, got %d", functionName(fn), len(params), len(args))
+	}
+
+	used := 0
+	for paramIdx, param := range params {
+		name := parameterName(param)
+		value, byName := named[name]
+		if byName {
+			used++
+		}
+		switch {
+		case paramIdx < len(args):
+			if byName {
+				return nil, newArgumentError("%s: got multiple values for parameter %s", functionName(fn), name)
+			}
+			value = args[paramIdx]
+		case byName:
+		case isDefaultPattern(param):
+			value = Eval(param.(*ast.DefaultPattern).Default, env)
+			if isError(value) {
+				return nil, value.(*object.Error)
+			}
+		default:
+			return nil, newArgumentError("%s: missing argument for parameter %s", functionName(fn), param.String())
+		}
+		if def, ok := param.(*ast.DefaultPattern); ok {
+			param = def.Target
+		}
+		if typed, ok := param.(*ast.TypedPattern); ok {
+			converted, errObj := checkParameterType(fn, typed.Target.String(), typed.Type, value)
+			if errObj != nil {
+				return nil, errObj
+			}
+			param, value = typed.Target, converted
+		}
+		if errObj := destructure(param, value, env); errObj != nil {
+			return nil, errObj
+		}
+	}
+
+	if used != len(named) {
+		for name := range named {
+			if !hasParameter(params, name) {
+				return nil, newArgumentError("%s: unexpected named argument %s", functionName(fn), name)
+			}
+		}
+	}
+	if rest != nil {
+		extra := []object.Object{}
+		if len(args) > len(params) {
+			extra = append(extra, args[len(params):]...)
+		}
+		restValue, errObj := checkParameterType(fn, rest.String(), restType, &object.Array{Elements: extra})
+		if errObj != nil {
+			return nil, errObj
+		}
+		env.Set(rest.Name.Value, restValue)
 	}
-	return env
+	return env, nil
 }
 
 func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newTypeError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
 	return &object.String{Value: leftVal + rightVal}
 }
 
+// newError 创建 RuntimeError, 其他种类的错误用 newTypeError 等函数创建
 func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+	return newKindError(runtimeError, format, a...)
+}
+
+// newTypeError 值的类型不支持这个操作, 例如 1 + true
+func newTypeError(format string, a ...interface{}) *object.Error {
+	return newKindError(typeError, format, a...)
+}
+
+// newNameError 使用了未定义的名字
+func newNameError(format string, a ...interface{}) *object.Error {
+	return newKindError(nameError, format, a...)
+}
+
+// newArgumentError 参数的个数或取值不对
+func newArgumentError(format string, a ...interface{}) *object.Error {
+	return newKindError(argumentError, format, a...)
+}
+
+func newZeroDivisionError() *object.Error {
+	return newKindError(zeroDivisionError, "division by zero")
+}
+
+func newKindError(kind string, format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: kind}
 }
 
 func isError(obj object.Object) bool {
@@ -3,9 +3,16 @@
 package evaluator
 
 import (
+	"bytes"
+	"fmt"
+	"github.com/fanyeke/monkey/ast"
 	"github.com/fanyeke/monkey/lexer"
 	"github.com/fanyeke/monkey/object"
 	"github.com/fanyeke/monkey/parser"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
 	"testing"
 )
 
@@ -351,6 +358,34 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+// TestBuiltinArity 内置函数登记的参数个数要和它运行时的检查一致
+func TestBuiltinArity(t *testing.T) {
+	call := func(builtin *object.Builtin, n int) object.Object {
+		args := make([]object.Object, n)
+		for i := range args {
+			args[i] = NULL
+		}
+		return builtin.Fn(object.NewEnvironment(), args...)
+	}
+	for _, name := range BuiltinNames() {
+		builtin := builtins[name]
+		if builtin.MinArgs < 0 || builtin.MaxArgs == 0 || builtin.MaxArgs > 0 && builtin.MaxArgs < builtin.MinArgs {
+			t.Errorf("builtin %s has invalid arity [%d, %d]", name, builtin.MinArgs, builtin.MaxArgs)
+			continue
+		}
+		if builtin.MinArgs > 0 {
+			if errObj, ok := call(builtin, builtin.MinArgs-1).(*object.Error); !ok || errObj.Kind != argumentError {
+				t.Errorf("builtin %s accepts %d argument(s), want at least %d", name, builtin.MinArgs-1, builtin.MinArgs)
+			}
+		}
+		if builtin.MaxArgs > 0 {
+			if errObj, ok := call(builtin, builtin.MaxArgs+1).(*object.Error); !ok || errObj.Kind != argumentError {
+				t.Errorf("builtin %s accepts %d argument(s), want at most %d", name, builtin.MaxArgs+1, builtin.MaxArgs)
+			}
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -412,6 +447,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -512,3 +555,754 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestRegexBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`match(/^h.llo$/, "hello")`, true},
+		{`match(/^HELLO$/i, "hello")`, true},
+		{`match(regex("[0-9]+"), "abc")`, false},
+		{`match("b+", "abbc")`, true},
+		{`find_all(/[0-9]+/, "a1b22c333")`, "[1, 22, 333]"},
+		{`find_all(/x/, "abc")`, "[]"},
+		{`replace_all(/\s+/, "a  b   c", "-")`, "a-b-c"},
+		{`replace_all(/(?P<k>\w+)=(?P<v>\w+)/, "a=1", "${v}=${k}")`, "1=a"},
+		{`captures(/(?P<y>\d{4})-(?P<m>\d{2})/, "on 2023-10")["y"]`, "2023"},
+		{`captures(/(?P<y>\d{4})-(?P<m>\d{2})/, "on 2023-10")["m"]`, "10"},
+		{`captures(/(?P<y>\d{4})/, "none")`, nil},
+		{`10 / 2 / 5`, 1},
+		{`regex("(")`, "ERROR:invalid regex \"(\": error parsing regexp: missing closing ): `(`"},
+		{`match(1, "a")`, "ERROR:argument to `match` must be REGEX or STRING, got INTEGER"},
+		{`match(/a/)`, "ERROR:wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, expected, evaluated)
+			}
+		case nil:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestRegexCache(t *testing.T) {
+	first := testEval(`/ab+c/`)
+	second := testEval(`regex("ab+c")`)
+	if first != second {
+		t.Errorf("compiled regex was not cached. got=%p and %p", first, second)
+	}
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`json_parse("42")`, 42},
+		{`json_parse("true")`, true},
+		{`json_parse("null")`, nil},
+		{`json_parse("\"hi\"")`, "hi"},
+		{`json_parse("[1, \"a\", [false]]")`, "[1, a, [false]]"},
+		{`json_parse("{\"a\": {\"b\": [1, 2]}}")["a"]["b"][1]`, 2},
+		{`json_parse("1.5")`, "1.5"},
+		{`json_parse("[2.0, 1e3, -0.25]")`, "[2.0, 1000.0, -0.25]"},
+		{`json_parse("9223372036854775808")`, "9.223372036854776e+18"},
+		{`json_parse("1e400")`, "ERROR:json_parse: unsupported number 1e400"},
+		{`json_parse("[1,")`, "ERROR:json_parse: unexpected EOF"},
+		{`json_parse("1 2")`, "ERROR:json_parse: unexpected data after top-level value"},
+		{`json_stringify({"b": 1, "a": [true, "x<y"], "c": {}})`, `{"a":[true,"x<y"],"b":1,"c":{}}`},
+		{`json_stringify([1, 2], 2)`, "[\n  1,\n  2\n]"},
+		{`json_stringify({"k": 1}, "\t")`, "{\n\t\"k\": 1\n}"},
+		{`json_stringify(if (false) { 1 })`, "null"},
+		{`json_stringify(json_parse("{\"z\":[1,{\"y\":null}]}"))`, `{"z":[1,{"y":null}]}`},
+		{`json_stringify([1.5, 2.0, sqrt(2)])`, "[1.5,2.0,1.4142135623730951]"},
+		{`json_stringify(json_parse("{\"x\": 0.1}"))`, `{"x":0.1}`},
+		{`json_stringify(0.0 / 0.0)`, "ERROR:json_stringify: unsupported number NaN"},
+		{`json_stringify({1: 2})`, "ERROR:json_stringify: hash key must be STRING, got INTEGER"},
+		{`json_stringify([fn(x) { x }])`, "ERROR:json_stringify: unsupported value FUNCTION"},
+		{`json_stringify(1, true)`, "ERROR:second argument to `json_stringify` must be INTEGER or STRING, got BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated == nil || evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, expected, evaluated)
+			}
+		case nil:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestFloatAndModuloExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"7 % 3", "1"},
+		{"-7 % 3", "-1"},
+		{"1.5 + 1", "2.5"},
+		{"2 * 1.25", "2.5"},
+		{"1 / 4.0", "0.25"},
+		{"-1.5", "-1.5"},
+		{"3.0 - 1", "2.0"},
+		{"5.5 % 2", "1.5"},
+		{"1.5 < 2", "true"},
+		{"2.0 == 2", "true"},
+		{"1 / 0", "ERROR:division by zero"},
+		{"1 % 0", "ERROR:division by zero"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestMathBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"abs(-5)", "5"},
+		{"abs(-2.5)", "2.5"},
+		{"abs(-9223372036854775807)", "9223372036854775807"},
+		{"abs(-9223372036854775807 - 1)", "ERROR:`abs` result out of INTEGER range: -9223372036854775808"},
+		{"min(3, 1, 2)", "1"},
+		{"max([3, 7.5, 2])", "7.5"},
+		{"min()", "ERROR:`min` requires at least one number"},
+		{"pow(2, 10)", "1024"},
+		{"pow(2, 62)", "4611686018427387904"},
+		{"pow(-2, 63)", "-9223372036854775808"},
+		{"pow(2, 63)", "9.223372036854776e+18"},
+		{"pow(2, 64)", "1.8446744073709552e+19"},
+		{"pow(-3, 41)", "-3.647299637717079e+19"},
+		{"pow(3, 100)", "5.153775207320114e+47"},
+		{"pow(-1, 9223372036854775807)", "-1"},
+		{"pow(0, 9223372036854775807)", "0"},
+		{"pow(-9223372036854775807 - 1, 1)", "-9223372036854775808"},
+		{"pow(2, -1)", "0.5"},
+		{"pow(4, 0.5)", "2.0"},
+		{"sqrt(16)", "4"},
+		{"sqrt(2)", "1.4142135623730951"},
+		{"sqrt(-1)", "ERROR:argument to `sqrt` must not be negative, got -1"},
+		{"floor(2.7)", "2"},
+		{"ceil(2.1)", "3"},
+		{"round(2.5)", "3"},
+		{"round(-2.5)", "-3"},
+		{"floor(5)", "5"},
+		{"clamp(15, 0, 10)", "10"},
+		{"clamp(-3, 0, 10)", "0"},
+		{"clamp(4, 0, 10)", "4"},
+		{"clamp(4, 10, 0)", "ERROR:`clamp` lower bound 10 is greater than upper bound 0"},
+		{"sum([1, 2, 3])", "6"},
+		{"sum(1, 2, 0.5)", "3.5"},
+		{"sum([])", "0"},
+		{"gcd(12, 18)", "6"},
+		{"gcd(-12, 18, 27)", "3"},
+		{"gcd(1.5, 3)", "ERROR:argument to `gcd` must be INTEGER, got FLOAT"},
+		{`abs("x")`, "ERROR:argument to `abs` must be INTEGER or FLOAT, got STRING"},
+		{"rand_int(5, 5)", "ERROR:`rand_int` range is empty: [5, 5)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestSeededRandom(t *testing.T) {
+	input := "[rand_int(1000), rand_int(-5, 5), shuffle([1, 2, 3, 4, 5, 6, 7, 8])]"
+
+	SeedRandom(42)
+	first := testEval(input).Inspect()
+	SeedRandom(42)
+	second := testEval(input).Inspect()
+
+	if first != second {
+		t.Errorf("same seed produced different results: %s and %s", first, second)
+	}
+
+	for i := 0; i < 100; i++ {
+		n := testEval("rand_int(-5, 5)").(*object.Integer).Value
+		if n < -5 || n >= 5 {
+			t.Fatalf("rand_int(-5, 5) out of range: %d", n)
+		}
+	}
+
+	// 区间长度超出 int64 时不能溢出
+	for _, input := range []string{
+		"rand_int(-9223372036854775807, 9223372036854775807)",
+		"rand_int(-9223372036854775807 - 1, 9223372036854775807)",
+		"rand_int(-1, 9223372036854775807)",
+	} {
+		for i := 0; i < 100; i++ {
+			if _, ok := testEval(input).(*object.Integer); !ok {
+				t.Fatalf("%s did not return an integer", input)
+			}
+		}
+	}
+	if n := testEval("rand_int(9223372036854775806, 9223372036854775807)").(*object.Integer).Value; n != 9223372036854775806 {
+		t.Errorf("rand_int at the upper bound returned %d", n)
+	}
+}
+
+func TestOutputBuiltinsUseContext(t *testing.T) {
+	input := `puts("a", 1); print("b", [2]); print("!"); eprint("oops"); let x = input("? "); let y = input(); [x, y, input()]`
+	var out, errOut bytes.Buffer
+
+	env := object.NewEnvironment()
+	env.SetContext(object.NewContext(strings.NewReader("first\r\nsecond"), &out, &errOut))
+	evaluated := Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+
+	if out.String() != "a\n1\nb [2]!? " {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+	if errOut.String() != "oops\n" {
+		t.Errorf("wrong error output. got=%q", errOut.String())
+	}
+	if evaluated.Inspect() != "[first, second, null]" {
+		t.Errorf("wrong input result. got=%s", evaluated.Inspect())
+	}
+}
+
+func TestFileBuiltins(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "pwned.txt"), filepath.Join(root, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`write_file("a.txt", "one\n")`, "null"},
+		{`append_file("a.txt", "two\r\n")`, "null"},
+		{`read_file("a.txt")`, "one\ntwo\r\n"},
+		{`read_lines("a.txt")`, "[one, two]"},
+		{`exists("a.txt")`, "true"},
+		{`exists("b.txt")`, "false"},
+		{`list_dir()`, "[a.txt, dangling, escape]"},
+		{`read_file("b.txt")`, "ERROR:read_file: b.txt: no such file or directory"},
+		{`read_file("../x")`, `ERROR:read_file: path "../x" is outside the allowed directory`},
+		{`read_file("sub/../../x")`, `ERROR:read_file: path "sub/../../x" is outside the allowed directory`},
+		{`read_file("escape/secret.txt")`, `ERROR:read_file: path "escape/secret.txt" is outside the allowed directory`},
+		{`write_file("escape/new.txt", "x")`, `ERROR:write_file: path "escape/new.txt" is outside the allowed directory`},
+		{`write_file("dangling", "x")`, `ERROR:write_file: path "dangling" is a dangling symbolic link`},
+		{`append_file("dangling", "x")`, `ERROR:append_file: path "dangling" is a dangling symbolic link`},
+		{`exists("dangling")`, `ERROR:exists: path "dangling" is a dangling symbolic link`},
+		{`read_file(1)`, "ERROR:path argument to `read_file` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		if err := env.Context().AllowFiles(root); err != nil {
+			t.Fatal(err)
+		}
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(outside, "pwned.txt")); err == nil {
+		t.Errorf("write_file followed a dangling symbolic link out of the root")
+	}
+
+	evaluated := testEval(`read_file("a.txt")`)
+	expected := "ERROR:`read_file` is disabled: file access has not been enabled by the host"
+	if evaluated.Inspect() != expected {
+		t.Errorf("file access should be disabled by default. got=%s", evaluated.Inspect())
+	}
+}
+
+func writeModules(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestImportModules(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.mk":           `import "lib/math.mk" as m; import "lib/math.mk" as again; import "strings.mk" as s; [m.square(m.base), again["square"](2), s.greet("mk")]`,
+		"lib/math.mk":       `import "helper.mk" as h; puts("loading math"); let secret = 3; export let base = h.inc(secret); export let square = fn(x) { x * x }`,
+		"lib/helper.mk":     `export let inc = fn(x) { x + 1 };`,
+		"shared/strings.mk": `export let greet = fn(name) { "hello " + name };`,
+		"private.mk":        `import "lib/math.mk" as m; m.secret`,
+		"a.mk":              `import "b.mk" as b; export let x = 1;`,
+		"b.mk":              `import "a.mk" as a; export let y = 2;`,
+		"broken.mk":         `import "bad.mk" as bad; 1`,
+		"bad.mk":            `export let = 1;`,
+		"missing.mk":        `import "nope.mk" as nope; 1`,
+	})
+
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"main.mk", "[16, 4, hello mk]"},
+		{"private.mk", "ERROR:module math has no export named secret"},
+		{"a.mk", "ERROR:import cycle: a.mk -> b.mk -> a.mk"},
+		{"missing.mk", `ERROR:import "nope.mk": module not found`},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		path := filepath.Join(dir, tt.file)
+		source, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env := object.NewEnvironment()
+		env.SetContext(object.NewContext(strings.NewReader(""), &out, &out))
+		env.Context().ModulePaths = []string{filepath.Join(dir, "shared")}
+		env.SetFile(path)
+
+		evaluated := Eval(parser.New(lexer.New(string(source))).ParseProgram(), env)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.file, tt.expected, evaluated)
+		}
+		if tt.file == "main.mk" && out.String() != "loading math\n" {
+			t.Errorf("module should be evaluated exactly once. output=%q", out.String())
+		}
+	}
+
+	env := object.NewEnvironment()
+	env.SetFile(filepath.Join(dir, "broken.mk"))
+	evaluated := Eval(parser.New(lexer.New(`import "bad.mk" as bad;`)).ParseProgram(), env)
+	if errObj, ok := evaluated.(*object.Error); !ok || !strings.Contains(errObj.Message, "parse errors") {
+		t.Errorf("expected parse error from module. got=%+v", evaluated)
+	}
+}
+
+func TestMemberAccessOnNonModule(t *testing.T) {
+	evaluated := testEval(`let x = 1; x.y`)
+	if evaluated.Inspect() != "ERROR:member access not supported: INTEGER" {
+		t.Errorf("wrong error. got=%s", evaluated.Inspect())
+	}
+}
+
+func TestMemberAccessAndMethodCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let h = {"name": "monkey", "age": 5}; h.name`, "monkey"},
+		{`let h = {"inner": {"x": 1}}; h.inner.x + 1`, "2"},
+		{`{"a": 1}.missing`, "null"},
+		{`let h = {"double": fn(x) { x * 2 }}; h.double(21)`, "42"},
+		{`[1, 2].push(3)`, "[1, 2, 3]"},
+		{`[1, 2].push(3).rest().first()`, "2"},
+		{`"hello".len()`, "5"},
+		{`let add = fn(a, b) { a + b }; 1.add(2)`, "3"},
+		{`let x = 4; x.sqrt()`, "2"},
+		{`let len = fn(x) { 99 }; "abc".len()`, "99"},
+		{`{"push": fn(x) { "member" }}.push(1)`, "member"},
+		{`[1].nope()`, "ERROR:ARRAY has no member nope and no function named nope"},
+		{`1.b`, "ERROR:member access not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4, 5][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4, 5][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4, 5][3:]", "[4, 5]"},
+		{"[1, 2, 3, 4, 5][:]", "[1, 2, 3, 4, 5]"},
+		{"[1, 2, 3, 4, 5][-2:]", "[4, 5]"},
+		{"[1, 2, 3, 4, 5][:-2]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4, 5][::2]", "[1, 3, 5]"},
+		{"[1, 2, 3, 4, 5][1::2]", "[2, 4]"},
+		{"[1, 2, 3, 4, 5][::-1]", "[5, 4, 3, 2, 1]"},
+		{"[1, 2, 3, 4, 5][3:0:-1]", "[4, 3, 2]"},
+		{"[1, 2, 3, 4, 5][-1:-4:-2]", "[5, 3]"},
+		{"[1, 2, 3][10:20]", "[]"},
+		{"[1, 2, 3][-10:2]", "[1, 2]"},
+		{"[1, 2, 3][2:1]", "[]"},
+		{`"hello"[1:4]`, "ell"},
+		{`"hello"[::-1]`, "olleh"},
+		{`"hello"[-3:]`, "llo"},
+		{`"hello"[1]`, "e"},
+		{`"hello"[-1]`, "o"},
+		{`"hello"[5]`, "null"},
+		{"let a = [1, 2, 3]; let i = 1; a[i:i + 1]", "[2]"},
+		{"[1, 2, 3][1::9223372036854775807]", "[2]"},
+		{"[1, 2, 3][::-9223372036854775807]", "[3]"},
+		{"[1, 2, 3][::-9223372036854775807 - 1]", "[3]"},
+		{`"abc"[1::9223372036854775807]`, "b"},
+		{`"abc"[::-9223372036854775807]`, "c"},
+		{"[1, 2][::0]", "ERROR:slice step cannot be zero"},
+		{`[1, 2]["a":]`, "ERROR:slice index must be INTEGER, got STRING"},
+		{"5[1:2]", "ERROR:slice operation not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = [1, 2]; a + b", "3"},
+		{"let [first, ...rest] = [1, 2, 3]; [first, rest]", "[1, [2, 3]]"},
+		{"let [x, ...rest] = [1]; rest", "[]"},
+		{"let [[a, b], [c]] = [[1, 2], [3]]; a + b + c", "6"},
+		{"let [_, second] = [1, 2]; second", "2"},
+		{`let {name, age: years} = {"name": "monkey", "age": 5}; [name, years]`, "[monkey, 5]"},
+		{`let {"full-name": n} = {"full-name": "a b"}; n`, "a b"},
+		{`let {a, ...others} = {"a": 1, "b": 2}; [a, others["b"], len(json_stringify(others))]`, "[1, 2, 7]"},
+		{`let {point: [x, y]} = {"point": [3, 4]}; x * y`, "12"},
+		{"let add = fn([a, b]) { a + b }; add([2, 3])", "5"},
+		{`let greet = fn({name}, punct) { name + punct }; greet({"name": "hi"}, "!")`, "hi!"},
+		{"let [a, b] = [1, 2, 3];", "ERROR:cannot destructure [a, b]: expected 2 elements, got 3"},
+		{"let [a, b, ...c] = [1];", "ERROR:cannot destructure [a, b, ...c]: expected at least 2 elements, got 1"},
+		{"let [a] = 5;", "ERROR:cannot destructure [a]: expected ARRAY, got INTEGER"},
+		{`let {name} = {"age": 1};`, `ERROR:cannot destructure {name}: missing key "name"`},
+		{`let {p: [x]} = {"p": 1};`, "ERROR:cannot destructure {p: [x]}: expected ARRAY, got INTEGER"},
+		{"let f = fn([a]) { a }; f(1)", "ERROR:cannot destructure [a]: expected ARRAY, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestDefaultVariadicAndNamedParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, b = 10) { a + b }; [f(1), f(1, 2)]", "[11, 3]"},
+		{"let f = fn(a, b = a * 2) { b }; f(4)", "8"},
+		{"let n = 1; let f = fn(a = n) { a }; let n = 5; f()", "5"},
+		{"let f = fn(a, ...rest) { [a, rest] }; [f(1), f(1, 2, 3)]", "[[1, []], [1, [2, 3]]]"},
+		{"let f = fn(...all) { len(json_stringify(all)) }; f()", "2"},
+		{"let f = fn(a, b) { a - b }; f(b: 2, a: 10)", "8"},
+		{"let f = fn(a, b = 1, c = 2) { [a, b, c] }; f(0, c: 5)", "[0, 1, 5]"},
+		{"let f = fn(a, [x, y] = [1, 2]) { a + x + y }; f(1)", "4"},
+		{"let h = {\"f\": fn(a, b) { a * b }}; h.f(b: 3, a: 2)", "6"},
+		{"let f = fn(a, b) { a + b }; 1.f(b: 2)", "3"},
+		{"let add = fn(a, b) { a + b }; add(1)", "ERROR:add: missing argument for parameter b"},
+		{"let add = fn(a, b) { a + b }; add(1, 2, 3)", "ERROR:add: too many arguments, want at most 2, got 3"},
+		{"let add = fn(a, b) { a + b }; add(1, 2, c: 3)", "ERROR:add: unexpected named argument c"},
+		{"let add = fn(a, b) { a + b }; add(1, a: 3)", "ERROR:add: got multiple values for parameter a"},
+		{"let f = fn(a) { a }; f(a: 1, a: 2)", "ERROR:named argument a given more than once"},
+		{"fn(x) { x }()", "ERROR:anonymous function: missing argument for parameter x"},
+		{"let f = fn(a = nope) { a }; f()", "ERROR:identifier not found: nope"},
+		{"len(\"ab\", x: 1)", "ERROR:builtin functions do not accept named arguments"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let add = fn(a: int, b: int = 2) -> int { a + b }; [add(1), add(1, 3), add(b: 5, a: 1)]", "[3, 4, 6]"},
+		// 用在 float 位置的整数在绑定时转换为浮点数
+		{"let f = fn(x: float) -> float { x / 2 }; [f(3), f(3) == 1.5]", "[1.5, true]"},
+		{"let f = fn() -> float { 3 }; f() / 2", "1.5"},
+		{"let x: float = 3; let xs: [float] = [1, 2.5]; let h: {string: float} = {\"a\": 1}; [x / 2, xs[0] / 2, h.a / 2]", "[1.5, 0.5, 0.5]"},
+		{"let xs = [1]; let f = fn(ys: [float]) { ys[0] / 2 }; [f(xs), xs[0] / 2]", "[0.5, 0]"},
+		{"let f = fn(...xs: [float]) { xs[0] / 2 }; f(1)", "0.5"},
+		{"let f = fn(xs: [int], h: {string: [bool]}, g: fn(int) -> int) { [g(xs[0]), first(h.a)] }; f([2], {\"a\": [true]}, fn(n) { n * 10 })", "[20, true]"},
+		{"let f = fn(...xs: [string]) -> any { xs }; f(\"a\", \"b\")", "[a, b]"},
+		{"let x: [any] = [1, \"a\", true]; x", "[1, a, true]"},
+		{"let x: string = \"s\"; x", "s"},
+		{"let f = fn(a: string) { a }; f(1)", "ERROR:f: type mismatch for parameter a: expected string, got INTEGER"},
+		{"let f = fn(a: int = \"x\") { a }; f()", "ERROR:f: type mismatch for parameter a: expected int, got STRING"},
+		{"let f = fn(xs: [int]) { xs }; f([1, \"a\"])", "ERROR:f: type mismatch for parameter xs: expected [int], got ARRAY"},
+		{"let f = fn(h: {string: int}) { h }; f({1: 1})", "ERROR:f: type mismatch for parameter h: expected {string: int}, got HASH"},
+		{"let f = fn(...xs: [int]) { xs }; f(1, true)", "ERROR:f: type mismatch for parameter ...xs: expected [int], got ARRAY"},
+		{"let f = fn(g: fn(int) -> int) { g }; f(1)", "ERROR:f: type mismatch for parameter g: expected fn(int) -> int, got INTEGER"},
+		{"let f = fn() -> int { \"a\" }; f()", "ERROR:f: type mismatch for return value: expected int, got STRING"},
+		{"let f = fn() -> null { }; f()", "<nil>"},
+		{"let f = fn(n) -> int { if (n == 0) { return true; } f(n - 1) }; f(3)", "ERROR:f: type mismatch for return value: expected int, got BOOLEAN"},
+		{"let g = fn() { \"s\" }; let f = fn() -> int { g() }; f()", "ERROR:f: type mismatch for return value: expected int, got STRING"},
+		{"let x: int = 1.5;", "ERROR:type mismatch for x: expected int, got FLOAT"},
+		{"let [a, b]: [int] = [1, \"b\"];", "ERROR:type mismatch for [a, b]: expected [int], got ARRAY"},
+		{"let f = fn(a: string) { a }; try { f(1) } catch (e) { e.kind }", "TypeError"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := "<nil>"
+		if evaluated != nil {
+			got = evaluated.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	describe := `let describe = fn(v) {
+		match (v) {
+			0 => "zero",
+			-1 => "minus one",
+			"hi" => "greeting",
+			true => "yes",
+			[x, y] => x + y,
+			{type: "circle", r} => r * r * 3,
+			{type: "square", side} => side * side,
+			n if n > 10 => "big",
+			_ => "other",
+		}
+	};`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{describe + "describe(0)", "zero"},
+		{describe + "describe(-1)", "minus one"},
+		{describe + `describe("hi")`, "greeting"},
+		{describe + "describe(true)", "yes"},
+		{describe + "describe([1, 2])", "3"},
+		{describe + `describe({"type": "circle", "r": 2})`, "12"},
+		{describe + `describe({"type": "square", "side": 3})`, "9"},
+		{describe + "describe(42)", "big"},
+		{describe + "describe(5)", "other"},
+		{"match ([1, 2, 3]) { [x, y] => x + y, _ => \"other\" }", "other"},
+		{"match (1.5) { 1.5 => \"float\", _ => \"no\" }", "float"},
+		{"match (1) { 1.0 => \"float\", 1 => \"int\" }", "int"},
+		{"match ([1, [2, 3]]) { [1, [_, z]] => z }", "3"},
+		{"match ([1, 2, 3]) { [first, ...rest] => rest }", "[2, 3]"},
+		{"let x = 1; match (5) { x => x }; x", "1"},
+		{"match (3) { 1 => \"one\", 2 => \"two\" }", "ERROR:no match arm for value 3"},
+		{"match (3) { n if nope => n }", "ERROR:identifier not found: nope"},
+		{`match(/b+/, "abbc")`, "true"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestThrowTryCatchFinally(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`throw "boom"; 1`, "ERROR:boom"},
+		{`try { throw "boom"; 1 } catch (e) { e.message }`, "boom"},
+		{`try { throw "boom" } catch (e) { e.kind }`, "Error"},
+		{`try { throw [1, 2] } catch (e) { e.value }`, "[1, 2]"},
+		{`try { throw {"message": "bad input", "kind": "ValueError"} } catch (e) { [e.kind, e.message] }`, "[ValueError, bad input]"},
+		{"try { 1 + true } catch (e) { [e.kind, e.message] }", "[TypeError, type mismatch: INTEGER + BOOLEAN]"},
+		{"try { nope } catch (e) { e.kind }", "NameError"},
+		{"try { 1 / 0 } catch (e) { e.kind }", "ZeroDivisionError"},
+		{`try { match ("division by zero") { 1 => 1 } } catch (e) { e.kind }`, "RuntimeError"},
+		{"try { len(1, 2) } catch (e) { e.kind }", "ArgumentError"},
+		{"try { 10 } catch (e) { 20 }", "10"},
+		{"try { throw 1 } catch { 2 }", "2"},
+		// 尾调用复用调用者的栈帧, outer 中的 inner() 是尾调用, 栈中不再有 outer
+		{`let inner = fn() { throw "deep" }; let outer = fn() { inner() }; try { outer() } catch (e) { e.stack }`, "[inner]"},
+		{`let inner = fn() { throw "deep" }; let outer = fn() { let r = inner(); r }; try { outer() } catch (e) { e.stack }`, "[inner, outer]"},
+		{`try { throw "a" } catch (e) { throw e }`, "ERROR:a"},
+		{`try { try { 1 + true } catch (e) { throw e } } catch (e) { e.kind }`, "TypeError"},
+		{"let log = []; let r = try { throw 1 } catch (e) { 2 } finally { let log = push(log, 3) }; [r, log]", "[2, [3]]"},
+		{"let log = []; try { 1 } finally { let log = push(log, 3) }; log", "[3]"},
+		{`try { throw "x" } finally { 1 }`, "ERROR:x"},
+		{`try { 1 } finally { throw "from finally" }`, "ERROR:from finally"},
+		{"let f = fn() { try { return 1; } finally { 2 }; 3 }; f()", "1"},
+		{"let f = fn() { try { throw 1 } catch (e) { return 5 }; 3 }; f()", "5"},
+		{"try { throw 1 } catch (e) { 2 }; e", "ERROR:identifier not found: e"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	// 限制 Go 的栈大小, 没有尾调用优化时一百万层递归会超出限制
+	defer debug.SetMaxStack(debug.SetMaxStack(64 << 20))
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let count = fn(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } }; count(1000000, 0)", "1000000"},
+		{"let count = fn(n, acc) { if (n == 0) { return acc; } return count(n - 1, acc + 1); }; count(1000000, 0)", "1000000"},
+		{"let count = fn(n) { match (n) { 0 => \"done\", _ => count(n - 1) } }; count(100000)", "done"},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+		  let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+		  [even(100000), odd(100001)]`, "[true, true]"},
+		{"let sum = fn(xs, n, acc) { if (n == 0) { acc } else { xs.rest().sum(n - 1, acc + xs.first()) } }; sum([1, 2, 3, 4], 4, 0)", "10"},
+		{"let f = fn(n) { len(n) }; f(\"abc\")", "3"},
+		{"let f = fn(n) { if (n > 0) { f(n - 1) } }; f(3)", "null"},
+		{"let f = fn(n) { if (n == 0) { nope } else { f(n - 1) } }; f(100000)", "ERROR:identifier not found: nope"},
+		{"let f = fn(n) { if (n == 0) { 1 } else { f(n - 1) + 1 } }; f(100)", "101"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestExitBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		code     int
+		exited   bool
+		expected string
+	}{
+		{"exit(3); 1", 3, true, "ERROR:exit(3)"},
+		{"exit()", 0, true, "ERROR:exit(0)"},
+		{"let f = fn() { try { exit(2) } catch (e) { 1 } }; f(); 5", 2, true, "ERROR:exit(2)"},
+		{`exit("x")`, 0, false, "ERROR:argument to `exit` must be INTEGER, got STRING"},
+		{"1 + true", 0, false, "ERROR:type mismatch: INTEGER + BOOLEAN"},
+		{`try { throw {"kind": "Exit", "message": "x"} } catch (e) { "caught" }`, 0, false, "caught"},
+		{`throw {"kind": "Exit", "message": "x"}`, 0, false, "ERROR:cannot throw error of reserved kind Exit"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		code, exited := ExitCode(evaluated)
+		if exited != tt.exited || code != tt.code {
+			t.Errorf("wrong ExitCode for %s. expected=(%d, %t), got=(%d, %t)", tt.input, tt.code, tt.exited, code, exited)
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+// recordingHook 把收到的回调记录成 "stmt 行" "call 函数名" "return 函数名" 的序列
+type recordingHook struct {
+	events []string
+	stopAt int // 在这一行的语句之前中止求值, 0 表示不中止
+}
+
+func (h *recordingHook) Statement(stmt ast.Statement, env *object.Environment) *object.Error {
+	line := 0
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		line = stmt.Token.Pos.Line
+	case *ast.ExpressionStatement:
+		line = stmt.Token.Pos.Line
+	case *ast.ReturnStatement:
+		line = stmt.Token.Pos.Line
+	default:
+		return nil
+	}
+	h.events = append(h.events, fmt.Sprintf("stmt %d", line))
+	if line == h.stopAt {
+		return NewExit(7)
+	}
+	return nil
+}
+
+func (h *recordingHook) Call(fn *object.Function, env *object.Environment) *object.Error {
+	h.events = append(h.events, "call "+fn.Name)
+	return nil
+}
+
+func (h *recordingHook) Return(fn *object.Function, result object.Object) {
+	h.events = append(h.events, "return "+fn.Name)
+}
+
+func TestHook(t *testing.T) {
+	input := `let f = fn(n) {
+  if (n == 0) { return 0; }
+  f(n - 1)
+};
+let x = f(1);
+x`
+	tests := []struct {
+		stopAt   int
+		expected string
+		events   []string
+	}{
+		{0, "0", []string{
+			"stmt 1", "stmt 5", "call f", "stmt 2", "stmt 3",
+			// 尾调用: 先离开外层的 f 再进入被调用的 f
+			"return f", "call f", "stmt 2", "stmt 2", "return f",
+			"stmt 6",
+		}},
+		{3, "ERROR:exit(7)", []string{"stmt 1", "stmt 5", "call f", "stmt 2", "stmt 3", "return f"}},
+	}
+
+	for _, tt := range tests {
+		hook := &recordingHook{stopAt: tt.stopAt}
+		env := object.NewEnvironment()
+		env.Context().Hook = hook
+		evaluated := Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result with stopAt=%d. expected=%q, got=%q", tt.stopAt, tt.expected, evaluated.Inspect())
+		}
+		if strings.Join(hook.events, ", ") != strings.Join(tt.events, ", ") {
+			t.Errorf("wrong events with stopAt=%d.\nexpected=%q\ngot=%q", tt.stopAt, tt.events, hook.events)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package evaluator
+
+import (
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/object"
+)
+
+// exitKind exit() 产生的错误, 它会一直向外传播直到宿主程序, try/catch 不会捕获它
+const exitKind = "Exit"
+
+// ExitCode 判断求值结果是否是 exit(code) 的调用, 返回退出码
+func ExitCode(obj object.Object) (int, bool) {
+	errObj, ok := obj.(*object.Error)
+	if !ok || errObj.Kind != exitKind {
+		return 0, false
+	}
+	code, _ := errObj.Value.(*object.Integer)
+	if code == nil {
+		return 0, true
+	}
+	return int(code.Value), true
+}
+
+// 运行时错误的种类, 在创建错误的地方确定, 见 newError 等函数
+const (
+	runtimeError      = "RuntimeError"
+	typeError         = "TypeError"
+	nameError         = "NameError"
+	argumentError     = "ArgumentError"
+	zeroDivisionError = "ZeroDivisionError"
+)
+
+// evalThrowStatement 把任意值包装成错误抛出
+// 抛出带 message 字段的 hash (例如 catch 到的错误) 时沿用其中的 message 和 kind, kind 不能是保留的 Exit
+func evalThrowStatement(node *ast.ThrowStatement, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	errObj := &object.Error{Message: val.Inspect(), Kind: "Error", Value: val}
+	if hash, ok := val.(*object.Hash); ok {
+		if message, ok := hashString(hash, "message"); ok {
+			errObj.Message = message
+			if kind, ok := hashString(hash, "kind"); ok {
+				// Exit 只能由 exit() 产生, 否则 throw 就能绕过 try/catch 直接结束程序
+				if kind == exitKind {
+					return newError("cannot throw error of reserved kind %s", exitKind)
+				}
+				errObj.Kind = kind
+			}
+		}
+	}
+	return errObj
+}
+
+// evalTryExpression 求值 try 区块, 出错时把错误转换成 hash 绑定到 catch 的变量上
+// finally 总会执行, 它自身的错误或 return 会覆盖 try/catch 的结果
+func evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(node.Block, env)
+
+	if errObj, ok := result.(*object.Error); ok && errObj.Kind != exitKind && node.Catch != nil {
+		catchEnv := object.NewEnclosedEnvironment(env)
+		if node.Param != nil {
+			catchEnv.Set(node.Param.Value, errorToHash(errObj))
+		}
+		result = Eval(node.Catch, catchEnv)
+	}
+
+	if node.Finally != nil {
+		finally := Eval(node.Finally, env)
+		if finally != nil {
+			if rt := finally.Type(); rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return finally
+			}
+		}
+	}
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// errorToHash 把错误转换成 catch 中看到的 hash: {"message", "kind", "stack"}, throw 抛出的还带有原始值 "value"
+func errorToHash(errObj *object.Error) *object.Hash {
+	stack := make([]object.Object, len(errObj.Stack))
+	for i, frame := range errObj.Stack {
+		stack[i] = &object.String{Value: frame}
+	}
+	kind := errObj.Kind
+	if kind == "" {
+		kind = runtimeError
+	}
+
+	pairs := make(map[object.HashKey]object.HashPair)
+	set := func(name string, value object.Object) {
+		key := &object.String{Value: name}
+		pairs[key.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+	set("message", &object.String{Value: errObj.Message})
+	set("kind", &object.String{Value: kind})
+	set("stack", &object.Array{Elements: stack})
+	if errObj.Value != nil {
+		set("value", errObj.Value)
+	}
+	return &object.Hash{Pairs: pairs}
+}
+
+// hashString 读取 hash 中字符串类型的字段
+func hashString(hash *object.Hash, name string) (string, bool) {
+	pair, ok := hash.Pairs[(&object.String{Value: name}).HashKey()]
+	if !ok {
+		return "", false
+	}
+	s, ok := pair.Value.(*object.String)
+	if !ok {
+		return "", false
+	}
+	return s.Value, true
+}
@@ -0,0 +1,191 @@
+package evaluator
+
+import (
+	"errors"
+	"github.com/fanyeke/monkey/object"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sandboxPath 把脚本给出的路径解析为 Context.FileRoot 下的真实路径
+// 相对路径相对于根目录, 任何指向根目录之外的路径(包括 .. 和符号链接)都会被拒绝
+func sandboxPath(name string, env *object.Environment, arg object.Object) (string, *object.Error) {
+	root := env.Context().FileRoot
+	if root == "" {
+		return "", newError("`%s` is disabled: file access has not been enabled by the host", name)
+	}
+	str, ok := arg.(*object.String)
+	if !ok {
+		return "", newTypeError("path argument to `%s` must be STRING, got %s", name, arg.Type())
+	}
+
+	path := str.Value
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path = filepath.Clean(path)
+
+	// 文件可能还不存在(write_file), 这时解析父目录的符号链接
+	// 指向不存在文件的符号链接会在创建文件时被跟随, 无法确定它最终指向哪里, 直接拒绝
+	resolved, err := filepath.EvalSymlinks(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if info, lerr := os.Lstat(path); lerr == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return "", newError("%s: path %q is a dangling symbolic link", name, str.Value)
+		}
+		var dir string
+		dir, err = filepath.EvalSymlinks(filepath.Dir(path))
+		resolved = filepath.Join(dir, filepath.Base(path))
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", newError("%s: %s", name, err)
+	}
+	if err != nil {
+		// 父目录也不存在, 只能按字面路径检查
+		resolved = path
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", newError("%s: path %q is outside the allowed directory", name, str.Value)
+	}
+	return resolved, nil
+}
+
+// fileError 把 I/O 错误转换为错误对象, 去掉 Go 错误信息中的真实路径
+func fileError(name string, arg object.Object, err error) *object.Error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return newError("%s: %s: %s", name, arg.Inspect(), err)
+}
+
+// writeBuiltin 生成 write_file 和 append_file
+func writeBuiltin(name string, flag int) *object.Builtin {
+	return &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newArgumentError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			path, errObj := sandboxPath(name, env, args[0])
+			if errObj != nil {
+				return errObj
+			}
+			content, ok := args[1].(*object.String)
+			if !ok {
+				return newTypeError("second argument to `%s` must be STRING, got %s", name, args[1].Type())
+			}
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|flag, 0o644)
+			if err != nil {
+				return fileError(name, args[0], err)
+			}
+			defer f.Close()
+			if _, err := f.WriteString(content.Value); err != nil {
+				return fileError(name, args[0], err)
+			}
+			return NULL
+		},
+	}
+}
+
+var fileBuiltins = map[string]*object.Builtin{
+	"read_file": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newArgumentError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			path, errObj := sandboxPath("read_file", env, args[0])
+			if errObj != nil {
+				return errObj
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fileError("read_file", args[0], err)
+			}
+			return &object.String{Value: string(data)}
+		},
+	},
+	"read_lines": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newArgumentError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			path, errObj := sandboxPath("read_lines", env, args[0])
+			if errObj != nil {
+				return errObj
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fileError("read_lines", args[0], err)
+			}
+			elements := []object.Object{}
+			text := strings.TrimSuffix(string(data), "\n")
+			if text == "" {
+				return &object.Array{Elements: elements}
+			}
+			for _, line := range strings.Split(text, "\n") {
+				elements = append(elements, &object.String{Value: strings.TrimSuffix(line, "\r")})
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"write_file":  writeBuiltin("write_file", os.O_TRUNC),
+	"append_file": writeBuiltin("append_file", os.O_APPEND),
+	"list_dir": &object.Builtin{
+		MinArgs: 0,
+		MaxArgs: 1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newArgumentError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+			}
+			// 不传参数时列出根目录
+			dirArg := object.Object(&object.String{Value: "."})
+			if len(args) == 1 {
+				dirArg = args[0]
+			}
+			path, errObj := sandboxPath("list_dir", env, dirArg)
+			if errObj != nil {
+				return errObj
+			}
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return fileError("list_dir", dirArg, err)
+			}
+			elements := make([]object.Object, 0, len(entries))
+			for _, entry := range entries {
+				elements = append(elements, &object.String{Value: entry.Name()})
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"exists": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newArgumentError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			path, errObj := sandboxPath("exists", env, args[0])
+			if errObj != nil {
+				return errObj
+			}
+			_, err := os.Stat(path)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fileError("exists", args[0], err)
+			}
+			return nativeBoolToBooleanObject(err == nil)
+		},
+	},
+}
+
+func init() {
+	registerBuiltins(fileBuiltins)
+}
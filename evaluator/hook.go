@@ -0,0 +1,38 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/object"
+)
+
+// beforeStatement 执行语句之前通知 Context 中安装的 Hook
+func beforeStatement(stmt ast.Statement, env *object.Environment) *object.Error {
+	if hook := env.Context().Hook; hook != nil {
+		return hook.Statement(stmt, env)
+	}
+	return nil
+}
+
+// enterFunction 执行函数体之前通知 Hook
+func enterFunction(fn *object.Function, env *object.Environment) *object.Error {
+	if hook := env.Context().Hook; hook != nil {
+		return hook.Call(fn, env)
+	}
+	return nil
+}
+
+// leaveFunction 函数体执行完之后通知 Hook, 尾调用的结果为 nil
+func leaveFunction(fn *object.Function, env *object.Environment, result object.Object) {
+	if hook := env.Context().Hook; hook != nil {
+		if _, ok := result.(*tailCall); ok {
+			result = nil
+		}
+		hook.Return(fn, result)
+	}
+}
+
+// NewExit 创建与 exit(code) 相同的错误, 宿主程序可以用它中止求值, try/catch 不会捕获它
+func NewExit(code int64) *object.Error {
+	return &object.Error{Message: fmt.Sprintf("exit(%d)", code), Kind: exitKind, Value: &object.Integer{Value: code}}
+}
@@ -0,0 +1,172 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/fanyeke/monkey/object"
+	"io"
+	"math"
+	"strings"
+)
+
+// jsonToObject 把 encoding/json 解码出的值转换为 monkey 对象
+func jsonToObject(value interface{}) object.Object {
+	switch value := value.(type) {
+	case nil:
+		return NULL
+	case bool:
+		return nativeBoolToBooleanObject(value)
+	case string:
+		return &object.String{Value: value}
+	case json.Number:
+		// 能用 int64 表示的数字是整数, 其余 (小数, 指数形式和超出 int64 范围的数) 是浮点数
+		if n, err := value.Int64(); err == nil {
+			return &object.Integer{Value: n}
+		}
+		f, err := value.Float64()
+		if err != nil {
+			return newError("json_parse: unsupported number %s", value)
+		}
+		return &object.Float{Value: f}
+	case []interface{}:
+		elements := make([]object.Object, 0, len(value))
+		for _, v := range value {
+			el := jsonToObject(v)
+			if isError(el) {
+				return el
+			}
+			elements = append(elements, el)
+		}
+		return &object.Array{Elements: elements}
+	case map[string]interface{}:
+		pairs := make(map[object.HashKey]object.HashPair)
+		for k, v := range value {
+			val := jsonToObject(v)
+			if isError(val) {
+				return val
+			}
+			key := &object.String{Value: k}
+			pairs[key.HashKey()] = object.HashPair{Key: key, Value: val}
+		}
+		return &object.Hash{Pairs: pairs}
+	default:
+		return newError("json_parse: unsupported value %v", value)
+	}
+}
+
+// objectToJSON 把 monkey 对象转换为可以被 encoding/json 编码的值
+// map[string]interface{} 在编码时会按键排序, 因此输出的键顺序是确定的
+func objectToJSON(obj object.Object) (interface{}, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.NULL:
+		return nil, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.Float:
+		// JSON 不能表示 NaN 和无穷大; 沿用 Inspect 的格式, 使 2.0 不会变成整数 2
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return nil, newError("json_stringify: unsupported number %s", obj.Inspect())
+		}
+		return json.Number(obj.Inspect()), nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Array:
+		values := make([]interface{}, 0, len(obj.Elements))
+		for _, el := range obj.Elements {
+			v, errObj := objectToJSON(el)
+			if errObj != nil {
+				return nil, errObj
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case *object.Hash:
+		values := make(map[string]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return nil, newTypeError("json_stringify: hash key must be STRING, got %s", pair.Key.Type())
+			}
+			v, errObj := objectToJSON(pair.Value)
+			if errObj != nil {
+				return nil, errObj
+			}
+			values[key.Value] = v
+		}
+		return values, nil
+	default:
+		return nil, newError("json_stringify: unsupported value %s", obj.Type())
+	}
+}
+
+var jsonBuiltins = map[string]*object.Builtin{
+	"json_parse": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newArgumentError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			str, ok := args[0].(*object.String)
+			if !ok {
+				return newTypeError("argument to `json_parse` must be STRING, got %s", args[0].Type())
+			}
+			dec := json.NewDecoder(strings.NewReader(str.Value))
+			dec.UseNumber()
+			var value interface{}
+			if err := dec.Decode(&value); err != nil {
+				return newError("json_parse: %s", err)
+			}
+			// 一个字符串中只允许有一个 JSON 值
+			if _, err := dec.Token(); err != io.EOF {
+				return newError("json_parse: unexpected data after top-level value")
+			}
+			return jsonToObject(value)
+		},
+	},
+	"json_stringify": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 2,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newArgumentError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			indent := ""
+			if len(args) == 2 {
+				switch arg := args[1].(type) {
+				case *object.Integer:
+					if arg.Value < 0 {
+						return newArgumentError("json_stringify: indent must not be negative, got %d", arg.Value)
+					}
+					indent = strings.Repeat(" ", int(arg.Value))
+				case *object.String:
+					indent = arg.Value
+				default:
+					return newTypeError("second argument to `json_stringify` must be INTEGER or STRING, got %s", args[1].Type())
+				}
+			}
+			value, errObj := objectToJSON(args[0])
+			if errObj != nil {
+				return errObj
+			}
+
+			var out bytes.Buffer
+			enc := json.NewEncoder(&out)
+			enc.SetEscapeHTML(false)
+			if indent != "" {
+				enc.SetIndent("", indent)
+			}
+			if err := enc.Encode(value); err != nil {
+				return newError("json_stringify: %s", err)
+			}
+			// Encode 会在末尾追加换行
+			return &object.String{Value: strings.TrimSuffix(out.String(), "\n")}
+		},
+	},
+}
+
+func init() {
+	registerBuiltins(jsonBuiltins)
+}
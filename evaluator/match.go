@@ -0,0 +1,46 @@
+package evaluator
+
+import (
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/object"
+)
+
+// evalMatchExpression 依次尝试每个分支, 返回第一个模式匹配且守卫为真的分支的结果
+// 每个分支在独立的环境中绑定变量, 不会泄漏到外层
+func evalMatchExpression(node *ast.MatchExpression, env *object.Environment) object.Object {
+	arm, armEnv, errObj := selectMatchArm(node, env)
+	if errObj != nil {
+		return errObj
+	}
+	return Eval(arm.Body, armEnv)
+}
+
+// selectMatchArm 找到第一个匹配的分支, 返回分支和绑定了模式变量的环境
+func selectMatchArm(node *ast.MatchExpression, env *object.Environment) (*ast.MatchArm, *object.Environment, *object.Error) {
+	subject := Eval(node.Subject, env)
+	if errObj, ok := subject.(*object.Error); ok {
+		return nil, nil, errObj
+	}
+
+	for _, arm := range node.Arms {
+		armEnv := object.NewEnclosedEnvironment(env)
+		mismatch, errObj := bindPattern(arm.Pattern, subject, armEnv)
+		if errObj != nil {
+			return nil, nil, errObj
+		}
+		if mismatch != "" {
+			continue
+		}
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if errObj, ok := guard.(*object.Error); ok {
+				return nil, nil, errObj
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+		return arm, armEnv, nil
+	}
+	return nil, nil, newError("no match arm for value %s", subject.Inspect())
+}
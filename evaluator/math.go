@@ -0,0 +1,356 @@
+package evaluator
+
+import (
+	"github.com/fanyeke/monkey/object"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// random 供 rand_int 和 shuffle 使用的随机数生成器
+// 默认以当前时间为种子, 宿主程序可以通过 SeedRandom 固定种子以复现运行结果
+var random = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// SeedRandom 设置随机数种子, 相同的种子会产生相同的 rand_int 和 shuffle 结果
+func SeedRandom(seed int64) {
+	random.Lock()
+	defer random.Unlock()
+	random.Rand = rand.New(rand.NewSource(seed))
+}
+
+// isNumber 判断对象是否为整数或浮点数
+func isNumber(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.Float:
+		return true
+	}
+	return false
+}
+
+// toFloat 把整数或浮点数对象转换为 float64, 调用前需要用 isNumber 检查
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	}
+	return 0
+}
+
+// numberArgs 检查参数个数以及每个参数都是数字
+func numberArgs(name string, want int, args []object.Object) *object.Error {
+	if len(args) != want {
+		return newArgumentError("wrong number of arguments. got=%d, want=%d", len(args), want)
+	}
+	for _, arg := range args {
+		if !isNumber(arg) {
+			return newTypeError("argument to `%s` must be INTEGER or FLOAT, got %s", name, arg.Type())
+		}
+	}
+	return nil
+}
+
+// numberList min, max, sum 既可以接收多个参数, 也可以接收一个数组
+func numberList(name string, args []object.Object) ([]object.Object, *object.Error) {
+	if len(args) == 1 {
+		if arr, ok := args[0].(*object.Array); ok {
+			args = arr.Elements
+		}
+	}
+	for _, arg := range args {
+		if !isNumber(arg) {
+			return nil, newTypeError("argument to `%s` must be INTEGER or FLOAT, got %s", name, arg.Type())
+		}
+	}
+	return args, nil
+}
+
+// floatToInteger 把浮点数转换为整数对象, 超出 int64 范围时报错
+func floatToInteger(name string, f float64) object.Object {
+	if math.IsNaN(f) || f >= math.MaxInt64 || f < math.MinInt64 {
+		return newError("`%s` result out of INTEGER range: %g", name, f)
+	}
+	return &object.Integer{Value: int64(f)}
+}
+
+// roundBuiltin 生成 floor, ceil, round 这类把浮点数转为整数的内置函数
+func roundBuiltin(name string, fn func(float64) float64) *object.Builtin {
+	return &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if errObj := numberArgs(name, 1, args); errObj != nil {
+				return errObj
+			}
+			if args[0].Type() == object.INTEGER_OBJ {
+				return args[0]
+			}
+			return floatToInteger(name, fn(toFloat(args[0])))
+		},
+	}
+}
+
+// extremeBuiltin 生成 min 和 max, less 决定保留哪一个
+func extremeBuiltin(name string, less func(a, b float64) bool) *object.Builtin {
+	return &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: -1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			nums, errObj := numberList(name, args)
+			if errObj != nil {
+				return errObj
+			}
+			if len(nums) == 0 {
+				return newArgumentError("`%s` requires at least one number", name)
+			}
+			result := nums[0]
+			for _, n := range nums[1:] {
+				if less(toFloat(n), toFloat(result)) {
+					result = n
+				}
+			}
+			return result
+		},
+	}
+}
+
+// mulInt64 返回 a*b, 结果超出 int64 范围时 ok 为 false
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	c := a * b
+	// MinInt64 / -1 在 Go 中仍然是 MinInt64, 需要单独判断
+	if c/b != a || b == -1 && a == math.MinInt64 {
+		return 0, false
+	}
+	return c, true
+}
+
+// powInt64 用平方求幂计算 base 的 exp 次方, 结果超出 int64 范围时 ok 为 false
+func powInt64(base, exp int64) (int64, bool) {
+	result := int64(1)
+	for ok := true; exp > 0; {
+		if exp&1 == 1 {
+			if result, ok = mulInt64(result, base); !ok {
+				return 0, false
+			}
+		}
+		exp >>= 1
+		if exp > 0 {
+			if base, ok = mulInt64(base, base); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+var mathBuiltins = map[string]*object.Builtin{
+	"abs": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if errObj := numberArgs("abs", 1, args); errObj != nil {
+				return errObj
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				if arg.Value == math.MinInt64 {
+					return newError("`abs` result out of INTEGER range: %d", arg.Value)
+				}
+				if arg.Value < 0 {
+					return &object.Integer{Value: -arg.Value}
+				}
+				return arg
+			default:
+				return &object.Float{Value: math.Abs(toFloat(arg))}
+			}
+		},
+	},
+	"min": extremeBuiltin("min", func(a, b float64) bool { return a < b }),
+	"max": extremeBuiltin("max", func(a, b float64) bool { return a > b }),
+	"pow": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if errObj := numberArgs("pow", 2, args); errObj != nil {
+				return errObj
+			}
+			base, baseOk := args[0].(*object.Integer)
+			exp, expOk := args[1].(*object.Integer)
+			// 整数的非负整数次幂仍然是整数, 超出 INTEGER 范围时和其余情况一样返回浮点数
+			if baseOk && expOk && exp.Value >= 0 {
+				if result, ok := powInt64(base.Value, exp.Value); ok {
+					return &object.Integer{Value: result}
+				}
+			}
+			return &object.Float{Value: math.Pow(toFloat(args[0]), toFloat(args[1]))}
+		},
+	},
+	"sqrt": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if errObj := numberArgs("sqrt", 1, args); errObj != nil {
+				return errObj
+			}
+			if toFloat(args[0]) < 0 {
+				return newArgumentError("argument to `sqrt` must not be negative, got %s", args[0].Inspect())
+			}
+			root := math.Sqrt(toFloat(args[0]))
+			// 完全平方数的平方根仍然是整数
+			if n, ok := args[0].(*object.Integer); ok {
+				if r := int64(root); r*r == n.Value {
+					return &object.Integer{Value: r}
+				}
+			}
+			return &object.Float{Value: root}
+		},
+	},
+	"floor": roundBuiltin("floor", math.Floor),
+	"ceil":  roundBuiltin("ceil", math.Ceil),
+	"round": roundBuiltin("round", math.Round),
+	"clamp": &object.Builtin{
+		MinArgs: 3,
+		MaxArgs: 3,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if errObj := numberArgs("clamp", 3, args); errObj != nil {
+				return errObj
+			}
+			value, lo, hi := args[0], args[1], args[2]
+			if toFloat(lo) > toFloat(hi) {
+				return newArgumentError("`clamp` lower bound %s is greater than upper bound %s", lo.Inspect(), hi.Inspect())
+			}
+			if toFloat(value) < toFloat(lo) {
+				return lo
+			}
+			if toFloat(value) > toFloat(hi) {
+				return hi
+			}
+			return value
+		},
+	},
+	"sum": &object.Builtin{
+		MinArgs: 0,
+		MaxArgs: -1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			nums, errObj := numberList("sum", args)
+			if errObj != nil {
+				return errObj
+			}
+			var intSum int64
+			var floatSum float64
+			isFloat := false
+			for _, n := range nums {
+				switch n := n.(type) {
+				case *object.Integer:
+					intSum += n.Value
+				case *object.Float:
+					floatSum += n.Value
+					isFloat = true
+				}
+			}
+			if isFloat {
+				return &object.Float{Value: floatSum + float64(intSum)}
+			}
+			return &object.Integer{Value: intSum}
+		},
+	},
+	"gcd": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: -1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 2 {
+				return newArgumentError("wrong number of arguments. got=%d, want at least 2", len(args))
+			}
+			var result int64
+			for _, arg := range args {
+				n, ok := arg.(*object.Integer)
+				if !ok {
+					return newTypeError("argument to `gcd` must be INTEGER, got %s", arg.Type())
+				}
+				result = gcd(result, n.Value)
+			}
+			return &object.Integer{Value: result}
+		},
+	},
+	"rand_int": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 2,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			// rand_int(n) 返回 [0, n), rand_int(lo, hi) 返回 [lo, hi)
+			var lo, hi int64
+			switch len(args) {
+			case 1, 2:
+				for _, arg := range args {
+					if arg.Type() != object.INTEGER_OBJ {
+						return newTypeError("argument to `rand_int` must be INTEGER, got %s", arg.Type())
+					}
+				}
+				hi = args[len(args)-1].(*object.Integer).Value
+				if len(args) == 2 {
+					lo = args[0].(*object.Integer).Value
+				}
+			default:
+				return newArgumentError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			if lo >= hi {
+				return newArgumentError("`rand_int` range is empty: [%d, %d)", lo, hi)
+			}
+			random.Lock()
+			defer random.Unlock()
+			// hi-lo 可能超出 int64, 用 uint64 计算区间长度, 超出 Int63n 的范围时用拒绝采样
+			span := uint64(hi) - uint64(lo)
+			if span <= math.MaxInt64 {
+				return &object.Integer{Value: lo + random.Int63n(int64(span))}
+			}
+			for {
+				if n := random.Uint64(); n < span {
+					return &object.Integer{Value: int64(uint64(lo) + n)}
+				}
+			}
+		},
+	},
+	"shuffle": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newArgumentError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newTypeError("argument to `shuffle` must be ARRAY, got %s", args[0].Type())
+			}
+			// 返回一个新数组, 不修改原数组
+			elements := make([]object.Object, len(arr.Elements))
+			copy(elements, arr.Elements)
+			random.Lock()
+			defer random.Unlock()
+			random.Shuffle(len(elements), func(i, j int) {
+				elements[i], elements[j] = elements[j], elements[i]
+			})
+			return &object.Array{Elements: elements}
+		},
+	},
+}
+
+func init() {
+	registerBuiltins(mathBuiltins)
+}
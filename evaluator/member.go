@@ -0,0 +1,68 @@
+package evaluator
+
+import (
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/object"
+)
+
+// lookupMember 查找对象的成员, 只有模块和以字符串为键的哈希表有成员
+// 第二个返回值表示成员是否存在
+func lookupMember(obj object.Object, name string) (object.Object, bool) {
+	switch obj := obj.(type) {
+	case *object.Module:
+		value, ok := obj.Exports[name]
+		return value, ok
+	case *object.Hash:
+		key := &object.String{Value: name}
+		pair, ok := obj.Pairs[key.HashKey()]
+		return pair.Value, ok
+	}
+	return nil, false
+}
+
+// evalMemberExpression 成员访问 obj.name
+func evalMemberExpression(obj object.Object, name string) object.Object {
+	if value, ok := lookupMember(obj, name); ok {
+		return value
+	}
+	switch obj := obj.(type) {
+	case *object.Module:
+		return newError("module %s has no export named %s", obj.Name, name)
+	case *object.Hash:
+		// 与 h["name"] 一致, 不存在的键返回 null
+		return NULL
+	default:
+		return newTypeError("member access not supported: %s", obj.Type())
+	}
+}
+
+// prepareMethodCall 求值 obj.f(args) 要调用的函数和参数
+// 如果 obj 有成员 f 就调用它; 否则按统一函数调用语法(UFCS)把 obj 作为第一个参数调用函数 f,
+// 例如 arr.push(x) 等价于 push(arr, x)
+func prepareMethodCall(member *ast.MemberExpression, arguments []ast.Expression, env *object.Environment) (object.Object, []object.Object, map[string]object.Object, *object.Error) {
+	obj := Eval(member.Object, env)
+	if errObj, ok := obj.(*object.Error); ok {
+		return nil, nil, nil, errObj
+	}
+	name := member.Member.Value
+
+	function, found := lookupMember(obj, name)
+	if !found {
+		if module, ok := obj.(*object.Module); ok {
+			return nil, nil, nil, newError("module %s has no export named %s", module.Name, name)
+		}
+		function = evalIdentifier(member.Member, env)
+		if isError(function) {
+			return nil, nil, nil, newError("%s has no member %s and no function named %s", obj.Type(), name, name)
+		}
+	}
+
+	args, named, errObj := evalArguments(arguments, env)
+	if errObj != nil {
+		return nil, nil, nil, errObj
+	}
+	if !found {
+		args = append([]object.Object{obj}, args...)
+	}
+	return function, args, named, nil
+}
@@ -0,0 +1,124 @@
+package evaluator
+
+import (
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/object"
+	"github.com/fanyeke/monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// evalImportStatement 加载模块并把它绑定到别名上
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	path, errObj := resolveModule(node.Path.Value, env)
+	if errObj != nil {
+		return errObj
+	}
+	ctx := env.Context()
+	// 主程序文件不是通过 import 加载的, 把它也放进栈里, 这样导回主程序时也能检测到循环
+	if file := env.File(); len(ctx.Importing) == 0 && file != "" {
+		if abs, err := filepath.Abs(file); err == nil {
+			ctx.Importing = append(ctx.Importing, abs)
+			defer func() { ctx.Importing = ctx.Importing[:0] }()
+		}
+	}
+	module := loadModule(path, ctx)
+	if isError(module) {
+		return module
+	}
+	env.Set(node.Alias.Value, module)
+	return nil
+}
+
+// resolveModule 查找模块文件: 先相对于导入方所在的目录, 再依次查找 Context.ModulePaths
+func resolveModule(name string, env *object.Environment) (string, *object.Error) {
+	var candidates []string
+	if filepath.IsAbs(name) {
+		candidates = append(candidates, name)
+	} else {
+		// repl 等没有源文件的环境以当前工作目录为准
+		dir := "."
+		if file := env.File(); file != "" {
+			dir = filepath.Dir(file)
+		}
+		candidates = append(candidates, filepath.Join(dir, name))
+		for _, searchPath := range env.Context().ModulePaths {
+			candidates = append(candidates, filepath.Join(searchPath, name))
+		}
+	}
+
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		abs, err := filepath.Abs(candidate)
+		if err != nil {
+			return "", newError("import %q: %s", name, err)
+		}
+		return abs, nil
+	}
+	return "", newError("import %q: module not found", name)
+}
+
+// loadModule 在独立的环境中求值模块, 每个模块只会被求值一次
+func loadModule(path string, ctx *object.Context) object.Object {
+	if module, ok := ctx.Modules[path]; ok {
+		return module
+	}
+	for i, importing := range ctx.Importing {
+		if importing == path {
+			cycle := append(append([]string{}, ctx.Importing[i:]...), path)
+			for j := range cycle {
+				cycle[j] = filepath.Base(cycle[j])
+			}
+			return newError("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return newError("import %q: %s", path, err)
+	}
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return newError("import %q: parse errors: %s", path, strings.Join(p.Errors(), "; "))
+	}
+
+	ctx.Importing = append(ctx.Importing, path)
+	defer func() { ctx.Importing = ctx.Importing[:len(ctx.Importing)-1] }()
+
+	env := object.NewModuleEnvironment(ctx, path)
+	result := Eval(program, env)
+	if isError(result) {
+		return result
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	module := &object.Module{Name: name, Path: path, Exports: make(map[string]object.Object)}
+	for _, statement := range program.Statements {
+		if export, ok := statement.(*ast.ExportStatement); ok {
+			for _, ident := range ast.PatternNames(export.Statement.Target()) {
+				if value, ok := env.Get(ident.Value); ok {
+					module.Exports[ident.Value] = value
+				}
+			}
+		}
+	}
+	ctx.Modules[path] = module
+	return module
+}
+
+// evalModuleIndexExpression 读取模块导出的绑定, 例如 lib["name"]
+func evalModuleIndexExpression(module object.Object, index object.Object) object.Object {
+	moduleObject := module.(*object.Module)
+	name := index.(*object.String).Value
+
+	if value, ok := moduleObject.Exports[name]; ok {
+		return value
+	}
+	return newError("module %s has no export named %s", moduleObject.Name, name)
+}
@@ -0,0 +1,126 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/object"
+)
+
+// destructure 按模式把值绑定到环境中, 值的结构与模式不符时返回错误
+func destructure(pattern ast.Pattern, value object.Object, env *object.Environment) *object.Error {
+	mismatch, errObj := bindPattern(pattern, value, env)
+	if errObj != nil {
+		return errObj
+	}
+	if mismatch != "" {
+		return newError("cannot destructure %s: %s", pattern.String(), mismatch)
+	}
+	return nil
+}
+
+// bindPattern 把值与模式匹配并绑定变量
+// 第一个返回值描述值与模式不符的原因, 匹配成功时为空字符串; 第二个返回值是求值过程中的错误
+func bindPattern(pattern ast.Pattern, value object.Object, env *object.Environment) (string, *object.Error) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		// "_" 只占位, 不绑定
+		if pattern.Value != "_" {
+			env.Set(pattern.Value, value)
+		}
+		return "", nil
+	case *ast.ArrayPattern:
+		return bindArrayPattern(pattern, value, env)
+	case *ast.HashPattern:
+		return bindHashPattern(pattern, value, env)
+	case *ast.LiteralPattern:
+		return matchLiteralPattern(pattern, value, env)
+	default:
+		return "", newError("unsupported pattern: %s", pattern.String())
+	}
+}
+
+func bindArrayPattern(pattern *ast.ArrayPattern, value object.Object, env *object.Environment) (string, *object.Error) {
+	arr, ok := value.(*object.Array)
+	if !ok {
+		return fmt.Sprintf("expected ARRAY, got %s", value.Type()), nil
+	}
+
+	elements := pattern.Elements
+	var rest *ast.RestPattern
+	if n := len(elements); n > 0 {
+		if r, ok := elements[n-1].(*ast.RestPattern); ok {
+			rest = r
+			elements = elements[:n-1]
+		}
+	}
+	if rest == nil && len(arr.Elements) != len(elements) {
+		return fmt.Sprintf("expected %d elements, got %d", len(elements), len(arr.Elements)), nil
+	}
+	if rest != nil && len(arr.Elements) < len(elements) {
+		return fmt.Sprintf("expected at least %d elements, got %d", len(elements), len(arr.Elements)), nil
+	}
+
+	for i, el := range elements {
+		if mismatch, errObj := bindPattern(el, arr.Elements[i], env); mismatch != "" || errObj != nil {
+			return mismatch, errObj
+		}
+	}
+	if rest != nil {
+		remaining := make([]object.Object, len(arr.Elements)-len(elements))
+		copy(remaining, arr.Elements[len(elements):])
+		env.Set(rest.Name.Value, &object.Array{Elements: remaining})
+	}
+	return "", nil
+}
+
+func bindHashPattern(pattern *ast.HashPattern, value object.Object, env *object.Environment) (string, *object.Error) {
+	hash, ok := value.(*object.Hash)
+	if !ok {
+		return fmt.Sprintf("expected HASH, got %s", value.Type()), nil
+	}
+
+	used := make(map[object.HashKey]bool)
+	for _, pair := range pattern.Pairs {
+		key := (&object.String{Value: pair.Key}).HashKey()
+		entry, ok := hash.Pairs[key]
+		if !ok {
+			return fmt.Sprintf("missing key %q", pair.Key), nil
+		}
+		used[key] = true
+		if mismatch, errObj := bindPattern(pair.Value, entry.Value, env); mismatch != "" || errObj != nil {
+			return mismatch, errObj
+		}
+	}
+	if pattern.Rest != nil {
+		remaining := make(map[object.HashKey]object.HashPair)
+		for key, entry := range hash.Pairs {
+			if !used[key] {
+				remaining[key] = entry
+			}
+		}
+		env.Set(pattern.Rest.Name.Value, &object.Hash{Pairs: remaining})
+	}
+	return "", nil
+}
+
+// matchLiteralPattern 字面量模式不绑定变量, 只比较类型和值
+func matchLiteralPattern(pattern *ast.LiteralPattern, value object.Object, env *object.Environment) (string, *object.Error) {
+	literal := Eval(pattern.Value, env)
+	if errObj, ok := literal.(*object.Error); ok {
+		return "", errObj
+	}
+	if literal.Type() != value.Type() {
+		return fmt.Sprintf("expected %s, got %s", literal.Type(), value.Type()), nil
+	}
+	equal := false
+	switch literal := literal.(type) {
+	case *object.Float:
+		equal = literal.Value == value.(*object.Float).Value
+	case object.Hashable:
+		equal = literal.HashKey() == value.(object.Hashable).HashKey()
+	}
+	if !equal {
+		return fmt.Sprintf("expected %s, got %s", literal.Inspect(), value.Inspect()), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,159 @@
+package evaluator
+
+import (
+	"github.com/fanyeke/monkey/object"
+	"regexp"
+	"sync"
+)
+
+// regexCache 缓存已经编译过的正则, 同一个模式只编译一次
+var regexCache = struct {
+	sync.Mutex
+	compiled map[string]*object.Regex
+}{compiled: make(map[string]*object.Regex)}
+
+// compileRegex 编译正则模式, 命中缓存时直接返回
+func compileRegex(pattern string) (*object.Regex, error) {
+	regexCache.Lock()
+	defer regexCache.Unlock()
+
+	if re, ok := regexCache.compiled[pattern]; ok {
+		return re, nil
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	re := &object.Regex{Value: compiled}
+	regexCache.compiled[pattern] = re
+	return re, nil
+}
+
+// regexPattern 把正则字面量的标志位转换为 Go 正则的内联标志, 例如 /abc/i => (?i)abc
+func regexPattern(pattern string, flags string) string {
+	if flags == "" {
+		return pattern
+	}
+	return "(?" + flags + ")" + pattern
+}
+
+// toRegex 内置函数的正则参数既可以是正则对象, 也可以是字符串模式
+func toRegex(name string, arg object.Object) (*object.Regex, *object.Error) {
+	switch arg := arg.(type) {
+	case *object.Regex:
+		return arg, nil
+	case *object.String:
+		re, err := compileRegex(arg.Value)
+		if err != nil {
+			return nil, newError("invalid regex %q: %s", arg.Value, err)
+		}
+		return re, nil
+	default:
+		return nil, newTypeError("argument to `%s` must be REGEX or STRING, got %s", name, arg.Type())
+	}
+}
+
+// regexArgs 检查 (regex, string, ...) 形式的参数
+func regexArgs(name string, want int, args []object.Object) (*object.Regex, string, *object.Error) {
+	if len(args) != want {
+		return nil, "", newArgumentError("wrong number of arguments. got=%d, want=%d", len(args), want)
+	}
+	re, errObj := toRegex(name, args[0])
+	if errObj != nil {
+		return nil, "", errObj
+	}
+	str, ok := args[1].(*object.String)
+	if !ok {
+		return nil, "", newTypeError("second argument to `%s` must be STRING, got %s", name, args[1].Type())
+	}
+	return re, str.Value, nil
+}
+
+var regexBuiltins = map[string]*object.Builtin{
+	"regex": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newArgumentError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if args[0].Type() != object.STRING_OBJ {
+				return newTypeError("argument to `regex` must be STRING, got %s", args[0].Type())
+			}
+			re, errObj := toRegex("regex", args[0])
+			if errObj != nil {
+				return errObj
+			}
+			return re
+		},
+	},
+	"match": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			re, str, errObj := regexArgs("match", 2, args)
+			if errObj != nil {
+				return errObj
+			}
+			return nativeBoolToBooleanObject(re.Value.MatchString(str))
+		},
+	},
+	"find_all": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			re, str, errObj := regexArgs("find_all", 2, args)
+			if errObj != nil {
+				return errObj
+			}
+			elements := []object.Object{}
+			for _, m := range re.Value.FindAllString(str, -1) {
+				elements = append(elements, &object.String{Value: m})
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"captures": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			re, str, errObj := regexArgs("captures", 2, args)
+			if errObj != nil {
+				return errObj
+			}
+			m := re.Value.FindStringSubmatch(str)
+			if m == nil {
+				return NULL
+			}
+			// 只收集命名分组, 例如 (?P<year>\d+)
+			pairs := make(map[object.HashKey]object.HashPair)
+			for i, name := range re.Value.SubexpNames() {
+				if i == 0 || name == "" {
+					continue
+				}
+				key := &object.String{Value: name}
+				pairs[key.HashKey()] = object.HashPair{Key: key, Value: &object.String{Value: m[i]}}
+			}
+			return &object.Hash{Pairs: pairs}
+		},
+	},
+	"replace_all": &object.Builtin{
+		MinArgs: 3,
+		MaxArgs: 3,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			re, str, errObj := regexArgs("replace_all", 3, args)
+			if errObj != nil {
+				return errObj
+			}
+			repl, ok := args[2].(*object.String)
+			if !ok {
+				return newTypeError("third argument to `replace_all` must be STRING, got %s", args[2].Type())
+			}
+			return &object.String{Value: re.Value.ReplaceAllString(str, repl.Value)}
+		},
+	},
+}
+
+func init() {
+	registerBuiltins(regexBuiltins)
+}
@@ -0,0 +1,115 @@
+package evaluator
+
+import (
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/object"
+	"strings"
+)
+
+// evalSliceExpression 求值 a[start:end:step], 规则与 Python 相同:
+// 负数索引从末尾开始计数, 越界的索引会被截断, step 为负数时倒序
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	var length int
+	switch left := left.(type) {
+	case *object.Array:
+		length = len(left.Elements)
+	case *object.String:
+		length = len(left.Value)
+	default:
+		return newTypeError("slice operation not supported: %s", left.Type())
+	}
+
+	step, errObj := sliceBound(node.Step, env, 1)
+	if errObj != nil {
+		return errObj
+	}
+	if step == 0 {
+		return newError("slice step cannot be zero")
+	}
+	// 省略 start 和 end 时的默认值取决于方向
+	defaultStart, defaultEnd := int64(0), int64(length)
+	if step < 0 {
+		defaultStart, defaultEnd = int64(length)-1, -int64(length)-1
+	}
+	start, errObj := sliceBound(node.Start, env, defaultStart)
+	if errObj != nil {
+		return errObj
+	}
+	end, errObj := sliceBound(node.End, env, defaultEnd)
+	if errObj != nil {
+		return errObj
+	}
+	indexes := sliceIndexes(start, end, step, int64(length))
+
+	switch left := left.(type) {
+	case *object.Array:
+		elements := make([]object.Object, 0, len(indexes))
+		for _, i := range indexes {
+			elements = append(elements, left.Elements[i])
+		}
+		return &object.Array{Elements: elements}
+	default:
+		value := left.(*object.String).Value
+		var out strings.Builder
+		for _, i := range indexes {
+			out.WriteByte(value[i])
+		}
+		return &object.String{Value: out.String()}
+	}
+}
+
+// sliceBound 求值切片的一个部分, 省略时返回默认值
+func sliceBound(exp ast.Expression, env *object.Environment, defaultValue int64) (int64, *object.Error) {
+	if exp == nil {
+		return defaultValue, nil
+	}
+	value := Eval(exp, env)
+	if errObj, ok := value.(*object.Error); ok {
+		return 0, errObj
+	}
+	integer, ok := value.(*object.Integer)
+	if !ok {
+		return 0, newTypeError("slice index must be INTEGER, got %s", value.Type())
+	}
+	return integer.Value, nil
+}
+
+// sliceIndexes 计算切片选中的所有索引
+func sliceIndexes(start, end, step, length int64) []int64 {
+	// clamp 把索引限制在合法范围内, 负数索引先从末尾换算
+	clamp := func(idx, lo, hi int64) int64 {
+		if idx < 0 {
+			idx += length
+		}
+		if idx < lo {
+			return lo
+		}
+		if idx > hi {
+			return hi
+		}
+		return idx
+	}
+
+	var indexes []int64
+	if step > 0 {
+		start, end = clamp(start, 0, length), clamp(end, 0, length)
+		for i := start; i < end; i += step {
+			indexes = append(indexes, i)
+			// 下一个索引已经越过 end 时提前结束, 避免 i += step 溢出
+			if end-i <= step {
+				break
+			}
+		}
+	} else {
+		start, end = clamp(start, -1, length-1), clamp(end, -1, length-1)
+		for i := start; i > end; i += step {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
@@ -0,0 +1,79 @@
+package evaluator
+
+import (
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/object"
+)
+
+// tailCall 处于尾部位置的调用, 由 applyFunction 的循环执行, 不会出现在函数外面
+type tailCall struct {
+	fn    object.Object
+	args  []object.Object
+	named map[string]object.Object
+	env   *object.Environment // 调用发生的环境, 内置函数需要
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+// evalFunctionBody 求值函数体
+// 与 evalBlockStatement 相同, 但 return 语句和最后一个表达式处于尾部位置, 其中的调用会返回 tailCall 而不是立即执行
+func evalFunctionBody(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+	last := len(block.Statements) - 1
+
+	for i, statement := range block.Statements {
+		if errObj := beforeStatement(statement, env); errObj != nil {
+			return errObj
+		}
+		switch statement := statement.(type) {
+		case *ast.ReturnStatement:
+			return evalTailExpression(statement.ReturnValue, env)
+		case *ast.ExpressionStatement:
+			if i == last {
+				return evalTailExpression(statement.Expression, env)
+			}
+		}
+
+		result = Eval(statement, env)
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
+	return result
+}
+
+// evalTailExpression 求值尾部位置的表达式
+// 调用表达式返回 tailCall; if 和 match 的分支同样处于尾部位置
+func evalTailExpression(exp ast.Expression, env *object.Environment) object.Object {
+	switch exp := exp.(type) {
+	case *ast.CallExpression:
+		function, args, named, errObj := prepareCall(exp, env)
+		if errObj != nil {
+			return errObj
+		}
+		return &tailCall{fn: function, args: args, named: named, env: env}
+	case *ast.IfExpression:
+		condition := Eval(exp.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			return evalFunctionBody(exp.Consequence, env)
+		} else if exp.Alternative != nil {
+			return evalFunctionBody(exp.Alternative, env)
+		}
+		return NULL
+	case *ast.MatchExpression:
+		arm, armEnv, errObj := selectMatchArm(exp, env)
+		if errObj != nil {
+			return errObj
+		}
+		return evalTailExpression(arm.Body, armEnv)
+	default:
+		return Eval(exp, env)
+	}
+}
@@ -0,0 +1,511 @@
+// Package format 把语法树重新打印为统一风格的 Monkey 源码
+// 输出只保留必要的括号, 每条语句独占一行, 代码块按层级缩进, 源码中语句之间的空行最多保留一行
+// 语句之间和语句行尾的注释会被保留; 注释位于表达式内部时无法确定它的位置, Source 拒绝格式化
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/parser"
+	"github.com/fanyeke/monkey/token"
+	"math"
+	"strings"
+)
+
+// DEFAULT_INDENT 默认的缩进
+const DEFAULT_INDENT = "    "
+
+// Options 格式化选项
+type Options struct {
+	Indent string // 每一层缩进使用的字符串, 为空时使用 DEFAULT_INDENT
+}
+
+// Source 解析并格式化源码, 源码有语法错误或者注释无法保留时返回错误
+func Source(src string, opts Options) (string, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.ParseErrors(); len(errs) != 0 {
+		return "", fmt.Errorf("%s: %s", errs[0].Span.Start, errs[0].Message)
+	}
+	pr := newPrinter(opts, l.Comments())
+	pr.node(program)
+	if pr.misplaced != nil {
+		return "", fmt.Errorf("%s: cannot keep a comment inside an expression", pr.misplaced.Span.Start)
+	}
+	return pr.out.String(), nil
+}
+
+// Node 格式化一个语法树节点, 格式化 *ast.Program 时结果以换行结尾
+func Node(node ast.Node, opts Options) string {
+	pr := newPrinter(opts, nil)
+	pr.node(node)
+	return pr.out.String()
+}
+
+func newPrinter(opts Options, comments []token.Comment) *printer {
+	if opts.Indent == "" {
+		opts.Indent = DEFAULT_INDENT
+	}
+	return &printer{indent: opts.Indent, comments: comments}
+}
+
+func (pr *printer) node(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.Program:
+		pr.statements(node.Statements, token.Position{Line: math.MaxInt32})
+		if pr.out.Len() > 0 {
+			pr.out.WriteString("\n")
+		}
+	case ast.Statement:
+		pr.statement(node)
+	case ast.Expression:
+		pr.expression(node)
+	}
+}
+
+// 与 parser 中的优先级一致, 用于判断子表达式是否需要加括号
+const (
+	lowest = iota
+	equals
+	lessGreater
+	sum
+	product
+	prefix
+	call
+)
+
+var precedences = map[string]int{
+	"==": equals,
+	"!=": equals,
+	"<":  lessGreater,
+	">":  lessGreater,
+	"+":  sum,
+	"-":  sum,
+	"*":  product,
+	"/":  product,
+	"%":  product,
+}
+
+type printer struct {
+	out    bytes.Buffer
+	indent string
+	depth  int
+
+	comments  []token.Comment // 还没有打印的注释
+	floor     token.Position  // 已经打印的源码的结束位置, 在它之前的注释已经无法放到正确的位置
+	misplaced *token.Comment  // 第一个无法保留的注释
+}
+
+func (pr *printer) write(s ...string) {
+	for _, part := range s {
+		pr.out.WriteString(part)
+	}
+}
+
+// newline 换行并写入当前层级的缩进
+func (pr *printer) newline() {
+	pr.out.WriteString("\n")
+	pr.out.WriteString(strings.Repeat(pr.indent, pr.depth))
+}
+
+// statements 逐行打印语句和语句之间的注释, 源码中相邻两项之间有空行时保留一个空行
+// end 是语句所在代码块的结束位置, 在它之前的注释都打印在最后一条语句之后
+func (pr *printer) statements(stmts []ast.Statement, end token.Position) {
+	first := true
+	var prevEnd token.Position
+	// item 在打印下一项之前换行
+	item := func(span token.Span) {
+		if !first {
+			if prevEnd.IsValid() && span.Start.Line > prevEnd.Line+1 {
+				pr.out.WriteString("\n")
+			}
+			pr.newline()
+		}
+		first = false
+		prevEnd = span.End
+	}
+
+	for i, stmt := range stmts {
+		span := ast.SpanOf(stmt)
+		for c := pr.commentBefore(span.Start); c != nil; c = pr.commentBefore(span.Start) {
+			item(c.Span)
+			pr.write(c.Text)
+		}
+		item(span)
+		pr.statement(stmt)
+		pr.floor = span.End
+		// 与语句的结尾在同一行的注释留在行尾
+		if span.End.IsValid() {
+			next := end
+			if i+1 < len(stmts) {
+				next = ast.SpanOf(stmts[i+1]).Start
+			}
+			pr.trailingComment(span.End, next)
+		}
+	}
+	for c := pr.commentBefore(end); c != nil; c = pr.commentBefore(end) {
+		item(c.Span)
+		pr.write(c.Text)
+	}
+}
+
+// hasCommentBefore 判断下一条注释是否位于 pos 之前
+func (pr *printer) hasCommentBefore(pos token.Position) bool {
+	return len(pr.comments) > 0 && pr.comments[0].Span.Start.Before(pos)
+}
+
+// trailingComment 如果下一条注释与 after 在同一行, 并且位于 after 和 before 之间, 就把它打印在行尾
+func (pr *printer) trailingComment(after, before token.Position) {
+	if len(pr.comments) == 0 {
+		return
+	}
+	c := pr.comments[0]
+	if c.Span.Start.Line == after.Line && !c.Span.Start.Before(after) && c.Span.Start.Before(before) {
+		pr.write(" ", c.Text)
+		pr.floor = c.Span.End
+		pr.comments = pr.comments[1:]
+	}
+}
+
+// commentBefore 取出下一条位于 pos 之前的注释, 没有时返回 nil
+// 注释如果在已经打印的源码之内, 说明它位于某个表达式中间, 记录在 misplaced 中
+func (pr *printer) commentBefore(pos token.Position) *token.Comment {
+	if !pr.hasCommentBefore(pos) {
+		return nil
+	}
+	c := &pr.comments[0]
+	pr.comments = pr.comments[1:]
+	if c.Span.Start.Before(pr.floor) && pr.misplaced == nil {
+		pr.misplaced = c
+	}
+	return c
+}
+
+func (pr *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		pr.write("let ")
+		if stmt.Pattern != nil {
+			pr.pattern(stmt.Pattern)
+		} else {
+			pr.write(stmt.Name.Value)
+		}
+		if stmt.Type != nil {
+			pr.write(": ", stmt.Type.String())
+		}
+		pr.write(" = ")
+		pr.expression(stmt.Value)
+		pr.write(";")
+	case *ast.ReturnStatement:
+		pr.write("return")
+		if stmt.ReturnValue != nil {
+			pr.write(" ")
+			pr.expression(stmt.ReturnValue)
+		}
+		pr.write(";")
+	case *ast.ThrowStatement:
+		pr.write("throw ")
+		pr.expression(stmt.Value)
+		pr.write(";")
+	case *ast.ImportStatement:
+		pr.write("import ", quote(stmt.Path.Value), " as ", stmt.Alias.Value, ";")
+	case *ast.ExportStatement:
+		pr.write("export ")
+		pr.statement(stmt.Statement)
+	case *ast.ExpressionStatement:
+		pr.expression(stmt.Expression)
+		if !endsWithBlock(stmt.Expression) {
+			pr.write(";")
+		}
+	case *ast.BlockStatement:
+		pr.block(stmt)
+	}
+}
+
+// endsWithBlock 以代码块结尾的表达式语句不需要分号
+func endsWithBlock(e ast.Expression) bool {
+	switch e.(type) {
+	case *ast.IfExpression, *ast.TryExpression, *ast.MatchExpression, *ast.FunctionLiteral:
+		return true
+	}
+	return false
+}
+
+func (pr *printer) block(block *ast.BlockStatement) {
+	end := ast.SpanOf(block).End
+	if len(block.Statements) == 0 && !pr.hasCommentBefore(end) {
+		pr.write("{}")
+		return
+	}
+	pr.write("{")
+	if block.Token.End.IsValid() {
+		pr.floor = block.Token.End
+		pr.trailingComment(block.Token.End, end)
+	}
+	if len(block.Statements) == 0 && !pr.hasCommentBefore(end) {
+		pr.newline()
+		pr.write("}")
+		return
+	}
+	pr.depth++
+	pr.newline()
+	pr.statements(block.Statements, end)
+	pr.depth--
+	pr.newline()
+	pr.write("}")
+}
+
+func (pr *printer) expression(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		pr.write(e.Value)
+	case *ast.IntegerLiteral:
+		pr.write(e.Token.Literal)
+	case *ast.FloatLiteral:
+		pr.write(e.Token.Literal)
+	case *ast.Boolean:
+		pr.write(e.Token.Literal)
+	case *ast.StringLiteral:
+		pr.write(quote(e.Value))
+	case *ast.RegexLiteral:
+		pr.write(e.Token.Literal)
+	case *ast.PrefixExpression:
+		pr.write(e.Operator)
+		pr.operand(e.Right, precedenceOf(e.Right) < prefix)
+	case *ast.InfixExpression:
+		prec := precedences[e.Operator]
+		// 运算符都是左结合的, 右侧优先级相同时也需要括号
+		pr.operand(e.Left, precedenceOf(e.Left) < prec)
+		pr.write(" ", e.Operator, " ")
+		pr.operand(e.Right, precedenceOf(e.Right) <= prec)
+	case *ast.IfExpression:
+		pr.write("if (")
+		pr.expression(e.Condition)
+		pr.write(") ")
+		pr.block(e.Consequence)
+		if e.Alternative != nil {
+			pr.write(" else ")
+			pr.block(e.Alternative)
+		}
+	case *ast.TryExpression:
+		pr.write("try ")
+		pr.block(e.Block)
+		if e.Catch != nil {
+			pr.write(" catch ")
+			if e.Param != nil {
+				pr.write("(", e.Param.Value, ") ")
+			}
+			pr.block(e.Catch)
+		}
+		if e.Finally != nil {
+			pr.write(" finally ")
+			pr.block(e.Finally)
+		}
+	case *ast.FunctionLiteral:
+		pr.write("fn(")
+		for i, param := range e.Parameters {
+			if i > 0 {
+				pr.write(", ")
+			}
+			pr.pattern(param)
+		}
+		pr.write(") ")
+		if e.ReturnType != nil {
+			pr.write("-> ", e.ReturnType.String(), " ")
+		}
+		pr.block(e.Body)
+	case *ast.CallExpression:
+		pr.operand(e.Function, precedenceOf(e.Function) < call)
+		pr.write("(")
+		pr.expressions(e.Arguments)
+		pr.write(")")
+	case *ast.NamedArgument:
+		pr.write(e.Name.Value, ": ")
+		pr.expression(e.Value)
+	case *ast.ArrayLiteral:
+		pr.write("[")
+		pr.expressions(e.Elements)
+		pr.write("]")
+	case *ast.HashLiteral:
+		pr.write("{")
+		for i, key := range e.OrderedKeys() {
+			if i > 0 {
+				pr.write(", ")
+			}
+			pr.expression(key)
+			pr.write(": ")
+			pr.expression(e.Pairs[key])
+		}
+		pr.write("}")
+	case *ast.IndexExpression:
+		pr.operand(e.Left, precedenceOf(e.Left) < call)
+		pr.write("[")
+		pr.expression(e.Index)
+		pr.write("]")
+	case *ast.SliceExpression:
+		pr.operand(e.Left, precedenceOf(e.Left) < call)
+		pr.write("[")
+		pr.optional(e.Start)
+		pr.write(":")
+		pr.optional(e.End)
+		if e.Step != nil {
+			pr.write(":")
+			pr.expression(e.Step)
+		}
+		pr.write("]")
+	case *ast.MemberExpression:
+		pr.operand(e.Object, precedenceOf(e.Object) < call)
+		pr.write(".", e.Member.Value)
+	case *ast.MatchExpression:
+		pr.write("match (")
+		pr.expression(e.Subject)
+		pr.write(") {")
+		pr.depth++
+		for _, arm := range e.Arms {
+			pr.newline()
+			pr.pattern(arm.Pattern)
+			if arm.Guard != nil {
+				pr.write(" if ")
+				pr.expression(arm.Guard)
+			}
+			pr.write(" => ")
+			pr.expression(arm.Body)
+			pr.write(",")
+		}
+		pr.depth--
+		pr.newline()
+		pr.write("}")
+	case ast.Pattern:
+		pr.pattern(e)
+	}
+}
+
+// operand 打印子表达式, parens 为 true 时加上括号
+func (pr *printer) operand(e ast.Expression, parens bool) {
+	if parens {
+		pr.write("(")
+	}
+	pr.expression(e)
+	if parens {
+		pr.write(")")
+	}
+}
+
+func (pr *printer) optional(e ast.Expression) {
+	if e != nil {
+		pr.expression(e)
+	}
+}
+
+func (pr *printer) expressions(list []ast.Expression) {
+	for i, e := range list {
+		if i > 0 {
+			pr.write(", ")
+		}
+		pr.expression(e)
+	}
+}
+
+func (pr *printer) pattern(pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		pr.write(pattern.Value)
+	case *ast.RestPattern:
+		pr.write("...", pattern.Name.Value)
+	case *ast.DefaultPattern:
+		pr.pattern(pattern.Target)
+		pr.write(" = ")
+		pr.expression(pattern.Default)
+	case *ast.TypedPattern:
+		pr.pattern(pattern.Target)
+		pr.write(": ", pattern.Type.String())
+	case *ast.LiteralPattern:
+		pr.expression(pattern.Value)
+	case *ast.ArrayPattern:
+		pr.write("[")
+		for i, el := range pattern.Elements {
+			if i > 0 {
+				pr.write(", ")
+			}
+			pr.pattern(el)
+		}
+		pr.write("]")
+	case *ast.HashPattern:
+		pr.write("{")
+		for i, pair := range pattern.Pairs {
+			if i > 0 {
+				pr.write(", ")
+			}
+			// {name} 是 {name: name} 的简写
+			if id, ok := pair.Value.(*ast.Identifier); ok && id.Value == pair.Key {
+				pr.write(pair.Key)
+				continue
+			}
+			if isIdentifier(pair.Key) {
+				pr.write(pair.Key)
+			} else {
+				pr.write(quote(pair.Key))
+			}
+			pr.write(": ")
+			pr.pattern(pair.Value)
+		}
+		if pattern.Rest != nil {
+			if len(pattern.Pairs) > 0 {
+				pr.write(", ")
+			}
+			pr.write("...", pattern.Rest.Name.Value)
+		}
+		pr.write("}")
+	}
+}
+
+// precedenceOf 返回表达式作为运算对象时的优先级, 只有前缀和中缀表达式可能需要加括号
+func precedenceOf(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return precedences[e.Operator]
+	case *ast.PrefixExpression:
+		return prefix
+	}
+	return call
+}
+
+// quote 按照词法分析器支持的转义规则给字符串加上引号
+func quote(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
+// isIdentifier 判断哈希模式的键能否不加引号直接书写
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_') {
+			return false
+		}
+	}
+	return token.LookupIdent(s) == token.IDENT
+}
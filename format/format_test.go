@@ -0,0 +1,114 @@
+package format
+
+import (
+	"github.com/fanyeke/monkey/evaluator"
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/object"
+	"github.com/fanyeke/monkey/parser"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1+2*3", "let x = 1 + 2 * 3;\n"},
+		{"(1 + 2) * 3", "(1 + 2) * 3;\n"},
+		{"1 - (2 - 3); (1 - 2) - 3", "1 - (2 - 3);\n1 - 2 - 3;\n"},
+		{"-(a + b); !-x; -f(x)", "-(a + b);\n!-x;\n-f(x);\n"},
+		{"(a + b)(c)[0].d", "(a + b)(c)[0].d;\n"},
+		{`puts("a\"b\n")`, `puts("a\"b\n");` + "\n"},
+		{"xs[1:] ; xs[::2]", "xs[1:];\nxs[::2];\n"},
+		{`let {name, "full-name": n, age: years, ...rest} = h`, `let {name, "full-name": n, age: years, ...rest} = h;` + "\n"},
+		{"let f = fn(a, [b, c], d = 1, ...xs) { return a; }", "let f = fn(a, [b, c], d = 1, ...xs) {\n    return a;\n};\n"},
+		{"let x : [int]=[1];let f = fn(a: int = 1, ...r: [int]) -> {string: fn(int) -> bool} { r }",
+			"let x: [int] = [1];\nlet f = fn(a: int = 1, ...r: [int]) -> {string: fn(int) -> bool} {\n    r;\n};\n"},
+		{"if (x > 1) { x } else { }", "if (x > 1) {\n    x;\n} else {}\n"},
+		{"try { throw 1; } catch (e) { e } finally { puts(1) }",
+			"try {\n    throw 1;\n} catch (e) {\n    e;\n} finally {\n    puts(1);\n}\n"},
+		{"match (x) { 0 => \"zero\", -1 => \"minus\", n if n > 0 => n }",
+			"match (x) {\n    0 => \"zero\",\n    -1 => \"minus\",\n    n if n > 0 => n,\n}\n"},
+		{`import "lib/math" as m; export let pi = m.pi;`, "import \"lib/math\" as m;\nexport let pi = m.pi;\n"},
+		{"let a = 1;\n\n\n\nlet b = {\"k\": [1, 2.5], 2: /x+/i};\nf(b, key: a)",
+			"let a = 1;\n\nlet b = {\"k\": [1, 2.5], 2: /x+/i};\nf(b, key: a);\n"},
+		{"", ""},
+		// 注释
+		{"// header\n\n\nlet x = 1; // one\n// bye", "// header\n\nlet x = 1; // one\n// bye\n"},
+		{"let f = fn() { // why\n  // first\n  a\n  // last\n};", "let f = fn() { // why\n    // first\n    a;\n    // last\n};\n"},
+		{"if (x) { // nothing yet\n}", "if (x) { // nothing yet\n}\n"},
+		{"fn() { // todo\n}; g() // call", "fn() { // todo\n}\ng(); // call\n"},
+	}
+
+	for _, tt := range tests {
+		got, err := Source(tt.input, Options{})
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("Source(%q) wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+			continue
+		}
+		// 格式化的结果再次格式化应保持不变
+		again, err := Source(got, Options{})
+		if err != nil || again != got {
+			t.Errorf("formatting %q is not idempotent: %q, %v", got, again, err)
+		}
+	}
+}
+
+func TestSourceIndent(t *testing.T) {
+	got, err := Source("fn() { if (a) { b } }", Options{Indent: "\t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "fn() {\n\tif (a) {\n\t\tb;\n\t}\n}\n"
+	if got != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, got)
+	}
+}
+
+// TestSourceMisplacedComment 表达式内部的注释无法保留, Source 拒绝格式化而不是丢掉注释
+func TestSourceMisplacedComment(t *testing.T) {
+	inputs := []string{
+		"let xs = [1, // one\n  2];",
+		"if (x) { 1 } // after the block\nelse { 2 }",
+		"let f = fn(a, // first\n  b) { a };",
+	}
+	for _, input := range inputs {
+		if _, err := Source(input, Options{}); err == nil || !strings.Contains(err.Error(), "cannot keep a comment") {
+			t.Errorf("Source(%q): expected a misplaced comment error, got %v", input, err)
+		}
+	}
+}
+
+func TestSourceSyntaxError(t *testing.T) {
+	_, err := Source("let x = ;", Options{})
+	if err == nil || !strings.HasPrefix(err.Error(), "1:9: ") {
+		t.Errorf("expected positioned syntax error, got %v", err)
+	}
+}
+
+// TestFormattedProgramBehavesTheSame 格式化只改变书写方式, 不改变程序的结果
+func TestFormattedProgramBehavesTheSame(t *testing.T) {
+	input := `
+let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) };
+let xs = [1, 2, 3, 4];
+let [first, ...rest] = xs;
+(10 - (4 - 1)) * -(first + fib(10)) / 2 + rest[1:][0] % 3
+`
+	formatted, err := Source(input, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, b := eval(input), eval(formatted); a != b {
+		t.Errorf("formatted program has a different result. before=%s, after=%s\n%s", a, b, formatted)
+	}
+}
+
+func eval(input string) string {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	return evaluator.Eval(program, object.NewEnvironment()).Inspect()
+}
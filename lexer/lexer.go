@@ -1,23 +1,37 @@
 package lexer
 
-import "github.com/fanyeke/monkey/token"
+import (
+	"github.com/fanyeke/monkey/token"
+	"strings"
+)
 
 type Lexer struct {
 	input        string
 	position     int  // 输入字符的当前位置
 	readPosition int  // 输入字符的当前位置下一个，也就是下一个读取的位置
 	ch           byte // 当前正在读取的字符
+	line         int  // ch 所在的行, 从 1 开始
+	column       int  // ch 所在的列, 从 1 开始
+
+	prevType token.TokenType // 上一个词法单元的类型, 用于区分除号和正则字面量
+	comments []token.Comment // 已经跳过的注释
 }
 
 // New 初始化Lexer
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
 // readChar 读取下一个字符，还是存储在Lexer中
 func (l *Lexer) readChar() {
+	// 越过换行符时行号加一
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -29,10 +43,28 @@ func (l *Lexer) readChar() {
 
 // NextToken 读取一下个token,可以理解为把读取的单个字符加工包装上类型
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
-	// 跳过空格,换行等
+	// 跳过空格,换行和注释等
 	l.skipWhitespace()
 
+	start := l.pos()
+	tok := l.readToken()
+	tok.Pos = start
+	tok.End = l.pos()
+	if tok.Type == token.EOF {
+		tok.End = start
+	}
+	return tok
+}
+
+// pos 返回当前字符的位置
+func (l *Lexer) pos() token.Position {
+	return token.Position{Offset: l.position, Line: l.line, Column: l.column}
+}
+
+// readToken 从当前字符开始读取一个词法单元
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
+
 	// case中枚举单个的token,例如+-=*(){}等
 	// default中区分关键字/标识符,鉴别数字和错误处理
 	switch l.ch {
@@ -46,13 +78,21 @@ func (l *Lexer) NextToken() token.Token {
 				Type:    token.EQ,
 				Literal: literal,
 			}
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "=>"}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '+':
 		tok = newToken(token.PLUS, l.ch)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.RARROW, Literal: "->"}
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -66,9 +106,17 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '/':
+		if l.regexAllowed() {
+			tok.Type = token.REGEX
+			tok.Literal = l.readRegex()
+			l.prevType = tok.Type
+			return tok
+		}
 		tok = newToken(token.SLASH, l.ch)
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
 	case '<':
 		tok = newToken(token.LT, l.ch)
 	case '>':
@@ -80,6 +128,14 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.COMMA, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(1) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 		// 括号
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
@@ -103,16 +159,25 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			l.prevType = tok.Type
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Type = token.INT
 			tok.Literal = l.readNumber()
+			// 数字后面紧跟".数字"则为浮点数
+			if l.ch == '.' && isDigit(l.peekChar()) {
+				l.readChar()
+				tok.Type = token.FLOAT
+				tok.Literal += "." + l.readNumber()
+			}
+			l.prevType = tok.Type
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 	l.readChar()
+	l.prevType = tok.Type
 	return tok
 }
 
@@ -138,12 +203,34 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
+// skipWhitespace 跳过空白和 "//" 开头的行注释, 注释记录在 comments 中
+// 空的正则字面量没有意义, 所以 "//" 总是注释的开始
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
-		l.readChar()
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			start := l.pos()
+			for l.ch != '\n' && l.ch != 0 {
+				l.readChar()
+			}
+			text := strings.TrimRight(l.input[start.Offset:l.position], "\r")
+			end := start
+			end.Offset += len(text)
+			end.Column += len(text)
+			l.comments = append(l.comments, token.Comment{Text: text, Span: token.Span{Start: start, End: end}})
+		default:
+			return
+		}
 	}
 }
 
+// Comments 返回到目前为止跳过的注释, 读到 EOF 之后就是源代码中的所有注释
+func (l *Lexer) Comments() []token.Comment {
+	return l.comments
+}
+
 func (l *Lexer) readNumber() string {
 	position := l.position
 	for isDigit(l.ch) {
@@ -167,17 +254,75 @@ func (l *Lexer) peekChar() byte {
 	return l.input[l.readPosition]
 }
 
-// readString 读取字符串
+// peekCharAt 查看下一个字符之后第 n 个字符, peekCharAt(0) 等同于 peekChar
+func (l *Lexer) peekCharAt(n int) byte {
+	if l.readPosition+n >= len(l.input) {
+		return 0
+	}
+	return l.input[l.readPosition+n]
+}
+
+// readString 读取字符串, 支持 \" \\ \n \t \r 转义
 func (l *Lexer) readString() string {
-	// position 记录字符串开始的位置
-	position := l.position + 1
+	var out []byte
 	for {
 		// 往后读取, 直到'""'或结束
 		l.readChar()
 		if l.ch == '"' || l.ch == 0 {
 			break
 		}
+		if l.ch == '\\' {
+			switch l.peekChar() {
+			case '"', '\\':
+				l.readChar()
+			case 'n':
+				l.readChar()
+				l.ch = '\n'
+			case 't':
+				l.readChar()
+				l.ch = '\t'
+			case 'r':
+				l.readChar()
+				l.ch = '\r'
+			}
+		}
+		out = append(out, l.ch)
+	}
+	// 返回读取到的字符串(不包括引号)
+	return string(out)
+}
+
+// regexAllowed 判断当前的"/"是否为正则字面量的开头
+// 如果上一个词法单元是一个操作数(标识符、数字、字符串、右括号等), 那么"/"只能是除号
+func (l *Lexer) regexAllowed() bool {
+	switch l.prevType {
+	case token.IDENT, token.INT, token.FLOAT, token.STRING, token.REGEX,
+		token.TRUE, token.FALSE, token.RPAREN, token.RBRACKET:
+		return false
+	}
+	return true
+}
+
+// readRegex 读取正则字面量, 返回包括两侧"/"和结尾标志位在内的原始文本, 例如 /a\/b/i
+func (l *Lexer) readRegex() string {
+	position := l.position
+	for {
+		l.readChar()
+		// "\/" 是被转义的斜杠, 不作为结束符
+		if l.ch == '\\' && l.peekChar() != 0 {
+			l.readChar()
+			continue
+		}
+		if l.ch == '/' || l.ch == 0 || l.ch == '\n' {
+			break
+		}
+	}
+	if l.ch == '/' {
+		l.readChar()
+		// 结尾的标志位, 例如 i m s U
+		for isLetter(l.ch) {
+			l.readChar()
+		}
 	}
-	// 拼接返回读取到的字符串(不包括引号)
 	return l.input[position:l.position]
 }
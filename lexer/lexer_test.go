@@ -18,6 +18,7 @@ let result = add(five, ten);
 "foo bar"
 [1, 2];
 {"foo": "bar"}
+fn(a: int) -> [int] { a - 1 }
  `
 
 	tests := []struct {
@@ -73,6 +74,21 @@ let result = add(five, ten);
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.IDENT, "a"},
+		{token.COLON, ":"},
+		{token.IDENT, "int"},
+		{token.RPAREN, ")"},
+		{token.RARROW, "->"},
+		{token.LBRACKET, "["},
+		{token.IDENT, "int"},
+		{token.RBRACKET, "]"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "a"},
+		{token.MINUS, "-"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
 		{token.EOF, ""},
 	}
 	l := New(input)
@@ -88,3 +104,141 @@ let result = add(five, ten);
 		}
 	}
 }
+
+func TestRegexToken(t *testing.T) {
+	input := `let re = /a\/b[0-9]+/i; 10 / 2 / x; match(/x/, "x")`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "re"},
+		{token.ASSIGN, "="},
+		{token.REGEX, `/a\/b[0-9]+/i`},
+		{token.SEMICOLON, ";"},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SLASH, "/"},
+		{token.IDENT, "x"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "match"},
+		{token.LPAREN, "("},
+		{token.REGEX, "/x/"},
+		{token.COMMA, ","},
+		{token.STRING, "x"},
+		{token.RPAREN, ")"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	input := `"a\"b" "c\\d" "e\nf\tg" "\q"`
+	expected := []string{"a\"b", `c\d`, "e\nf\tg", `\q`}
+
+	l := New(input)
+	for i, want := range expected {
+		tok := l.NextToken()
+		if tok.Type != token.STRING {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, token.STRING, tok.Type)
+		}
+		if tok.Literal != want {
+			t.Fatalf("test[%d] - literal wrong. expected=%q, got=%q", i, want, tok.Literal)
+		}
+	}
+}
+
+func TestNumberTokens(t *testing.T) {
+	input := `3.14 % 2 10.x`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FLOAT, "3.14"},
+		{token.PERCENT, "%"},
+		{token.INT, "2"},
+		{token.INT, "10"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("test[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("test[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = \"ab\";\n  foo(1.5)"
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedSpan string
+	}{
+		{token.LET, "1:1-1:4"},
+		{token.IDENT, "1:5-1:6"},
+		{token.ASSIGN, "1:7-1:8"},
+		{token.STRING, "1:9-1:13"},
+		{token.SEMICOLON, "1:13-1:14"},
+		{token.IDENT, "2:3-2:6"},
+		{token.LPAREN, "2:6-2:7"},
+		{token.FLOAT, "2:7-2:10"},
+		{token.RPAREN, "2:10-2:11"},
+		{token.EOF, "2:11-2:11"},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		span := token.Span{Start: tok.Pos, End: tok.End}
+		if tok.Type != tt.expectedType || span.String() != tt.expectedSpan {
+			t.Fatalf("tests[%d] - wrong token. expected=%s %s, got=%s %s", i, tt.expectedType, tt.expectedSpan, tok.Type, span)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "// header\nlet x = 10 / 2; // half\r\n/a/ // regex\n\"// not a comment\"//end"
+
+	expectedTokens := []token.TokenType{
+		token.LET, token.IDENT, token.ASSIGN, token.INT, token.SLASH, token.INT, token.SEMICOLON,
+		token.REGEX, token.STRING, token.EOF,
+	}
+	l := New(input)
+	for i, expected := range expectedTokens {
+		if tok := l.NextToken(); tok.Type != expected {
+			t.Fatalf("tests[%d] - wrong token. expected=%q, got=%q (%q)", i, expected, tok.Type, tok.Literal)
+		}
+	}
+
+	expectedComments := []string{"// header@1:1-1:10", "// half@2:17-2:24", "// regex@3:5-3:13", "//end@4:19-4:24"}
+	comments := l.Comments()
+	if len(comments) != len(expectedComments) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%d", len(expectedComments), len(comments))
+	}
+	for i, c := range comments {
+		if got := c.Text + "@" + c.Span.String(); got != expectedComments[i] {
+			t.Errorf("comments[%d] wrong. expected=%q, got=%q", i, expectedComments[i], got)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Package lint 检查语法正确但很可能有错误的代码, 每条规则都可以单独开关
+//
+// 注释可以屏蔽诊断:
+//
+//	// vet:ignore                  屏蔽注释所在行和下一行的所有诊断
+//	// vet:ignore rule1,rule2      只屏蔽指定的规则
+//	// vet:ignore-file rule1       在整个文件中屏蔽指定的规则, 不指定规则时屏蔽所有规则
+package lint
+
+import (
+	"fmt"
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/parser"
+	"github.com/fanyeke/monkey/resolver"
+	"github.com/fanyeke/monkey/token"
+	"sort"
+	"strings"
+)
+
+// Diagnostic 一条规则发现的问题
+type Diagnostic struct {
+	Rule    string     `json:"rule"`
+	Message string     `json:"message"`
+	Span    token.Span `json:"span"`
+}
+
+// String 返回 "行:列: 信息 (规则)" 形式的描述
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s (%s)", d.Span.Start, d.Message, d.Rule)
+}
+
+// Rule 一条检查规则
+type Rule struct {
+	Name string
+	Doc  string
+	run  func(p *pass)
+}
+
+var rules = map[string]*Rule{}
+
+// register 注册规则, 各条规则在 rules.go 中通过 init 注册
+func register(rule *Rule) {
+	rules[rule.Name] = rule
+}
+
+// Rules 返回所有规则, 按名字排序
+func Rules() []*Rule {
+	list := make([]*Rule, 0, len(rules))
+	for _, rule := range rules {
+		list = append(list, rule)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Options 选择要运行的规则
+type Options struct {
+	Enable  []string // 不为空时只运行这些规则
+	Disable []string // 不运行这些规则
+}
+
+// selected 根据选项返回要运行的规则, 规则名不存在时返回错误
+func (opts Options) selected() ([]*Rule, error) {
+	for _, name := range append(append([]string(nil), opts.Enable...), opts.Disable...) {
+		if rules[name] == nil {
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+	}
+	var selected []*Rule
+	for _, rule := range Rules() {
+		if len(opts.Enable) > 0 && !contains(opts.Enable, rule.Name) || contains(opts.Disable, rule.Name) {
+			continue
+		}
+		selected = append(selected, rule)
+	}
+	return selected, nil
+}
+
+// Source 解析并检查源码, 源码有语法错误时返回第一个错误
+func Source(src string, opts Options) ([]Diagnostic, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.ParseErrors(); len(errs) != 0 {
+		return nil, fmt.Errorf("%s: %s", errs[0].Span.Start, errs[0].Message)
+	}
+	return Program(program, l.Comments(), opts)
+}
+
+// Program 检查语法树, comments 是源码中的注释, 用于屏蔽诊断; 结果按位置排序
+func Program(program *ast.Program, comments []token.Comment, opts Options) ([]Diagnostic, error) {
+	selected, err := opts.selected()
+	if err != nil {
+		return nil, err
+	}
+	p := &pass{program: program, analysis: resolver.Analyze(program)}
+	for _, rule := range selected {
+		p.rule = rule
+		rule.run(p)
+	}
+
+	s := newSuppressions(comments)
+	diagnostics := []Diagnostic{}
+	for _, d := range p.diagnostics {
+		if !s.suppressed(d) {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Span.Start.Before(diagnostics[j].Span.Start)
+	})
+	return diagnostics, nil
+}
+
+// pass 运行规则时的共享状态
+type pass struct {
+	program     *ast.Program
+	analysis    *resolver.Analysis
+	rule        *Rule
+	diagnostics []Diagnostic
+}
+
+func (p *pass) report(node ast.Node, format string, args ...interface{}) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Rule:    p.rule.Name,
+		Message: fmt.Sprintf(format, args...),
+		Span:    ast.SpanOf(node),
+	})
+}
+
+// inspect 先序遍历语法树, visit 返回 false 时不再进入节点的子节点
+func (p *pass) inspect(visit func(node ast.Node) bool) {
+	var walk func(node ast.Node)
+	walk = func(node ast.Node) {
+		if !visit(node) {
+			return
+		}
+		for _, child := range ast.Children(node) {
+			walk(child.Node)
+		}
+	}
+	walk(p.program)
+}
+
+const (
+	ignoreDirective     = "vet:ignore"
+	ignoreFileDirective = "vet:ignore-file"
+)
+
+// suppressions 注释中的屏蔽指令, 规则列表为 nil 表示所有规则
+type suppressions struct {
+	lines map[int][][]string
+	file  [][]string
+}
+
+func newSuppressions(comments []token.Comment) *suppressions {
+	s := &suppressions{lines: make(map[int][][]string)}
+	for _, c := range comments {
+		fields := strings.Fields(strings.TrimPrefix(c.Text, "//"))
+		if len(fields) == 0 {
+			continue
+		}
+		var names []string
+		if len(fields) > 1 {
+			names = strings.Split(fields[1], ",")
+		}
+		switch fields[0] {
+		case ignoreDirective:
+			line := c.Span.Start.Line
+			s.lines[line] = append(s.lines[line], names)
+			s.lines[line+1] = append(s.lines[line+1], names)
+		case ignoreFileDirective:
+			s.file = append(s.file, names)
+		}
+	}
+	return s
+}
+
+func (s *suppressions) suppressed(d Diagnostic) bool {
+	for _, names := range append(s.lines[d.Span.Start.Line], s.file...) {
+		if names == nil || contains(names, d.Rule) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+)
+
+func lintStrings(t *testing.T, input string, opts Options) []string {
+	t.Helper()
+	diagnostics, err := Source(input, opts)
+	if err != nil {
+		t.Fatalf("Source(%q) returned error: %v", input, err)
+	}
+	var got []string
+	for _, d := range diagnostics {
+		got = append(got, d.String())
+	}
+	return got
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let f = fn(x) { x }; let g = fn(h) { h == f }; g(1) != len", []string{
+			"1:38: comparing a function with ==; functions are only equal to themselves (function-compare)",
+			"1:48: comparing a function with !=; functions are only equal to themselves (function-compare)",
+		}},
+		{"let f = fn(x) { x }; let x = f(1); x == 1; fn(a) { a } == x", []string{
+			"1:44: comparing a function with ==; functions are only equal to themselves (function-compare)",
+		}},
+		{"if (true) { 1 }; if (!\"s\") { 2 }; if (1 < 2) { 3 }; if ([]) { 4 }; if (x > 1) { 5 }", []string{
+			"1:5: if condition is always true (constant-condition)",
+			"1:22: if condition is always false (constant-condition)",
+			"1:39: if condition is constant (constant-condition)",
+			"1:57: if condition is always true (constant-condition)",
+		}},
+		{"let f = fn() { return 1; puts(2); puts(3); }; let g = fn() { if (f()) { throw \"x\"; 1 } 2 }", []string{
+			"1:26: unreachable code after return (unreachable)",
+			"1:84: unreachable code after throw (unreachable)",
+		}},
+		{`{"a": 1, "b": 2, "a": 3, 1: 1, "1": 2, 1: 3, true: 0, x: 1, x: 2}`, []string{
+			`1:18: duplicate key "a" in hash literal (duplicate-key)`,
+			"1:40: duplicate key 1 in hash literal (duplicate-key)",
+		}},
+		{"len(); len([1], 2); puts(); exit(1, 2); min(); gcd(1); let first = fn(a, b) { a }; first(1, 2)", []string{
+			"1:1: len takes 1 argument(s), got 0 (builtin-arity)",
+			"1:8: len takes 1 argument(s), got 2 (builtin-arity)",
+			"1:29: exit takes 0 or 1 argument(s), got 2 (builtin-arity)",
+			"1:41: min takes at least 1 argument(s), got 0 (builtin-arity)",
+			"1:48: gcd takes at least 2 argument(s), got 1 (builtin-arity)",
+		}},
+	}
+
+	for _, tt := range tests {
+		got := lintStrings(t, tt.input, Options{})
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong diagnostics for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestOptionsAndSuppression(t *testing.T) {
+	input := `if (true) { len() }
+len(1, 2) // vet:ignore builtin-arity
+// vet:ignore
+if (false) { len() }
+len(1, 2, 3) // vet:ignore constant-condition`
+
+	tests := []struct {
+		opts     Options
+		expected []string
+	}{
+		{Options{}, []string{
+			"1:5: if condition is always true (constant-condition)",
+			"1:13: len takes 1 argument(s), got 0 (builtin-arity)",
+			"5:1: len takes 1 argument(s), got 3 (builtin-arity)",
+		}},
+		{Options{Enable: []string{"constant-condition"}}, []string{
+			"1:5: if condition is always true (constant-condition)",
+		}},
+		{Options{Disable: []string{"constant-condition"}}, []string{
+			"1:13: len takes 1 argument(s), got 0 (builtin-arity)",
+			"5:1: len takes 1 argument(s), got 3 (builtin-arity)",
+		}},
+	}
+	for _, tt := range tests {
+		got := lintStrings(t, input, tt.opts)
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong diagnostics with %+v.\nexpected=%q\ngot=%q", tt.opts, tt.expected, got)
+		}
+	}
+
+	if got := lintStrings(t, "// vet:ignore-file builtin-arity\nlen(); if (true) { 1 }", Options{}); len(got) != 1 {
+		t.Errorf("expected ignore-file to suppress builtin-arity only, got %q", got)
+	}
+	if _, err := Source("1", Options{Disable: []string{"nope"}}); err == nil {
+		t.Errorf("expected an error for an unknown rule")
+	}
+	if _, err := Source("let = 1;", Options{}); err == nil {
+		t.Errorf("expected a syntax error")
+	}
+}
@@ -0,0 +1,232 @@
+package lint
+
+import (
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/evaluator"
+	"github.com/fanyeke/monkey/format"
+	"strconv"
+)
+
+func init() {
+	register(&Rule{
+		Name: "function-compare",
+		Doc:  "== or != with a function operand; functions are only equal to themselves",
+		run:  functionCompare,
+	})
+	register(&Rule{
+		Name: "constant-condition",
+		Doc:  "if condition whose value does not depend on the program state",
+		run:  constantCondition,
+	})
+	register(&Rule{
+		Name: "unreachable",
+		Doc:  "statements after return or throw in the same block",
+		run:  unreachable,
+	})
+	register(&Rule{
+		Name: "duplicate-key",
+		Doc:  "hash literal with the same literal key more than once",
+		run:  duplicateKey,
+	})
+	register(&Rule{
+		Name: "builtin-arity",
+		Doc:  "builtin function called with the wrong number of arguments",
+		run:  builtinArity,
+	})
+}
+
+func functionCompare(p *pass) {
+	p.inspect(func(node ast.Node) bool {
+		infix, ok := node.(*ast.InfixExpression)
+		if !ok || infix.Operator != "==" && infix.Operator != "!=" {
+			return true
+		}
+		for _, operand := range []ast.Expression{infix.Left, infix.Right} {
+			if p.isFunction(operand) {
+				p.report(infix, "comparing a function with %s; functions are only equal to themselves", infix.Operator)
+				break
+			}
+		}
+		return true
+	})
+}
+
+// isFunction 判断表达式的值是否一定是函数: 函数字面量、绑定到函数字面量的名字或内置函数
+func (p *pass) isFunction(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.FunctionLiteral:
+		return true
+	case *ast.Identifier:
+		b := p.analysis.BindingOf(e)
+		if b == nil {
+			return isBuiltin(e.Value)
+		}
+		if let, ok := b.Node.(*ast.LetStatement); ok && let.Name == b.Decl {
+			_, isFn := let.Value.(*ast.FunctionLiteral)
+			return isFn
+		}
+	}
+	return false
+}
+
+func constantCondition(p *pass) {
+	p.inspect(func(node ast.Node) bool {
+		if ife, ok := node.(*ast.IfExpression); ok && isConstant(ife.Condition) {
+			if value, known := truthiness(ife.Condition); known {
+				p.report(ife.Condition, "if condition is always %t", value)
+			} else {
+				p.report(ife.Condition, "if condition is constant")
+			}
+		}
+		return true
+	})
+}
+
+// isConstant 判断表达式是否只由字面量组成, 或者它的真假与其中的变量无关
+func isConstant(e ast.Expression) bool {
+	if _, known := truthiness(e); known {
+		return true
+	}
+	switch e := e.(type) {
+	case *ast.PrefixExpression:
+		return isConstant(e.Right)
+	case *ast.InfixExpression:
+		return isScalar(e.Left) && isScalar(e.Right)
+	}
+	return false
+}
+
+// isScalar 判断表达式是否是数字、字符串、布尔值字面量或者由它们组成的运算
+func isScalar(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	case *ast.PrefixExpression:
+		return isScalar(e.Right)
+	case *ast.InfixExpression:
+		return isScalar(e.Left) && isScalar(e.Right)
+	}
+	return false
+}
+
+// truthiness 不求值就能确定真假的表达式: 只有 false 和 null 为假, 所以其他字面量总是为真
+func truthiness(e ast.Expression) (value bool, known bool) {
+	switch e := e.(type) {
+	case *ast.Boolean:
+		return e.Value, true
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.RegexLiteral,
+		*ast.ArrayLiteral, *ast.HashLiteral, *ast.FunctionLiteral:
+		return true, true
+	case *ast.PrefixExpression:
+		if e.Operator == "!" {
+			value, known := truthiness(e.Right)
+			return !value, known
+		}
+	}
+	return false, false
+}
+
+func unreachable(p *pass) {
+	p.inspect(func(node ast.Node) bool {
+		block, ok := node.(*ast.BlockStatement)
+		if !ok {
+			return true
+		}
+		// 只报告第一条执行不到的语句
+		for i := 0; i+1 < len(block.Statements); i++ {
+			switch block.Statements[i].(type) {
+			case *ast.ReturnStatement:
+				p.report(block.Statements[i+1], "unreachable code after return")
+			case *ast.ThrowStatement:
+				p.report(block.Statements[i+1], "unreachable code after throw")
+			default:
+				continue
+			}
+			break
+		}
+		return true
+	})
+}
+
+func duplicateKey(p *pass) {
+	p.inspect(func(node ast.Node) bool {
+		hash, ok := node.(*ast.HashLiteral)
+		if !ok {
+			return true
+		}
+		seen := make(map[string]bool)
+		for _, key := range hash.OrderedKeys() {
+			k, ok := literalKey(key)
+			if !ok {
+				continue
+			}
+			if seen[k] {
+				p.report(key, "duplicate key %s in hash literal", format.Node(key, format.Options{}))
+			}
+			seen[k] = true
+		}
+		return true
+	})
+}
+
+// literalKey 返回字面量键的比较用的字符串, 不同类型的键不相等
+func literalKey(e ast.Expression) (string, bool) {
+	switch e := e.(type) {
+	case *ast.StringLiteral:
+		return "string:" + e.Value, true
+	case *ast.IntegerLiteral:
+		return "int:" + strconv.FormatInt(e.Value, 10), true
+	case *ast.Boolean:
+		return "bool:" + strconv.FormatBool(e.Value), true
+	}
+	return "", false
+}
+
+// arity 内置函数接受的参数个数, 来自 evaluator.BuiltinArity, max 为 -1 表示没有上限
+type arity struct {
+	min, max int
+}
+
+func (a arity) String() string {
+	switch {
+	case a.max == a.min:
+		return strconv.Itoa(a.min)
+	case a.max < 0:
+		return "at least " + strconv.Itoa(a.min)
+	case a.max == a.min+1:
+		return strconv.Itoa(a.min) + " or " + strconv.Itoa(a.max)
+	}
+	return strconv.Itoa(a.min) + " to " + strconv.Itoa(a.max)
+}
+
+func builtinArity(p *pass) {
+	p.inspect(func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		// 被遮蔽的内置函数名字会解析到用户的绑定
+		ident, ok := call.Function.(*ast.Identifier)
+		if !ok || p.analysis.BindingOf(ident) != nil {
+			return true
+		}
+		min, max, ok := evaluator.BuiltinArity(ident.Value)
+		if !ok {
+			return true
+		}
+		a := arity{min, max}
+		if n := len(call.Arguments); n < a.min || a.max >= 0 && n > a.max {
+			p.report(call, "%s takes %s argument(s), got %d", ident.Value, a, n)
+		}
+		return true
+	})
+}
+
+func isBuiltin(name string) bool {
+	for _, builtin := range evaluator.BuiltinNames() {
+		if builtin == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package lsp
+
+import (
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/parser"
+	"github.com/fanyeke/monkey/resolver"
+	"github.com/fanyeke/monkey/token"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// document 编辑器中打开的一个文件, 每次修改后重新解析
+type document struct {
+	uri     string
+	version int
+	text    string
+	lines   []string // 按 "\n" 切分的各行, 用于在字节列和 UTF-16 列之间换算
+
+	program  *ast.Program
+	errors   []parser.ParseError
+	analysis *resolver.Analysis
+}
+
+func newDocument(uri string, version int, text string) *document {
+	p := parser.New(lexer.New(text))
+	program := p.ParseProgram()
+	return &document{
+		uri:      uri,
+		version:  version,
+		text:     text,
+		lines:    strings.Split(text, "\n"),
+		program:  program,
+		errors:   p.ParseErrors(),
+		analysis: resolver.Analyze(program),
+	}
+}
+
+// toProtocol 把词法分析器的位置(从 1 开始, 按字节计列)转换为协议中的位置
+func (d *document) toProtocol(pos token.Position) Position {
+	if !pos.IsValid() || pos.Line > len(d.lines) {
+		return Position{}
+	}
+	line := d.lines[pos.Line-1]
+	col := pos.Column - 1
+	if col > len(line) {
+		col = len(line)
+	}
+	return Position{Line: pos.Line - 1, Character: utf16Len(line[:col])}
+}
+
+func (d *document) toRange(span token.Span) Range {
+	return Range{Start: d.toProtocol(span.Start), End: d.toProtocol(span.End)}
+}
+
+// identRange 标识符在文档中的范围
+func (d *document) identRange(ident *ast.Identifier) Range {
+	return d.toRange(token.Span{Start: ident.Token.Pos, End: ident.Token.End})
+}
+
+// fromProtocol 把协议中的位置转换为词法分析器的位置
+func (d *document) fromProtocol(pos Position) token.Position {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return token.Position{}
+	}
+	offset := 0
+	for _, line := range d.lines[:pos.Line] {
+		offset += len(line) + 1
+	}
+	line := d.lines[pos.Line]
+	col, units := 0, 0
+	for col < len(line) && units < pos.Character {
+		r, size := utf8.DecodeRuneInString(line[col:])
+		units += len(utf16.Encode([]rune{r}))
+		col += size
+	}
+	return token.Position{Offset: offset + col, Line: pos.Line + 1, Column: col + 1}
+}
+
+// end 文档末尾的位置
+func (d *document) end() Position {
+	last := len(d.lines) - 1
+	return Position{Line: last, Character: utf16Len(d.lines[last])}
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += len(utf16.Encode([]rune{r}))
+	}
+	return n
+}
@@ -0,0 +1,122 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JSON-RPC 2.0 和 LSP 规定的错误码
+const (
+	ParseError           = -32700
+	InvalidRequest       = -32600
+	MethodNotFound       = -32601
+	InvalidParams        = -32602
+	InternalError        = -32603
+	ServerNotInitialized = -32002
+)
+
+// Message 一条 JSON-RPC 消息, 可以是请求、通知或响应
+// 请求带有 ID 和 Method, 通知只有 Method, 响应带有 ID 和 Result 或 Error
+type Message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *ResponseError   `json:"error,omitempty"`
+}
+
+// IsNotification 没有 ID 的请求是通知, 不需要响应
+func (m *Message) IsNotification() bool {
+	return m.ID == nil && m.Method != ""
+}
+
+// ResponseError 响应中的错误
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// Conn 使用 LSP 的基础协议收发消息: 每条消息前面是 Content-Length 头, 之后是一个空行和 JSON 内容
+// Write 可以被多个 goroutine 同时调用, Read 只能在一个 goroutine 中调用
+type Conn struct {
+	r  *bufio.Reader
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// NewConn 在 r 和 w 上创建连接
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	return &Conn{r: bufio.NewReader(r), w: w}
+}
+
+// Read 读取下一条消息, 连接关闭时返回 io.EOF
+func (c *Conn) Read() (*Message, error) {
+	header, err := textproto.NewReader(c.r).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+	msg := &Message{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, &ResponseError{Code: ParseError, Message: err.Error()}
+	}
+	return msg, nil
+}
+
+// Write 发送一条消息, JSONRPC 字段会被自动设置
+func (c *Conn) Write(msg *Message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(body)
+	return err
+}
+
+// Notify 发送一条通知
+func (c *Conn) Notify(method string, params interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.Write(&Message{Method: method, Params: raw})
+}
+
+// Reply 响应 id 对应的请求, err 不为 nil 时发送错误响应
+func (c *Conn) Reply(id *json.RawMessage, result interface{}, err *ResponseError) error {
+	if err != nil {
+		return c.Write(&Message{ID: id, Error: err})
+	}
+	raw, merr := json.Marshal(result)
+	if merr != nil {
+		return c.Write(&Message{ID: id, Error: &ResponseError{Code: InternalError, Message: merr.Error()}})
+	}
+	return c.Write(&Message{ID: id, Result: raw})
+}
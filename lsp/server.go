@@ -0,0 +1,455 @@
+// Package lsp 实现 Monkey 的语言服务器, 通过标准输入输出使用 Language Server Protocol 与编辑器通信
+// 支持语法错误诊断、悬停提示、跳转到定义、查找引用、补全、文档符号和格式化
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/evaluator"
+	"github.com/fanyeke/monkey/format"
+	"github.com/fanyeke/monkey/resolver"
+	"github.com/fanyeke/monkey/token"
+	"io"
+	"strings"
+)
+
+// SERVER_NAME 在 initialize 的响应中告诉编辑器的服务器名
+const SERVER_NAME = "monkey-lsp"
+
+// ErrExitWithoutShutdown 没有收到 shutdown 请求就收到了 exit 通知或连接被关闭
+var ErrExitWithoutShutdown = errors.New("lsp: exit without shutdown")
+
+type handler func(s *Server, params json.RawMessage) (interface{}, *ResponseError)
+
+// requests 需要响应的请求, notifications 不需要响应的通知
+var requests, notifications map[string]handler
+
+func init() {
+	requests = map[string]handler{
+		"initialize":                  (*Server).initialize,
+		"shutdown":                    (*Server).shutdownRequest,
+		"textDocument/hover":          (*Server).hover,
+		"textDocument/definition":     (*Server).definition,
+		"textDocument/references":     (*Server).references,
+		"textDocument/completion":     (*Server).completion,
+		"textDocument/documentSymbol": (*Server).documentSymbol,
+		"textDocument/formatting":     (*Server).formatting,
+	}
+	notifications = map[string]handler{
+		"initialized":            func(*Server, json.RawMessage) (interface{}, *ResponseError) { return nil, nil },
+		"textDocument/didOpen":   (*Server).didOpen,
+		"textDocument/didChange": (*Server).didChange,
+		"textDocument/didClose":  (*Server).didClose,
+	}
+}
+
+// Server 语言服务器, 按顺序处理连接上收到的消息
+type Server struct {
+	conn        *Conn
+	docs        map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+// NewServer 创建在 conn 上通信的服务器
+func NewServer(conn *Conn) *Server {
+	return &Server{conn: conn, docs: make(map[string]*document)}
+}
+
+// Serve 在 in 和 out 上运行语言服务器, 直到收到 exit 通知
+func Serve(in io.Reader, out io.Writer) error {
+	return NewServer(NewConn(in, out)).Run()
+}
+
+// Run 读取并处理消息, 收到 exit 通知时返回; 之前没有收到 shutdown 请求时返回 ErrExitWithoutShutdown
+func (s *Server) Run() error {
+	for {
+		msg, err := s.conn.Read()
+		if err == io.EOF {
+			if s.shutdown {
+				return nil
+			}
+			return ErrExitWithoutShutdown
+		}
+		var rpcErr *ResponseError
+		if errors.As(err, &rpcErr) {
+			// 无法解析的消息没有 id, 按规定以 null 作为 id 响应
+			if err := s.conn.Reply(nil, nil, rpcErr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if s.shutdown {
+				return nil
+			}
+			return ErrExitWithoutShutdown
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle 分发一条消息, 只有写出响应失败时返回错误
+func (s *Server) handle(msg *Message) error {
+	if msg.IsNotification() {
+		if h, ok := notifications[msg.Method]; ok && s.initialized {
+			h(s, msg.Params)
+		}
+		return nil
+	}
+	if msg.Method == "" {
+		// 服务器不发送请求, 忽略客户端发来的响应
+		return nil
+	}
+
+	h, ok := requests[msg.Method]
+	switch {
+	case !ok:
+		return s.conn.Reply(msg.ID, nil, &ResponseError{Code: MethodNotFound, Message: "method not found: " + msg.Method})
+	case !s.initialized && msg.Method != "initialize":
+		return s.conn.Reply(msg.ID, nil, &ResponseError{Code: ServerNotInitialized, Message: "server not initialized"})
+	case s.shutdown:
+		return s.conn.Reply(msg.ID, nil, &ResponseError{Code: InvalidRequest, Message: "server is shutting down"})
+	}
+	result, rpcErr := h(s, msg.Params)
+	return s.conn.Reply(msg.ID, result, rpcErr)
+}
+
+// decode 解析请求参数
+func decode(params json.RawMessage, v interface{}) *ResponseError {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &ResponseError{Code: InvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, *ResponseError) {
+	if s.initialized {
+		return nil, &ResponseError{Code: InvalidRequest, Message: "server already initialized"}
+	}
+	s.initialized = true
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:           TEXT_DOCUMENT_SYNC_FULL,
+			HoverProvider:              true,
+			DefinitionProvider:         true,
+			ReferencesProvider:         true,
+			CompletionProvider:         &CompletionOptions{},
+			DocumentSymbolProvider:     true,
+			DocumentFormattingProvider: true,
+		},
+		ServerInfo: ServerInfo{Name: SERVER_NAME},
+	}, nil
+}
+
+func (s *Server) shutdownRequest(params json.RawMessage) (interface{}, *ResponseError) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) (interface{}, *ResponseError) {
+	var p DidOpenTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	s.update(p.TextDocument.URI, p.TextDocument.Version, p.TextDocument.Text)
+	return nil, nil
+}
+
+func (s *Server) didChange(params json.RawMessage) (interface{}, *ResponseError) {
+	var p DidChangeTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	if len(p.ContentChanges) == 0 {
+		return nil, nil
+	}
+	// 全量同步时最后一次修改就是完整的文档
+	s.update(p.TextDocument.URI, p.TextDocument.Version, p.ContentChanges[len(p.ContentChanges)-1].Text)
+	return nil, nil
+}
+
+func (s *Server) didClose(params json.RawMessage) (interface{}, *ResponseError) {
+	var p DidCloseTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.docs, p.TextDocument.URI)
+	// 清除编辑器中这个文件的诊断
+	s.conn.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	return nil, nil
+}
+
+// update 重新解析文档并发布诊断
+func (s *Server) update(uri string, version int, text string) {
+	d := newDocument(uri, version, text)
+	s.docs[uri] = d
+
+	diagnostics := []Diagnostic{}
+	for _, e := range d.errors {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    d.toRange(e.Span),
+			Severity: SeverityError,
+			Source:   "monkey",
+			Message:  e.Message,
+		})
+	}
+	// 有语法错误时名字解析的结果不可靠, 只报告语法错误
+	if len(d.errors) == 0 {
+		for _, r := range d.analysis.Diagnostics() {
+			severity := SeverityWarning
+			if r.Severity == resolver.Error {
+				severity = SeverityError
+			}
+			diagnostics = append(diagnostics, Diagnostic{
+				Range:    d.toRange(r.Span),
+				Severity: severity,
+				Source:   "monkey",
+				Message:  r.Message,
+			})
+		}
+	}
+	s.conn.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Version: version, Diagnostics: diagnostics})
+}
+
+// lookup 找到请求所在的文档和位置上的标识符, 标识符可能为 nil
+func (s *Server) lookup(p TextDocumentPositionParams) (*document, *ast.Identifier, *ResponseError) {
+	d, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, nil, &ResponseError{Code: InvalidParams, Message: "unknown document " + p.TextDocument.URI}
+	}
+	return d, d.analysis.IdentifierAt(d.fromProtocol(p.Position)), nil
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, *ResponseError) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, ident, err := s.lookup(p)
+	if err != nil || ident == nil {
+		return nil, err
+	}
+
+	var text string
+	if b := d.analysis.BindingOf(ident); b != nil {
+		text = describe(b)
+	} else if isBuiltin(ident.Value) {
+		text = "(builtin) " + ident.Value
+	} else {
+		return nil, nil
+	}
+	r := d.identRange(ident)
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: "```monkey\n" + text + "\n```"}, Range: &r}, nil
+}
+
+// describe 悬停时显示的绑定的定义
+func describe(b *resolver.Binding) string {
+	switch node := b.Node.(type) {
+	case *ast.LetStatement:
+		if fn, ok := node.Value.(*ast.FunctionLiteral); ok && node.Name != nil {
+			return "let " + b.Name + " = " + signature(fn)
+		}
+		return firstLine(format.Node(node, format.Options{}))
+	case *ast.FunctionLiteral:
+		return "(parameter) " + b.Name + " of " + signature(node)
+	case *ast.ImportStatement:
+		return format.Node(node, format.Options{})
+	}
+	return fmt.Sprintf("(%s) %s", b.Kind, b.Name)
+}
+
+// signature 函数的参数列表和返回值类型, 例如 fn(a, b = 1, ...rest)、fn(a: int) -> int
+func signature(fn *ast.FunctionLiteral) string {
+	params := []string{}
+	for _, param := range fn.Parameters {
+		params = append(params, format.Node(param, format.Options{}))
+	}
+	if fn.ReturnType != nil {
+		return "fn(" + strings.Join(params, ", ") + ") -> " + fn.ReturnType.String()
+	}
+	return "fn(" + strings.Join(params, ", ") + ")"
+}
+
+// firstLine 多行的定义只显示第一行
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i] + " ..."
+	}
+	return s
+}
+
+func isBuiltin(name string) bool {
+	for _, builtin := range evaluator.BuiltinNames() {
+		if builtin == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) definition(params json.RawMessage) (interface{}, *ResponseError) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, ident, err := s.lookup(p)
+	if err != nil || ident == nil {
+		return nil, err
+	}
+	b := d.analysis.BindingOf(ident)
+	if b == nil {
+		return nil, nil
+	}
+	return Location{URI: d.uri, Range: d.identRange(b.Decl)}, nil
+}
+
+func (s *Server) references(params json.RawMessage) (interface{}, *ResponseError) {
+	var p ReferenceParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, ident, err := s.lookup(p.TextDocumentPositionParams)
+	if err != nil || ident == nil {
+		return nil, err
+	}
+	b := d.analysis.BindingOf(ident)
+	if b == nil {
+		return nil, nil
+	}
+	locations := []Location{}
+	if p.Context.IncludeDeclaration {
+		locations = append(locations, Location{URI: d.uri, Range: d.identRange(b.Decl)})
+	}
+	for _, ref := range b.References {
+		locations = append(locations, Location{URI: d.uri, Range: d.identRange(ref)})
+	}
+	return locations, nil
+}
+
+func (s *Server) completion(params json.RawMessage) (interface{}, *ResponseError) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, _, err := s.lookup(p)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []CompletionItem{}
+	seen := make(map[string]bool)
+	for _, b := range d.analysis.VisibleAt(d.fromProtocol(p.Position)) {
+		seen[b.Name] = true
+		items = append(items, CompletionItem{Label: b.Name, Kind: completionKind(b), Detail: describe(b)})
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		if !seen[name] {
+			items = append(items, CompletionItem{Label: name, Kind: CompletionFunction, Detail: "builtin"})
+		}
+	}
+	for _, kw := range token.Keywords() {
+		items = append(items, CompletionItem{Label: kw, Kind: CompletionKeyword})
+	}
+	return CompletionList{Items: items}, nil
+}
+
+func completionKind(b *resolver.Binding) int {
+	switch node := b.Node.(type) {
+	case *ast.ImportStatement:
+		return CompletionModule
+	case *ast.LetStatement:
+		if _, ok := node.Value.(*ast.FunctionLiteral); ok && node.Name != nil {
+			return CompletionFunction
+		}
+	}
+	return CompletionVariable
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (interface{}, *ResponseError) {
+	var p DocumentSymbolParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, &ResponseError{Code: InvalidParams, Message: "unknown document " + p.TextDocument.URI}
+	}
+	return d.symbols(d.program.Statements), nil
+}
+
+// symbols 返回语句列表中 let 和 import 定义的符号, 函数中定义的名字作为函数的子符号
+func (d *document) symbols(stmts []ast.Statement) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, stmt := range stmts {
+		if export, ok := stmt.(*ast.ExportStatement); ok && export.Statement != nil {
+			stmt = export.Statement
+		}
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			names := []*ast.Identifier{stmt.Name}
+			if stmt.Pattern != nil {
+				names = ast.PatternNames(stmt.Pattern)
+			}
+			for _, name := range names {
+				symbol := DocumentSymbol{
+					Name:           name.Value,
+					Kind:           SymbolVariable,
+					Range:          d.toRange(ast.SpanOf(stmt)),
+					SelectionRange: d.identRange(name),
+				}
+				if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && stmt.Pattern == nil {
+					symbol.Kind = SymbolFunction
+					symbol.Detail = signature(fn)
+					if fn.Body != nil {
+						symbol.Children = d.symbols(fn.Body.Statements)
+					}
+				}
+				symbols = append(symbols, symbol)
+			}
+		case *ast.ImportStatement:
+			symbols = append(symbols, DocumentSymbol{
+				Name:           stmt.Alias.Value,
+				Detail:         stmt.Path.Value,
+				Kind:           SymbolModule,
+				Range:          d.toRange(ast.SpanOf(stmt)),
+				SelectionRange: d.identRange(stmt.Alias),
+			})
+		}
+	}
+	return symbols
+}
+
+// formatting 用一次编辑替换整个文档, 文档有语法错误或已经是格式化后的样子时不做修改
+func (s *Server) formatting(params json.RawMessage) (interface{}, *ResponseError) {
+	var p DocumentFormattingParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, &ResponseError{Code: InvalidParams, Message: "unknown document " + p.TextDocument.URI}
+	}
+	if len(d.errors) != 0 {
+		return []TextEdit{}, nil
+	}
+
+	opts := format.Options{Indent: "\t"}
+	if p.Options.InsertSpaces && p.Options.TabSize > 0 {
+		opts.Indent = strings.Repeat(" ", p.Options.TabSize)
+	}
+	// 从源代码格式化才能保留注释, 注释位置无法保留时不做修改
+	formatted, err := format.Source(d.text, opts)
+	if err != nil || formatted == d.text {
+		return []TextEdit{}, nil
+	}
+	return []TextEdit{{Range: Range{End: d.end()}, NewText: formatted}}, nil
+}
@@ -0,0 +1,412 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// client 测试用的 JSON-RPC 客户端, 通过管道与同一进程中的服务器通信
+type client struct {
+	t        *testing.T
+	conn     *Conn
+	incoming chan *Message
+	done     chan error
+	nextID   int
+	// notifications 等待响应时收到的服务器通知
+	notifications []*Message
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{
+		t:        t,
+		conn:     NewConn(clientIn, clientOut),
+		incoming: make(chan *Message, 16),
+		done:     make(chan error, 1),
+	}
+	go func() {
+		err := Serve(serverIn, serverOut)
+		serverOut.Close()
+		c.done <- err
+	}()
+	go func() {
+		for {
+			msg, err := c.conn.Read()
+			if err != nil {
+				close(c.incoming)
+				return
+			}
+			c.incoming <- msg
+		}
+	}()
+	return c
+}
+
+// call 发送请求并等待响应, 把结果解析到 result 中
+func (c *client) call(method string, params interface{}, result interface{}) *ResponseError {
+	c.t.Helper()
+	c.nextID++
+	id := json.RawMessage(strings.TrimSpace(string(mustMarshal(c.t, c.nextID))))
+	if err := c.conn.Write(&Message{ID: &id, Method: method, Params: mustMarshal(c.t, params)}); err != nil {
+		c.t.Fatalf("writing %s: %v", method, err)
+	}
+	for msg := range c.incoming {
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		if string(*msg.ID) != string(id) {
+			c.t.Fatalf("response id %s, want %s", *msg.ID, id)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("decoding %s result %s: %v", method, msg.Result, err)
+			}
+		}
+		return nil
+	}
+	c.t.Fatalf("connection closed while waiting for %s", method)
+	return nil
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	if err := c.conn.Notify(method, params); err != nil {
+		c.t.Fatalf("writing %s: %v", method, err)
+	}
+}
+
+// diagnostics 等待服务器发布下一组诊断
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+	for msg := range c.incoming {
+		if msg.Method == "textDocument/publishDiagnostics" {
+			var p PublishDiagnosticsParams
+			if err := json.Unmarshal(msg.Params, &p); err != nil {
+				c.t.Fatal(err)
+			}
+			return p
+		}
+	}
+	c.t.Fatal("connection closed while waiting for diagnostics")
+	return PublishDiagnosticsParams{}
+}
+
+func (c *client) initialize() {
+	c.t.Helper()
+	if err := c.call("initialize", map[string]interface{}{}, nil); err != nil {
+		c.t.Fatalf("initialize failed: %v", err)
+	}
+	c.notify("initialized", struct{}{})
+}
+
+func (c *client) open(uri, text string) PublishDiagnosticsParams {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "monkey", Version: 1, Text: text},
+	})
+	return c.diagnostics()
+}
+
+// close 按规范关闭服务器, 返回 Serve 的结果
+func (c *client) close() error {
+	c.t.Helper()
+	if err := c.call("shutdown", nil, nil); err != nil {
+		c.t.Fatalf("shutdown failed: %v", err)
+	}
+	c.notify("exit", nil)
+	return <-c.done
+}
+
+func mustMarshal(t *testing.T, v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func at(uri string, line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{line, character}}
+}
+
+func TestLifecycle(t *testing.T) {
+	c := newClient(t)
+	if err := c.call("textDocument/hover", at("file:///a.mk", 0, 0), nil); err == nil || err.Code != ServerNotInitialized {
+		t.Errorf("expected ServerNotInitialized before initialize, got %v", err)
+	}
+
+	var result InitializeResult
+	if err := c.call("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, &result); err != nil {
+		t.Fatal(err)
+	}
+	caps := result.Capabilities
+	if caps.TextDocumentSync != TEXT_DOCUMENT_SYNC_FULL || !caps.HoverProvider || !caps.DefinitionProvider ||
+		!caps.ReferencesProvider || caps.CompletionProvider == nil || !caps.DocumentSymbolProvider || !caps.DocumentFormattingProvider {
+		t.Errorf("missing capabilities: %+v", caps)
+	}
+	if result.ServerInfo.Name != SERVER_NAME {
+		t.Errorf("wrong server name %q", result.ServerInfo.Name)
+	}
+	if err := c.call("textDocument/rename", nil, nil); err == nil || err.Code != MethodNotFound {
+		t.Errorf("expected MethodNotFound, got %v", err)
+	}
+	if err := c.close(); err != nil {
+		t.Errorf("Serve returned %v after shutdown and exit", err)
+	}
+
+	c = newClient(t)
+	c.initialize()
+	c.notify("exit", nil)
+	if err := <-c.done; err != ErrExitWithoutShutdown {
+		t.Errorf("expected ErrExitWithoutShutdown, got %v", err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+
+	uri := "file:///bad.mk"
+	got := c.open(uri, "let a = 1;\nlet = 2;")
+	if got.URI != uri || len(got.Diagnostics) == 0 {
+		t.Fatalf("expected diagnostics for %s, got %+v", uri, got)
+	}
+	d := got.Diagnostics[0]
+	expected := Range{Start: Position{1, 4}, End: Position{1, 5}}
+	if d.Range != expected || d.Severity != SeverityError || d.Source != "monkey" ||
+		d.Message != "expected next token to be IDENT, got = install" {
+		t.Errorf("wrong diagnostic %+v", d)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let a = 1;\nlet b = a + c;"}},
+	})
+	got = c.diagnostics()
+	expectedNames := []Diagnostic{
+		{Range: Range{Start: Position{1, 4}, End: Position{1, 5}}, Severity: SeverityWarning, Source: "monkey", Message: "b declared and not used"},
+		{Range: Range{Start: Position{1, 12}, End: Position{1, 13}}, Severity: SeverityError, Source: "monkey", Message: "identifier not found: c"},
+	}
+	if got.Version != 2 || !reflect.DeepEqual(got.Diagnostics, expectedNames) {
+		t.Errorf("expected name diagnostics, got %+v", got)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 3},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let a = 1;\nputs(a);"}},
+	})
+	if got := c.diagnostics(); got.Version != 3 || len(got.Diagnostics) != 0 {
+		t.Errorf("expected diagnostics to be cleared, got %+v", got)
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+	if got := c.diagnostics(); len(got.Diagnostics) != 0 {
+		t.Errorf("expected empty diagnostics after close, got %+v", got)
+	}
+	if err := c.close(); err != nil {
+		t.Error(err)
+	}
+}
+
+const program = `let add = fn(a, b) {
+  let total = a + b;
+  total
+};
+let x = add(1, 2);
+puts(x, "é", x);`
+
+func TestNavigation(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+	uri := "file:///nav.mk"
+	c.open(uri, program)
+
+	hovers := []struct {
+		line, character int
+		expected        string
+	}{
+		{4, 9, "let add = fn(a, b)"},
+		{1, 14, "(parameter) a of fn(a, b)"},
+		{4, 5, "let x = add(1, 2);"},
+		{5, 1, "(builtin) puts"},
+		// 光标紧跟在标识符后面, 列按 UTF-16 计算
+		{5, 14, "let x = add(1, 2);"},
+	}
+	for _, tt := range hovers {
+		var hover *Hover
+		if err := c.call("textDocument/hover", at(uri, tt.line, tt.character), &hover); err != nil {
+			t.Fatal(err)
+		}
+		if hover == nil {
+			t.Errorf("no hover at %d:%d", tt.line, tt.character)
+			continue
+		}
+		if expected := "```monkey\n" + tt.expected + "\n```"; hover.Contents.Value != expected {
+			t.Errorf("hover at %d:%d wrong. expected=%q, got=%q", tt.line, tt.character, expected, hover.Contents.Value)
+		}
+	}
+
+	var hover *Hover
+	if err := c.call("textDocument/hover", at(uri, 1, 13), &hover); err != nil || hover != nil {
+		t.Errorf("expected no hover on whitespace, got %+v, %v", hover, err)
+	}
+
+	var def *Location
+	if err := c.call("textDocument/definition", at(uri, 2, 3), &def); err != nil {
+		t.Fatal(err)
+	}
+	if def == nil || def.URI != uri || def.Range != (Range{Position{1, 6}, Position{1, 11}}) {
+		t.Errorf("wrong definition of total: %+v", def)
+	}
+	def = nil
+	if err := c.call("textDocument/definition", at(uri, 5, 2), &def); err != nil || def != nil {
+		t.Errorf("expected no definition for builtin, got %+v, %v", def, err)
+	}
+
+	var refs []Location
+	params := ReferenceParams{TextDocumentPositionParams: at(uri, 5, 5), Context: ReferenceContext{IncludeDeclaration: true}}
+	if err := c.call("textDocument/references", params, &refs); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Range{{Position{4, 4}, Position{4, 5}}, {Position{5, 5}, Position{5, 6}}, {Position{5, 13}, Position{5, 14}}}
+	if len(refs) != len(expected) {
+		t.Fatalf("wrong references of x: %+v", refs)
+	}
+	for i, ref := range refs {
+		if ref.Range != expected[i] {
+			t.Errorf("reference %d wrong. expected=%+v, got=%+v", i, expected[i], ref.Range)
+		}
+	}
+	params.Context.IncludeDeclaration = false
+	params.Position = Position{0, 16}
+	if err := c.call("textDocument/references", params, &refs); err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 || refs[0].Range != (Range{Position{1, 18}, Position{1, 19}}) {
+		t.Errorf("wrong references of parameter b: %+v", refs)
+	}
+
+	if err := c.close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+	uri := "file:///complete.mk"
+	c.open(uri, program)
+
+	var list CompletionList
+	// 函数体的开头: 参数和外层的名字可用, total 还没有定义
+	if err := c.call("textDocument/completion", at(uri, 1, 2), &list); err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[string]int)
+	for _, item := range list.Items {
+		kinds[item.Label] = item.Kind
+	}
+	expected := map[string]int{
+		"a":    CompletionVariable,
+		"b":    CompletionVariable,
+		"add":  CompletionFunction,
+		"puts": CompletionFunction,
+		"let":  CompletionKeyword,
+		"fn":   CompletionKeyword,
+	}
+	for label, kind := range expected {
+		if kinds[label] != kind {
+			t.Errorf("completion %q has kind %d, want %d", label, kinds[label], kind)
+		}
+	}
+	if _, ok := kinds["total"]; ok {
+		t.Errorf("total should not be visible before its definition")
+	}
+
+	// 顶层: 函数的参数不可见
+	if err := c.call("textDocument/completion", at(uri, 5, 0), &list); err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range list.Items {
+		if item.Label == "a" || item.Label == "total" {
+			t.Errorf("%s should not be visible at the top level", item.Label)
+		}
+	}
+	if err := c.close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDocumentSymbolsAndFormatting(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+	uri := "file:///symbols.mk"
+	c.open(uri, program+"\nimport \"lib\" as lib;\nlet [p, q] = [1, 2]")
+
+	var symbols []DocumentSymbol
+	if err := c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &symbols); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range symbols {
+		names = append(names, s.Name)
+	}
+	if strings.Join(names, " ") != "add x lib p q" {
+		t.Fatalf("wrong symbols %v", names)
+	}
+	add := symbols[0]
+	if add.Kind != SymbolFunction || add.Detail != "fn(a, b)" || len(add.Children) != 1 || add.Children[0].Name != "total" {
+		t.Errorf("wrong symbol for add: %+v", add)
+	}
+	if add.Range != (Range{Position{0, 0}, Position{3, 1}}) || add.SelectionRange != (Range{Position{0, 4}, Position{0, 7}}) {
+		t.Errorf("wrong ranges for add: %+v %+v", add.Range, add.SelectionRange)
+	}
+	if symbols[1].Kind != SymbolVariable || symbols[2].Kind != SymbolModule || symbols[2].Detail != "lib" {
+		t.Errorf("wrong symbols %+v", symbols[1:3])
+	}
+
+	uri = "file:///format.mk"
+	c.open(uri, "let f=fn(x){x*2};\nf(1)")
+	var edits []TextEdit
+	params := DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: uri}, Options: FormattingOptions{TabSize: 2, InsertSpaces: true}}
+	if err := c.call("textDocument/formatting", params, &edits); err != nil {
+		t.Fatal(err)
+	}
+	expected := TextEdit{Range: Range{End: Position{1, 4}}, NewText: "let f = fn(x) {\n  x * 2;\n};\nf(1);\n"}
+	if len(edits) != 1 || edits[0] != expected {
+		t.Errorf("wrong edits %+v", edits)
+	}
+
+	// 格式化保留注释
+	c.open(uri, "// header\nlet x = 1; // trailing")
+	if err := c.call("textDocument/formatting", params, &edits); err != nil {
+		t.Fatal(err)
+	}
+	expected = TextEdit{Range: Range{End: Position{1, 22}}, NewText: "// header\nlet x = 1; // trailing\n"}
+	if len(edits) != 1 || edits[0] != expected {
+		t.Errorf("wrong edits for a document with comments %+v", edits)
+	}
+
+	// 注释在表达式中间时无法保留, 不做格式化
+	c.open(uri, "let x = 1 + // why\n 2")
+	if err := c.call("textDocument/formatting", params, &edits); err != nil || len(edits) != 0 {
+		t.Errorf("expected no edits when a comment cannot be kept, got %+v, %v", edits, err)
+	}
+
+	// 有语法错误的文档不做格式化
+	c.open(uri, "let f = ;")
+	if err := c.call("textDocument/formatting", params, &edits); err != nil || len(edits) != 0 {
+		t.Errorf("expected no edits for a document with errors, got %+v, %v", edits, err)
+	}
+	if err := c.close(); err != nil {
+		t.Error(err)
+	}
+}
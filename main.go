@@ -1,19 +1,10 @@
 package main
 
 import (
-	"fmt"
-	"github.com/fanyeke/monkey/repl"
+	"github.com/fanyeke/monkey/cli"
 	"os"
-	user2 "os/user"
 )
 
 func main() {
-	user, err := user2.Current()
-	if err != nil {
-		panic(err)
-	}
-
-	fmt.Printf("Hello %s!This is the Monkey programming language!\n", user.Username)
-	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	os.Exit(cli.Run(os.Args[1:], cli.IO{In: os.Stdin, Out: os.Stdout, Err: os.Stderr}))
 }
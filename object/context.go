@@ -0,0 +1,73 @@
+package object
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Context 一次求值共享的运行环境, 由宿主程序提供
+// 内置函数的输入输出都经过这里, 宿主程序(repl, 测试, 嵌入的调用方)可以替换成自己的读写器
+type Context struct {
+	In  *bufio.Reader // input 读取的输入
+	Out io.Writer     // puts 和 print 的输出
+	Err io.Writer     // eprint 的输出
+
+	// FileRoot 文件系统内置函数允许访问的根目录, 为空时文件系统内置函数被禁用
+	FileRoot string
+
+	// ModulePaths 在导入方所在目录找不到模块时, import 依次查找的目录
+	ModulePaths []string
+	// Modules 已经加载的模块, 以模块文件的绝对路径为键, 每个模块只求值一次
+	Modules map[string]*Module
+	// Importing 正在求值的模块路径栈, 用于检测循环导入
+	Importing []string
+
+	// Hook 不为 nil 时, 求值器在执行语句和调用函数时通知它
+	Hook Hook
+}
+
+// NewContext 使用给定的输入输出创建 Context, in 会被包装为 bufio.Reader
+func NewContext(in io.Reader, out io.Writer, err io.Writer) *Context {
+	ctx := &Context{Out: out, Err: err, Modules: make(map[string]*Module)}
+	ctx.SetInput(in)
+	return ctx
+}
+
+// SetInput 替换输入, 如果 in 已经是 bufio.Reader 则直接使用, 这样宿主程序可以和脚本共享同一个缓冲
+func (c *Context) SetInput(in io.Reader) {
+	if r, ok := in.(*bufio.Reader); ok {
+		c.In = r
+		return
+	}
+	c.In = bufio.NewReader(in)
+}
+
+// AllowFiles 启用文件系统内置函数, 脚本只能访问 root 目录及其子目录
+func (c *Context) AllowFiles(root string) error {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	// 解析符号链接, 之后的路径检查都基于真实路径
+	abs, err = filepath.EvalSymlinks(abs)
+	if err != nil {
+		return err
+	}
+	c.FileRoot = abs
+	return nil
+}
+
+var (
+	stdinOnce   sync.Once
+	stdinReader *bufio.Reader
+)
+
+// defaultContext 没有指定 Context 时使用标准输入输出
+// 所有默认 Context 共享同一个标准输入的缓冲, 否则各自缓冲的数据会被其他 Context 读不到
+func defaultContext() *Context {
+	stdinOnce.Do(func() { stdinReader = bufio.NewReader(os.Stdin) })
+	return NewContext(stdinReader, os.Stdout, os.Stderr)
+}
@@ -1,13 +1,17 @@
 package object
 
+import "sort"
+
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, outer: nil, ctx: defaultContext()}
 }
 
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+	ctx   *Context // 同一次求值中的所有环境共享同一个 Context
+	file  string   // 最外层环境对应的源文件, 用于解析相对路径的 import
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -22,8 +26,68 @@ func (e *Environment) Set(name string, val Object) Object {
 	return val
 }
 
+// Names 返回环境及其外层环境中定义的所有名字, 按字母顺序排列
+func (e *Environment) Names() []string {
+	seen := make(map[string]bool)
+	for env := e; env != nil; env = env.outer {
+		for name := range env.store {
+			seen[name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Outer 返回外层环境, 最外层环境返回 nil
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Locals 返回只在当前环境中定义的名字和值, 不包括外层环境
+func (e *Environment) Locals() map[string]Object {
+	locals := make(map[string]Object, len(e.store))
+	for name, val := range e.store {
+		locals[name] = val
+	}
+	return locals
+}
+
+// Context 返回环境所属的运行环境
+func (e *Environment) Context() *Context {
+	return e.ctx
+}
+
+// SetContext 替换运行环境, 应当在求值开始之前对最外层环境调用
+func (e *Environment) SetContext(ctx *Context) {
+	e.ctx = ctx
+}
+
+// File 返回环境所属的源文件路径, 没有对应文件(例如 repl)时返回空字符串
+func (e *Environment) File() string {
+	for env := e; env != nil; env = env.outer {
+		if env.file != "" {
+			return env.file
+		}
+	}
+	return ""
+}
+
+// SetFile 设置环境对应的源文件路径
+func (e *Environment) SetFile(path string) {
+	e.file = path
+}
+
+// NewModuleEnvironment 为模块创建一个独立的最外层环境, 与导入方共享同一个 Context
+func NewModuleEnvironment(ctx *Context, path string) *Environment {
+	s := make(map[string]Object)
+	return &Environment{store: s, ctx: ctx, file: path}
+}
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
-	return env
+	s := make(map[string]Object)
+	return &Environment{store: s, outer: outer, ctx: outer.ctx}
 }
@@ -0,0 +1,14 @@
+package object
+
+import "github.com/fanyeke/monkey/ast"
+
+// Hook 求值过程中的回调, 安装在 Context.Hook 上, 调试器等工具借此观察和控制程序的执行
+// Statement 和 Call 返回错误时求值被中止, 错误像运行时错误一样向外传播
+type Hook interface {
+	// Statement 在执行每条语句之前调用, env 是语句所在的环境
+	Statement(stmt ast.Statement, env *Environment) *Error
+	// Call 在执行函数体之前调用, env 是已经绑定了参数的函数环境
+	Call(fn *Function, env *Environment) *Error
+	// Return 在函数体执行完之后调用; 尾调用时先以 nil 结果 Return, 再 Call 被调用的函数
+	Return(fn *Function, result Object)
+}
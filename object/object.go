@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"github.com/fanyeke/monkey/ast"
 	"hash/fnv"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -12,6 +15,7 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	BUILTIN_OBJ      = "BULITIN"
@@ -21,6 +25,8 @@ const (
 	STRING_OBJ       = "STRING"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	REGEX_OBJ        = "REGEX"
+	MODULE_OBJ       = "MODULE"
 )
 
 type Object interface {
@@ -38,6 +44,21 @@ func (i *Integer) Inspect() string {
 }
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 
+// Float 浮点数, 主要由数学内置函数产生
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	// 保证整数值的浮点数也能和整数区分开, 例如 2.0
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
 // Boolean 对应包装布尔值的字面量
 type Boolean struct {
 	Value bool
@@ -53,11 +74,14 @@ type NULL struct {
 func (n *NULL) Type() ObjectType { return NULL_OBJ }
 func (n *NULL) Inspect() string  { return "null" }
 
-// BuiltinFunction 内置函数
-type BuiltinFunction func(args ...Object) Object
+// BuiltinFunction 内置函数, env 是调用处的环境, 需要输入输出的内置函数通过 env.Context() 获取
+type BuiltinFunction func(env *Environment, args ...Object) Object
 
+// Builtin 内置函数, MinArgs 和 MaxArgs 是接受的参数个数, 供 lint 等静态检查使用, MaxArgs 为 -1 表示没有上限
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn      BuiltinFunction
+	MinArgs int
+	MaxArgs int
 }
 
 func (b *Builtin) Type() ObjectType {
@@ -78,13 +102,18 @@ func (rv *ReturnValue) Inspect() string {
 
 type Error struct {
 	Message string
+	Kind    string   // 错误的种类, 例如 TypeError、NameError, throw 抛出的默认为 Error
+	Value   Object   // throw 抛出的原始值, 运行时错误为 nil
+	Stack   []string // 错误经过的函数调用, 最内层在前
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string  { return "ERROR:" + e.Message }
 
 type Function struct {
-	Parameters []*ast.Identifier
+	Name       string // 函数的名字, 匿名函数为空
+	Parameters []ast.Pattern
+	ReturnType ast.TypeExpr // 返回值的类型注解, 可以为 nil
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -99,7 +128,11 @@ func (f *Function) Inspect() string {
 	out.WriteString("fn")
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(") {\n")
+	out.WriteString(") ")
+	if f.ReturnType != nil {
+		out.WriteString("-> " + f.ReturnType.String() + " ")
+	}
+	out.WriteString("{\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
 
@@ -179,3 +212,28 @@ func (h *Hash) Inspect() string {
 type Hashable interface {
 	HashKey() HashKey
 }
+
+// Regex 编译后的正则表达式
+type Regex struct {
+	Value *regexp.Regexp
+}
+
+func (r *Regex) Type() ObjectType { return REGEX_OBJ }
+func (r *Regex) Inspect() string  { return "/" + r.Value.String() + "/" }
+
+// Module 导入的模块, 只包含模块顶层 export 的绑定
+type Module struct {
+	Name    string // 模块文件名, 不含扩展名
+	Path    string // 模块文件的绝对路径
+	Exports map[string]Object
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string {
+	names := make([]string, 0, len(m.Exports))
+	for name := range m.Exports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "module " + m.Name + " {" + strings.Join(names, ", ") + "}"
+}
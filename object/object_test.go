@@ -20,3 +20,13 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestDefaultContextSharesStdin(t *testing.T) {
+	first, second := NewEnvironment().Context(), NewEnvironment().Context()
+	if first == second {
+		t.Fatalf("each environment should get its own Context")
+	}
+	if first.In != second.In {
+		t.Errorf("default contexts should share one stdin reader")
+	}
+}
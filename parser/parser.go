@@ -5,7 +5,9 @@ import (
 	"github.com/fanyeke/monkey/ast"
 	"github.com/fanyeke/monkey/lexer"
 	"github.com/fanyeke/monkey/token"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
 // 这些常量是用来区分运算符优先级的
@@ -31,8 +33,10 @@ var precedences = map[token.TokenType]int{
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
+	token.PERCENT:  PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 }
 
 // 定义两种类型的函数: 前缀解析函数和中缀解析函数
@@ -41,9 +45,18 @@ type (
 	prefixParseFn func() ast.Expression
 	infixParseFn  func(ast.Expression) ast.Expression
 )
+
+// ParseError 带有位置信息的语法错误, Span 是出错的词法单元所在的范围
+type ParseError struct {
+	Message string
+	Span    token.Span
+}
+
 type Parser struct {
 	l      *lexer.Lexer // Parser 内联了 lexer.Lexer , Lexer 持有着输入的字符串
 	errors []string
+	// parseErrors 与 errors 一一对应, 额外记录了出错的位置
+	parseErrors []ParseError
 
 	// curToken 和 peekToken 的性质与Lexer中的当前字符和下一个字符相同, 但是它们指向的是当前词法单元和下一个词法单元
 	// 原因是有可能 curToken 没有提供足够的信息, 需要下一个词法单元 peekToken 来提供
@@ -54,6 +67,11 @@ type Parser struct {
 	// 使用 prefixParseFns 和 infixParseFns 两个 map 来保存映射函数, 每一种 token.TokenType 会对应一种处理函数
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// blockDepth 当前所在代码块的嵌套深度, export 只允许出现在最外层
+	blockDepth int
+	// literalPatterns 解析 match 分支的模式时允许出现字面量
+	literalPatterns bool
 }
 
 func New(l *lexer.Lexer) *Parser {
@@ -66,6 +84,7 @@ func New(l *lexer.Lexer) *Parser {
 	// 注册前缀解析函数
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	// 初始化中缀解析函数的映射
@@ -77,6 +96,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	// 注册花括号的解析函数
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
 	// 注册函数解析函数
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	// 注册中缀解析函数
@@ -84,6 +104,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
@@ -96,8 +117,12 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	// 注册索引解析函数
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	// 注册成员访问解析函数
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 	// 注册map解析函数
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	// 注册正则字面量解析函数
+	p.registerPrefix(token.REGEX, p.parseRegexLiteral)
 	// 向后读取两个词法单元, 根据这两个词法单元设置 curToken 和 peekToken
 	p.nextToken()
 	p.nextToken()
@@ -109,9 +134,20 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// ParseErrors 返回带位置信息的语法错误, 顺序与 Errors 相同
+func (p *Parser) ParseErrors() []ParseError {
+	return p.parseErrors
+}
+
+// errorAt 记录一条语法错误, 错误的位置为 tok 所在的范围
+func (p *Parser) errorAt(tok token.Token, msg string) {
+	p.errors = append(p.errors, msg)
+	p.parseErrors = append(p.parseErrors, ParseError{Message: msg, Span: token.Span{Start: tok.Pos, End: tok.End}})
+}
+
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s install", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.peekToken, msg)
 }
 
 func (p *Parser) nextToken() {
@@ -143,9 +179,25 @@ func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
 		// 如果是 LET 类型就执行
-		return p.parseLetStatement()
+		// 出错时返回 nil 接口, 而不是包装了 nil 指针的接口, 否则 ParseProgram 会把它加入语句列表
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.RETURN:
-		return p.parseReturnStatement()
+		if stmt := p.parseReturnStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
+	case token.THROW:
+		if stmt := p.parseThrowStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	default:
 
 		return p.parseExpressionStatement()
@@ -157,14 +209,31 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	// 把当前词法单元的 token 存入 stmt 中
 	stmt := &ast.LetStatement{Token: p.curToken}
 
-	// 根据下一个词法单元判断是不是符合 LET 语句
-	// 如果下一个词法单元不是一个标识符, 说明不是想要的元素, 直接返回, 词法单元指针继续前进
-	if !p.expectPeek(token.IDENT) {
-		return nil
+	// let 后面是"["或"{"时为解构赋值
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Pattern = p.parsePattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+	} else {
+		// 根据下一个词法单元判断是不是符合 LET 语句
+		// 如果下一个词法单元不是一个标识符, 说明不是想要的元素, 直接返回, 词法单元指针继续前进
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		// 值得注意的是上面已经进行了指针的移动, 那么此时的Token就已经是标识符了
+		// 下一个词法单元是标识符, 那么往 Statement 节点的 Name 中当前 **标识符** 存入当前 Token 的内容
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+	// 可选的类型注解 let x: int = 1;
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		if stmt.Type = p.parseType(); stmt.Type == nil {
+			return nil
+		}
 	}
-	// 值得注意的是上面已经进行了指针的移动, 那么此时的Token就已经是标识符了
-	// 下一个词法单元是标识符, 那么往 Statement 节点的 Name 中当前 **标识符** 存入当前 Token 的内容
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
 	// 如果下一个词法类型不是"=", 也就不是想要的元素, 会直接返回, 词法指针继续移动
 	if !p.expectPeek(token.ASSIGN) {
@@ -173,14 +242,24 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	p.nextToken()
 	stmt.Value = p.parseExpression(LOWEST)
-	// 直到遇到分号
-	for !p.curTokenIs(token.SEMICOLON) {
-		p.nextToken()
+	// 记录函数的名字, 用于错误信息
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok && stmt.Name != nil {
+		fl.Name = stmt.Name.Value
 	}
+	// 直到遇到分号
+	p.skipToSemicolon()
 
 	return stmt
 }
 
+// skipToSemicolon 跳过语句剩余的词法单元直到分号
+// 分号可以省略: 遇到文件结尾或者代码块的"}"时停下, 不会越过它们
+func (p *Parser) skipToSemicolon() {
+	for !p.curTokenIs(token.SEMICOLON) && !p.peekTokenIs(token.EOF) && !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+	}
+}
+
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -212,9 +291,7 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	for !p.curTokenIs(token.SEMICOLON) {
-		p.nextToken()
-	}
+	p.skipToSemicolon()
 	return stmt
 }
 
@@ -268,10 +345,15 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{
+	ident := &ast.Identifier{
 		Token: p.curToken,
 		Value: p.curToken.Literal,
 	}
+	// match 不是关键字, 因为同名的内置函数 match(re, str) 仍然可用
+	if ident.Value == "match" && p.peekTokenIs(token.LPAREN) {
+		return p.parseMatchOrCall(ident)
+	}
+	return ident
 }
 
 // parseIntegerLiteral 解析整数
@@ -284,7 +366,22 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("cound not parse %q as integer.", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorAt(p.curToken, msg)
+		return nil
+	}
+	lit.Value = value
+
+	return lit
+}
+
+// parseFloatLiteral 解析浮点数
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float.", p.curToken.Literal)
+		p.errorAt(p.curToken, msg)
 		return nil
 	}
 	lit.Value = value
@@ -295,7 +392,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 // noPrefixParseFnError 没有注册前缀解析函数
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefox parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.curToken, msg)
 }
 
 // parsePrefixExpression 遇到 "!"和"-"执行此函数, 将其写入expression中, 并且加上其所对应的优先级
@@ -393,10 +490,67 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+// parseThrowStatement 解析 throw 语句
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
+	p.skipToSemicolon()
+	return stmt
+}
+
+// parseTryExpression 解析 try { } catch (e) { } finally { }
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			expression.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		p.errorAt(p.curToken, "try must be followed by catch or finally")
+		return nil
+	}
+	return expression
+}
+
 // parseBlockStatement 花括号区块解析
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
 
 	p.nextToken()
 
@@ -407,6 +561,7 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		}
 		p.nextToken()
 	}
+	block.Close = p.curToken
 	return block
 }
 
@@ -418,6 +573,14 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 	lit.Parameters = p.parseFunctionParameters()
+	// 可选的返回值类型注解 fn(a: int) -> int { ... }
+	if p.peekTokenIs(token.RARROW) {
+		p.nextToken()
+		p.nextToken()
+		if lit.ReturnType = p.parseType(); lit.ReturnType == nil {
+			return nil
+		}
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -426,9 +589,9 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-// parseFunctionParameters 解析函数的入参
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	var identifiers []*ast.Identifier
+// parseFunctionParameters 解析函数的入参, 参数可以是标识符或解构模式
+func (p *Parser) parseFunctionParameters() []ast.Pattern {
+	var identifiers []ast.Pattern
 	// 如果下一个词法单元是")"则跳过并返回
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
@@ -436,17 +599,26 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	}
 	// 跳过当前"(", 开始读取第一个参数
 	p.nextToken()
-	// 将第一个参数初始化为 Identifier 并加入切片中
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	// 将第一个参数加入切片中, 参数有错误时整个参数列表返回 nil
+	param := p.parseFunctionParameter()
+	if param == nil {
+		return nil
+	}
+	identifiers = append(identifiers, param)
 	// 如果下个词法单元是","就一直循环
 	for p.peekTokenIs(token.COMMA) {
+		if isRestParameter(identifiers[len(identifiers)-1]) {
+			p.errorAt(p.curToken, "rest parameter must be last")
+			return nil
+		}
 		// 跳过两个词法单元, 到下一个参数
 		p.nextToken()
 		p.nextToken()
 		// 将这个参数加入到返回切片中
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		if param = p.parseFunctionParameter(); param == nil {
+			return nil
+		}
+		identifiers = append(identifiers, param)
 	}
 	// 如果还没有遇到")"就是出错了, 返回nil
 	if !p.expectPeek(token.RPAREN) {
@@ -459,6 +631,7 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseCallArgusments()
+	exp.Close = p.curToken
 	return exp
 }
 
@@ -471,12 +644,12 @@ func (p *Parser) parseCallArgusments() []ast.Expression {
 	}
 
 	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
+	args = append(args, p.parseCallArgument())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+		args = append(args, p.parseCallArgument())
 	}
 	if !p.expectPeek(token.RPAREN) {
 		return nil
@@ -491,6 +664,7 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Close = p.curToken
 	return array
 }
 
@@ -515,15 +689,41 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	return list
 }
 
+// parseIndexExpression 解析索引 a[i] 和切片 a[start:end:step], 切片的三个部分都可以省略
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	tok := p.curToken
 
-	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	var start ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		start = p.parseExpression(LOWEST)
+		// 没有":"就是普通的索引
+		if !p.peekTokenIs(token.COLON) {
+			if !p.expectPeek(token.RBRACKET) {
+				return nil
+			}
+			return &ast.IndexExpression{Token: tok, Left: left, Index: start, Close: p.curToken}
+		}
+	}
+
+	slice := &ast.SliceExpression{Token: tok, Left: left, Start: start}
+	p.nextToken() // 跳到第一个":"
+	if !p.peekTokenIs(token.COLON) && !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		slice.End = p.parseExpression(LOWEST)
+	}
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		if !p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+			slice.Step = p.parseExpression(LOWEST)
+		}
+	}
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
-	return exp
+	slice.Close = p.curToken
+	return slice
 }
 
 func (p *Parser) parseHashLiteral() ast.Expression {
@@ -538,6 +738,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
 		}
@@ -545,5 +746,396 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.Close = p.curToken
 	return hash
 }
+
+// parseRegexLiteral 解析正则字面量, 在语法分析阶段就检查模式能否编译
+func (p *Parser) parseRegexLiteral() ast.Expression {
+	lit := &ast.RegexLiteral{Token: p.curToken}
+
+	raw := p.curToken.Literal
+	end := strings.LastIndex(raw, "/")
+	if end <= 0 {
+		p.errorAt(p.curToken, fmt.Sprintf("unterminated regex literal %s", raw))
+		return nil
+	}
+	lit.Pattern = raw[1:end]
+	lit.Flags = raw[end+1:]
+	for _, f := range lit.Flags {
+		if !strings.ContainsRune("imsU", f) {
+			p.errorAt(p.curToken, fmt.Sprintf("unknown regex flag %q in %s", f, raw))
+			return nil
+		}
+	}
+	if _, err := regexp.Compile(lit.Pattern); err != nil {
+		p.errorAt(p.curToken, fmt.Sprintf("invalid regex %s: %s", raw, err))
+		return nil
+	}
+	return lit
+}
+
+// parseImportStatement 解析 import "path" as name;
+func (p *Parser) parseImportStatement() ast.Statement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.AS) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseExportStatement 解析 export let ...; 只允许出现在模块的最外层
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+
+	if p.blockDepth > 0 {
+		p.errorAt(p.curToken, "export is only allowed at the top level of a module")
+		return nil
+	}
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+	let := p.parseLetStatement()
+	if let == nil {
+		return nil
+	}
+	stmt.Statement = let
+	return stmt
+}
+
+// parseMemberExpression 解析成员访问 obj.name
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Member = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return exp
+}
+
+// parsePattern 解析解构模式: 标识符、数组模式 [a, ...rest] 或哈希模式 {name, age: years}
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	case token.INT, token.FLOAT, token.STRING, token.TRUE, token.FALSE, token.MINUS:
+		if p.literalPatterns {
+			return p.parseLiteralPattern()
+		}
+		p.errorAt(p.curToken, fmt.Sprintf("unexpected %s in pattern", p.curToken.Type))
+		return nil
+	default:
+		p.errorAt(p.curToken, fmt.Sprintf("unexpected %s in pattern", p.curToken.Type))
+		return nil
+	}
+}
+
+// parseRestPattern 解析 ...name, 当前词法单元为"..."
+func (p *Parser) parseRestPattern() *ast.RestPattern {
+	rest := &ast.RestPattern{Token: p.curToken}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	rest.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return rest
+}
+
+// parseArrayPattern 解析数组模式 [a, [b, c], ...rest], 剩余元素只能放在最后
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		if p.curTokenIs(token.ELLIPSIS) {
+			rest := p.parseRestPattern()
+			if rest == nil {
+				return nil
+			}
+			pattern.Elements = append(pattern.Elements, rest)
+			if !p.peekTokenIs(token.RBRACKET) {
+				p.errorAt(p.curToken, "rest element must be last in array pattern")
+				return nil
+			}
+			break
+		}
+		element := p.parsePattern()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	pattern.Close = p.curToken
+	return pattern
+}
+
+// parseHashPattern 解析哈希模式 {name, "full-name": n, age: years, ...others}
+func (p *Parser) parseHashPattern() ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		if p.curTokenIs(token.ELLIPSIS) {
+			pattern.Rest = p.parseRestPattern()
+			if pattern.Rest == nil {
+				return nil
+			}
+			if !p.peekTokenIs(token.RBRACE) {
+				p.errorAt(p.curToken, "rest element must be last in hash pattern")
+				return nil
+			}
+			break
+		}
+		if !p.curTokenIs(token.IDENT) && !p.curTokenIs(token.STRING) {
+			p.errorAt(p.curToken, fmt.Sprintf("expected IDENT or STRING as hash pattern key, got %s", p.curToken.Type))
+			return nil
+		}
+		pair := &ast.HashPatternPair{Key: p.curToken.Literal}
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			pair.Value = p.parsePattern()
+			if pair.Value == nil {
+				return nil
+			}
+		} else if p.curTokenIs(token.IDENT) {
+			// {name} 是 {name: name} 的简写
+			pair.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		} else {
+			p.errorAt(p.curToken, fmt.Sprintf("string key %q in hash pattern needs a binding", pair.Key))
+			return nil
+		}
+		pattern.Pairs = append(pattern.Pairs, pair)
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	pattern.Close = p.curToken
+	return pattern
+}
+
+// parseFunctionParameter 解析单个参数: 模式, 带默认值的参数 b = 10, 或者剩余参数 ...rest
+// 模式和剩余参数之后可以有类型注解, 例如 a: int = 1、...rest: [int]
+func (p *Parser) parseFunctionParameter() ast.Pattern {
+	if p.curTokenIs(token.ELLIPSIS) {
+		rest := p.parseRestPattern()
+		if rest == nil {
+			return nil
+		}
+		return p.parseParameterType(rest)
+	}
+	param := p.parsePattern()
+	if param == nil {
+		return nil
+	}
+	if param = p.parseParameterType(param); param == nil || !p.peekTokenIs(token.ASSIGN) {
+		return param
+	}
+	p.nextToken()
+	def := &ast.DefaultPattern{Token: p.curToken, Target: param}
+	p.nextToken()
+	if def.Default = p.parseExpression(LOWEST); def.Default == nil {
+		return nil
+	}
+	return def
+}
+
+// parseParameterType 解析参数后面可选的": 类型", 没有注解时原样返回参数
+func (p *Parser) parseParameterType(param ast.Pattern) ast.Pattern {
+	if !p.peekTokenIs(token.COLON) {
+		return param
+	}
+	p.nextToken()
+	typed := &ast.TypedPattern{Token: p.curToken, Target: param}
+	p.nextToken()
+	if typed.Type = p.parseType(); typed.Type == nil {
+		return nil
+	}
+	return typed
+}
+
+// isRestParameter 判断参数是否是剩余参数 ...rest, 包括带类型注解的
+func isRestParameter(param ast.Pattern) bool {
+	if typed, ok := param.(*ast.TypedPattern); ok {
+		param = typed.Target
+	}
+	_, ok := param.(*ast.RestPattern)
+	return ok
+}
+
+// parseType 解析类型注解, 当前词法单元是类型的第一个词法单元
+// 类型可以是名字 int、数组 [int]、哈希 {string: int} 或函数 fn(int, int) -> bool
+func (p *Parser) parseType() ast.TypeExpr {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+	case token.LBRACKET:
+		t := &ast.ArrayType{Token: p.curToken}
+		p.nextToken()
+		if t.Element = p.parseType(); t.Element == nil || !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		t.Close = p.curToken
+		return t
+	case token.LBRACE:
+		t := &ast.HashType{Token: p.curToken}
+		p.nextToken()
+		if t.Key = p.parseType(); t.Key == nil || !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		if t.Value = p.parseType(); t.Value == nil || !p.expectPeek(token.RBRACE) {
+			return nil
+		}
+		t.Close = p.curToken
+		return t
+	case token.FUNCTION:
+		return p.parseFunctionType()
+	}
+	p.errorAt(p.curToken, fmt.Sprintf("expected a type, got %s", p.curToken.Type))
+	return nil
+}
+
+// parseFunctionType 解析函数类型 fn(int, int) -> bool, 当前词法单元是 fn
+func (p *Parser) parseFunctionType() ast.TypeExpr {
+	t := &ast.FunctionType{Token: p.curToken, Parameters: []ast.TypeExpr{}}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	for !p.peekTokenIs(token.RPAREN) {
+		if len(t.Parameters) > 0 && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+		p.nextToken()
+		param := p.parseType()
+		if param == nil {
+			return nil
+		}
+		t.Parameters = append(t.Parameters, param)
+	}
+	p.nextToken()
+	t.Close = p.curToken
+	if p.peekTokenIs(token.RARROW) {
+		p.nextToken()
+		p.nextToken()
+		if t.Return = p.parseType(); t.Return == nil {
+			return nil
+		}
+	}
+	return t
+}
+
+// parseCallArgument 解析单个调用参数, name: value 形式的是具名参数
+func (p *Parser) parseCallArgument() ast.Expression {
+	if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON) {
+		arg := &ast.NamedArgument{Token: p.curToken}
+		arg.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		p.nextToken()
+		p.nextToken()
+		arg.Value = p.parseExpression(LOWEST)
+		return arg
+	}
+	return p.parseExpression(LOWEST)
+}
+
+// parseLiteralPattern 解析 match 中的字面量模式, 包括 -1 这样的负数
+func (p *Parser) parseLiteralPattern() ast.Pattern {
+	pattern := &ast.LiteralPattern{Token: p.curToken}
+	if p.curTokenIs(token.MINUS) && !p.peekTokenIs(token.INT) && !p.peekTokenIs(token.FLOAT) {
+		p.errorAt(p.peekToken, fmt.Sprintf("expected number after - in pattern, got %s", p.peekToken.Type))
+		return nil
+	}
+	pattern.Value = p.parseExpression(PREFIX)
+	if pattern.Value == nil {
+		return nil
+	}
+	return pattern
+}
+
+// parseMatchOrCall 解析 match(...) 开头的表达式
+// 如果括号中只有一个表达式并且后面跟着"{", 就是 match 表达式, 否则是对 match 的普通调用
+func (p *Parser) parseMatchOrCall(ident *ast.Identifier) ast.Expression {
+	p.nextToken()
+	call := &ast.CallExpression{Token: p.curToken, Function: ident}
+	call.Arguments = p.parseCallArgusments()
+	call.Close = p.curToken
+	if len(call.Arguments) != 1 || !p.peekTokenIs(token.LBRACE) {
+		return call
+	}
+	if _, ok := call.Arguments[0].(*ast.NamedArgument); ok {
+		return call
+	}
+
+	exp := &ast.MatchExpression{Token: ident.Token, Subject: call.Arguments[0]}
+	p.nextToken()
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		exp.Arms = append(exp.Arms, arm)
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	exp.Close = p.curToken
+	return exp
+}
+
+// parseMatchArm 解析 match 的一个分支: 模式 [if 守卫] => 结果
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{}
+
+	p.literalPatterns = true
+	arm.Pattern = p.parsePattern()
+	p.literalPatterns = false
+	if arm.Pattern == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		arm.Guard = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+	p.nextToken()
+	arm.Body = p.parseExpression(LOWEST)
+	if arm.Body == nil {
+		return nil
+	}
+	return arm
+}
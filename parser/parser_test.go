@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/fanyeke/monkey/ast"
 	"github.com/fanyeke/monkey/lexer"
+	"strings"
 	"testing"
 )
 
@@ -326,6 +327,18 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"a * b / c",
 			"((a * b) / c)",
 		},
+		{
+			"a + b % c * 2.5",
+			"(a + ((b % c) * 2.5))",
+		},
+		{
+			"-a.b * c.d[0]",
+			"((-(a.b)) * ((c.d)[0]))",
+		},
+		{
+			"a.b(c).d",
+			"((a.b)(c).d)",
+		},
 		{
 			"a + b / c",
 			"(a + (b / c))",
@@ -800,3 +813,374 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		testFunc(value)
 	}
 }
+
+func TestRegexLiteralExpression(t *testing.T) {
+	input := `/[a-z]+\/(?P<n>\d)/i;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.RegexLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.RegexLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Pattern != `[a-z]+\/(?P<n>\d)` {
+		t.Errorf("literal.Pattern wrong. got=%q", literal.Pattern)
+	}
+	if literal.Flags != "i" {
+		t.Errorf("literal.Flags not %q. got=%q", "i", literal.Flags)
+	}
+	if literal.String() != input[:len(input)-1] {
+		t.Errorf("literal.String() wrong. got=%q", literal.String())
+	}
+}
+
+func TestInvalidRegexLiteral(t *testing.T) {
+	tests := []string{`/(unclosed/`, `/abc/x`, `/abc`}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestImportExportStatements(t *testing.T) {
+	input := `import "lib/util.mk" as util;
+export let answer = util.double(21);
+util.a.b`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("program.Statements does not contain 3 statements. got=%d", len(program.Statements))
+	}
+	imp, ok := program.Statements[0].(*ast.ImportStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.ImportStatement. got=%T", program.Statements[0])
+	}
+	if imp.Path.Value != "lib/util.mk" || imp.Alias.Value != "util" {
+		t.Errorf("import wrong. got path=%q alias=%q", imp.Path.Value, imp.Alias.Value)
+	}
+	exp, ok := program.Statements[1].(*ast.ExportStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.ExportStatement. got=%T", program.Statements[1])
+	}
+	if !testLetStatement(t, exp.Statement, "answer") {
+		return
+	}
+
+	expected := `import "lib/util.mk" as util;export let answer = (util.double)(21);((util.a).b)`
+	if program.String() != expected {
+		t.Errorf("program.String() wrong.\nexpected=%q\ngot=%q", expected, program.String())
+	}
+}
+
+func TestExportOnlyAtTopLevel(t *testing.T) {
+	p := New(lexer.New(`fn() { export let x = 1; }`))
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 || p.Errors()[0] != "export is only allowed at the top level of a module" {
+		t.Errorf("expected export error. got=%v", p.Errors())
+	}
+}
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:2]", "(a[1:2])"},
+		{"a[:2]", "(a[:2])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:]", "(a[:])"},
+		{"a[::2]", "(a[::2])"},
+		{"a[::]", "(a[:])"},
+		{"a[1 + 1:-1:-x]", "(a[(1 + 1):(-1):(-x)])"},
+		{"a[b[1:]:][0]", "((a[(b[1:]):])[0])"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if stmt.String() != tt.expected {
+			t.Errorf("wrong String() for %q. expected=%q, got=%q", tt.input, tt.expected, stmt.String())
+		}
+
+		// String() 的结果重新解析后应当得到同样的结果
+		p = New(lexer.New(stmt.String()))
+		again := p.ParseProgram()
+		checkParserErrors(t, p)
+		if again.String() != tt.expected {
+			t.Errorf("String() does not round-trip for %q. got=%q", tt.input, again.String())
+		}
+	}
+
+	p := New(lexer.New("a[1:2"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected parser errors for unterminated slice")
+	}
+}
+
+func TestDestructuringPatterns(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = xs;", "let [a, b] = xs;"},
+		{"let [first, ...rest] = xs;", "let [first, ...rest] = xs;"},
+		{"let [[a, b], {c}] = xs;", "let [[a, b], {c}] = xs;"},
+		{"let [] = xs;", "let [] = xs;"},
+		{"let {name, age: years} = person;", "let {name, age: years} = person;"},
+		{`let {"full-name": n, ...others} = person;`, `let {"full-name": n, ...others} = person;`},
+		{"fn([a, b], {x}, c) { a }", "fn([a, b], {x}, c) a"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong String() for %q. got=%q", tt.input, program.String())
+		}
+	}
+
+	errorTests := []string{
+		"let [...rest, last] = xs;",
+		"let [a, 1] = xs;",
+		"let {1: a} = h;",
+		`let {"key"} = h;`,
+		"let {...rest, a} = h;",
+		"fn([a b]) { a }",
+	}
+	for _, input := range errorTests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestDefaultRestAndNamedArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 10, ...rest) { a }", "fn(a, b = 10, ...rest) a"},
+		{"fn([a, b] = [1, 2]) { a }", "fn([a, b] = [1, 2]) a"},
+		{"fn(...args) { args }", "fn(...args) args"},
+		{"f(1, b: 2 + 3, c: g(d: 4))", "f(1, b: (2 + 3), c: g(d: 4))"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong String() for %q. got=%q", tt.input, program.String())
+		}
+	}
+
+	program := New(lexer.New("let add = fn(a, b) { a + b };")).ParseProgram()
+	fl := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if fl.Name != "add" {
+		t.Errorf("function literal name not recorded. got=%q", fl.Name)
+	}
+
+	p := New(lexer.New("fn(...rest, a) { a }"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != "rest parameter must be last" {
+		t.Errorf("expected rest parameter error. got=%v", p.Errors())
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 1;", "let x: int = 1;"},
+		{"let [a, b]: [float] = xs;", "let [a, b]: [float] = xs;"},
+		{"fn(a: string, b: [int]) -> bool { a }", "fn(a: string, b: [int]) -> bool a"},
+		{"fn(a: int = 1, ...rest: [int]) { a }", "fn(a: int = 1, ...rest: [int]) a"},
+		{"fn({name}: {string: any}) { name }", "fn({name}: {string: any}) name"},
+		{"let f: fn(int, fn(string)) -> [int] = g;", "let f: fn(int, fn(string)) -> [int] = g;"},
+		{"let h = fn() -> fn() -> int { g };", "let h = fn() -> fn() -> int g;"},
+		{"a->b", ""},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if tt.expected == "" {
+			if len(p.Errors()) == 0 {
+				t.Errorf("expected parse errors for %q", tt.input)
+			}
+			continue
+		}
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong String() for %q. got=%q", tt.input, program.String())
+		}
+	}
+
+	program := New(lexer.New("fn(a: int, ...r: [int]) -> [int] { r }")).ParseProgram()
+	fl := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	typed, ok := fl.Parameters[1].(*ast.TypedPattern)
+	if !ok {
+		t.Fatalf("rest parameter is not a TypedPattern. got=%T", fl.Parameters[1])
+	}
+	if _, ok := typed.Target.(*ast.RestPattern); !ok {
+		t.Errorf("typed rest parameter target is not a RestPattern. got=%T", typed.Target)
+	}
+	if rt, ok := fl.ReturnType.(*ast.ArrayType); !ok || rt.Element.String() != "int" {
+		t.Errorf("wrong return type. got=%v", fl.ReturnType)
+	}
+
+	errors := map[string]string{
+		"let x: = 1;":              "expected a type, got =",
+		"fn(...r: [int], a) { a }": "rest parameter must be last",
+		"let x: {string} = 1;":     "expected next token to be :, got } install",
+	}
+	for input, expected := range errors {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 || p.Errors()[0] != expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%v", input, expected, p.Errors())
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`match (x) { 0 => "zero", -1 => "minus one", _ => "other" }`, `match (x) { 0 => zero, (-1) => minus one, _ => other }`},
+		{`match (p) { [x, y] => x + y, {type: "circle", r} => r, }`, `match (p) { [x, y] => (x + y), {type: "circle", r} => r }`},
+		{"match (n) { n if n > 10 => n * 2, n => n }", "match (n) { n if (n > 10) => (n * 2), n => n }"},
+		{`match (s) { "a" => 1.5, true => false }`, `match (s) { "a" => 1.5, true => false }`},
+		{"match (x) { }", "match (x) {  }"},
+		{`match(re, "abc")`, `match(re, abc)`},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong String() for %q. got=%q", tt.input, program.String())
+		}
+	}
+
+	errorTests := []string{
+		"match (x) { 0 => 1 _ => 2 }",
+		"match (x) { 0 -> 1 }",
+		"match (x) { -a => 1 }",
+		"let [a, 1] = xs;",
+	}
+	for _, input := range errorTests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestThrowAndTryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`throw "boom";`, `throw boom;`},
+		{"throw {\"message\": m}", "throw {message:m};"},
+		{"try { f() } catch (e) { e } finally { g() }", "try f() catch (e) e finally g()"},
+		{"try { f() } catch { 1 }", "try f() catch 1"},
+		{"let x = try { f() } finally { g() };", "let x = try f() finally g();"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong String() for %q. got=%q", tt.input, program.String())
+		}
+	}
+
+	errorTests := []string{
+		"try { f() }",
+		"try { f() } catch (1) { }",
+		"try f() catch (e) { }",
+		"throw;",
+	}
+	for _, input := range errorTests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+}
+
+func TestInvalidStatementsAreDropped(t *testing.T) {
+	// 参数有错误的函数字面量不能在参数列表中留下 nil, 否则 String 会崩溃
+	for _, input := range []string{"let = 1;", "return", "throw", "fn(1){}", "fn(...){}", "fn(a, 1){}", "fn(a = ){}", "fn(a: ){}"} {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+		for _, stmt := range program.Statements {
+			// 语句列表中不能出现包装了 nil 指针的接口
+			if stmt.String() == "" && stmt.TokenLiteral() == "" {
+				t.Errorf("unexpected empty statement for %q", input)
+			}
+		}
+	}
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let = 1;", []string{"1:5-1:6 expected next token to be IDENT, got = install", "1:5-1:6 no prefox parse function for = found"}},
+		{"let a = 1;\n  a + ;", []string{"2:7-2:8 no prefox parse function for ; found"}},
+		{"let x = /a/q;", []string{"1:9-1:13 unknown regex flag 'q' in /a/q"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		var got []string
+		for _, e := range p.ParseErrors() {
+			got = append(got, e.Span.String()+" "+e.Message)
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong errors for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+		if len(p.ParseErrors()) != len(p.Errors()) {
+			t.Errorf("ParseErrors and Errors differ for %q", tt.input)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package repl
+
+import (
+	"fmt"
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/evaluator"
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/object"
+	"github.com/fanyeke/monkey/parser"
+	"github.com/fanyeke/monkey/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// session 一次 repl 会话的状态, :reset 会替换其中的环境
+type session struct {
+	env    *object.Environment
+	newCtx func() *object.Context
+	out    io.Writer
+}
+
+func newSession(newCtx func() *object.Context, out io.Writer) *session {
+	s := &session{newCtx: newCtx, out: out}
+	s.reset()
+	return s
+}
+
+// reset 丢弃所有绑定和已经加载的模块
+func (s *session) reset() {
+	s.env = object.NewEnvironment()
+	s.env.SetContext(s.newCtx())
+}
+
+// command repl 中以":"开头的命令
+type command struct {
+	usage string
+	help  string
+	// source 为 true 时命令的参数是源代码, 括号没有闭合时继续读取下一行
+	source bool
+	run    func(s *session, arg string)
+}
+
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{
+		"tokens": {"<src>", "print the tokens produced by the lexer", true, (*session).tokens},
+		"ast":    {"<src>", "print the syntax tree produced by the parser", true, (*session).ast},
+		"env":    {"", "list the bindings in the current session", false, (*session).listEnv},
+		"type":   {"<expr>", "evaluate an expression and print the type of its value", true, (*session).typeOf},
+		"time":   {"<expr>", "evaluate an expression and print how long it took", true, (*session).time},
+		"load":   {"<file>", "run a file in the current session", false, (*session).load},
+		"reset":  {"", "discard all bindings and loaded modules", false, func(s *session, arg string) { s.reset() }},
+		"help":   {"", "show this help", false, (*session).help},
+	}
+}
+
+// isCommand 判断输入是否是 repl 命令
+func isCommand(input string) bool {
+	return strings.HasPrefix(strings.TrimSpace(input), ":")
+}
+
+// splitCommand 把 ":ast 1 + 2" 拆成命令名和参数
+func splitCommand(input string) (string, string) {
+	input = strings.TrimPrefix(strings.TrimSpace(input), ":")
+	name, arg, _ := strings.Cut(input, " ")
+	if i := strings.IndexAny(name, "\n\t"); i >= 0 {
+		name, arg = name[:i], name[i+1:]+" "+arg
+	}
+	return name, strings.TrimSpace(arg)
+}
+
+// commandIncomplete 参数是源代码的命令, 和普通输入一样判断是否需要继续读取
+func commandIncomplete(input string) bool {
+	name, arg := splitCommand(input)
+	if cmd, ok := commands[name]; ok && cmd.source {
+		return isIncomplete(arg)
+	}
+	return false
+}
+
+// runCommand 执行 repl 命令
+func (s *session) runCommand(input string) {
+	name, arg := splitCommand(input)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(s.out, "unknown command :%s, type :help for a list of commands\n", name)
+		return
+	}
+	if cmd.usage != "" && arg == "" {
+		fmt.Fprintf(s.out, "usage: :%s %s\n", name, cmd.usage)
+		return
+	}
+	cmd.run(s, arg)
+}
+
+func (s *session) tokens(src string) {
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "%-10s %q\n", tok.Type, tok.Literal)
+	}
+}
+
+func (s *session) ast(src string) {
+	program, ok := s.parse(src)
+	if !ok {
+		return
+	}
+	ast.PrintTree(s.out, program)
+}
+
+func (s *session) listEnv(string) {
+	names := s.env.Names()
+	if len(names) == 0 {
+		io.WriteString(s.out, "no bindings\n")
+		return
+	}
+	for _, name := range names {
+		value, _ := s.env.Get(name)
+		fmt.Fprintf(s.out, "%s: %s = %s\n", name, value.Type(), summarize(value))
+	}
+}
+
+func (s *session) typeOf(src string) {
+	program, ok := s.parse(src)
+	if !ok {
+		return
+	}
+	// 在新的作用域中求值, 表达式中的 let 不会影响会话
+	evaluated := evaluator.Eval(program, object.NewEnclosedEnvironment(s.env))
+	if evaluated == nil {
+		io.WriteString(s.out, "no value\n")
+		return
+	}
+	fmt.Fprintf(s.out, "%s\n", evaluated.Type())
+}
+
+func (s *session) time(src string) {
+	program, ok := s.parse(src)
+	if !ok {
+		return
+	}
+	start := time.Now()
+	evaluated := evaluator.Eval(program, s.env)
+	elapsed := time.Since(start)
+	if evaluated != nil {
+		fmt.Fprintf(s.out, "%s\n", evaluated.Inspect())
+	}
+	fmt.Fprintf(s.out, "time: %s\n", elapsed)
+}
+
+func (s *session) load(path string) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(s.out, "could not load %s: %s\n", path, err)
+		return
+	}
+	program, ok := s.parse(string(src))
+	if !ok {
+		return
+	}
+	// 文件中的相对路径 import 以该文件为准
+	if abs, err := filepath.Abs(path); err == nil {
+		previous := s.env.File()
+		s.env.SetFile(abs)
+		defer s.env.SetFile(previous)
+	}
+	evaluated := evaluator.Eval(program, s.env)
+	if evaluated != nil && evaluated.Type() == object.ERROR_OBJ {
+		fmt.Fprintf(s.out, "%s\n", evaluated.Inspect())
+		return
+	}
+	fmt.Fprintf(s.out, "loaded %s\n", path)
+}
+
+func (s *session) help(string) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := commands[name]
+		fmt.Fprintf(s.out, "  %-16s %s\n", strings.TrimSpace(":"+name+" "+cmd.usage), cmd.help)
+	}
+}
+
+// parse 解析源代码, 出错时打印错误
+func (s *session) parse(src string) (*ast.Program, bool) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors())
+		return nil, false
+	}
+	return program, true
+}
+
+// summarize 返回对象的单行描述, 函数只显示参数列表
+func summarize(obj object.Object) string {
+	if fn, ok := obj.(*object.Function); ok {
+		params := make([]string, len(fn.Parameters))
+		for i, p := range fn.Parameters {
+			params[i] = p.String()
+		}
+		return "fn(" + strings.Join(params, ", ") + ")"
+	}
+	return strings.Join(strings.Fields(obj.Inspect()), " ")
+}
@@ -0,0 +1,47 @@
+package repl
+
+import (
+	"github.com/fanyeke/monkey/evaluator"
+	"github.com/fanyeke/monkey/object"
+	"github.com/fanyeke/monkey/token"
+	"sort"
+	"strings"
+)
+
+// Complete 返回以 prefix 开头的关键字、内置函数和环境中的名字, 按字母顺序排列且不重复
+func Complete(prefix string, env *object.Environment) []string {
+	if prefix == "" {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var candidates []string
+	for _, group := range [][]string{token.Keywords(), evaluator.BuiltinNames(), env.Names()} {
+		for _, name := range group {
+			if strings.HasPrefix(name, prefix) && !seen[name] {
+				seen[name] = true
+				candidates = append(candidates, name)
+			}
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// commonPrefix 返回所有候选项的公共前缀
+func commonPrefix(candidates []string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// isIdentChar 与词法分析器一致, 标识符只包含字母和下划线
+func isIdentChar(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '_'
+}
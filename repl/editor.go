@@ -0,0 +1,247 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// errInterrupt 用户按下 Ctrl-C, 放弃当前输入
+var errInterrupt = errors.New("interrupted")
+
+// 编辑器识别的控制字符
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyBackspace = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlU     = 21
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// lineEditor 终端行编辑器, 支持光标移动、历史记录和 Tab 补全
+// 终端需要事先切换到 raw 模式, 编辑器自己负责回显
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  *History
+	complete func(prefix string) []string
+}
+
+// lineState 正在编辑的一行
+type lineState struct {
+	prompt string
+	buf    []rune
+	pos    int // 光标在 buf 中的位置
+
+	histIndex int    // 正在浏览的历史记录, 等于记录条数时表示正在编辑的新输入
+	draft     []rune // 浏览历史记录前输入的内容
+}
+
+// ReadLine 读取一行输入, 不包含换行符
+// 空行上按 Ctrl-D 返回 io.EOF, 按 Ctrl-C 返回 errInterrupt
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	s := &lineState{prompt: prompt, histIndex: len(e.history.Entries())}
+	e.refresh(s)
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return string(s.buf), err
+		}
+
+		switch r {
+		case '\r', '\n':
+			io.WriteString(e.out, "\n")
+			return string(s.buf), nil
+		case keyCtrlC:
+			io.WriteString(e.out, "^C\n")
+			return "", errInterrupt
+		case keyCtrlD:
+			if len(s.buf) == 0 {
+				io.WriteString(e.out, "\n")
+				return "", io.EOF
+			}
+			s.deleteForward()
+		case keyBackspace, keyDelete:
+			s.deleteBackward()
+		case keyCtrlA:
+			s.pos = 0
+		case keyCtrlE:
+			s.pos = len(s.buf)
+		case keyCtrlB:
+			s.moveLeft()
+		case keyCtrlF:
+			s.moveRight()
+		case keyCtrlK:
+			s.buf = s.buf[:s.pos]
+		case keyCtrlU:
+			s.buf = append([]rune{}, s.buf[s.pos:]...)
+			s.pos = 0
+		case keyTab:
+			e.completeWord(s)
+		case keyEscape:
+			e.handleEscape(s)
+		default:
+			if r >= ' ' {
+				s.insert([]rune{r})
+			}
+		}
+		e.refresh(s)
+	}
+}
+
+// AddHistory 记录一次完整的输入
+func (e *lineEditor) AddHistory(input string) {
+	e.history.Add(input)
+}
+
+// handleEscape 处理方向键等 ESC [ X 形式的转义序列
+func (e *lineEditor) handleEscape(s *lineState) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return
+	}
+	r, _, err = e.in.ReadRune()
+	if err != nil {
+		return
+	}
+	// ESC [ 3 ~ 这样的序列, 数字之后以 ~ 结束
+	if r >= '0' && r <= '9' {
+		code := string(r)
+		for {
+			next, _, err := e.in.ReadRune()
+			if err != nil || next == '~' {
+				break
+			}
+			code += string(next)
+		}
+		switch code {
+		case "3":
+			s.deleteForward()
+		case "1", "7":
+			s.pos = 0
+		case "4", "8":
+			s.pos = len(s.buf)
+		}
+		return
+	}
+
+	switch r {
+	case 'A':
+		e.historyPrev(s)
+	case 'B':
+		e.historyNext(s)
+	case 'C':
+		s.moveRight()
+	case 'D':
+		s.moveLeft()
+	case 'H':
+		s.pos = 0
+	case 'F':
+		s.pos = len(s.buf)
+	}
+}
+
+func (e *lineEditor) historyPrev(s *lineState) {
+	entries := e.history.Entries()
+	if s.histIndex == 0 {
+		return
+	}
+	if s.histIndex == len(entries) {
+		s.draft = s.buf
+	}
+	s.histIndex--
+	s.buf = []rune(entries[s.histIndex])
+	s.pos = len(s.buf)
+}
+
+func (e *lineEditor) historyNext(s *lineState) {
+	entries := e.history.Entries()
+	if s.histIndex >= len(entries) {
+		return
+	}
+	s.histIndex++
+	if s.histIndex == len(entries) {
+		s.buf = s.draft
+	} else {
+		s.buf = []rune(entries[s.histIndex])
+	}
+	s.pos = len(s.buf)
+}
+
+// completeWord 补全光标前的标识符
+// 只有一个候选项时直接补全; 有多个时补全公共前缀, 没有可补全的部分时列出所有候选项
+func (e *lineEditor) completeWord(s *lineState) {
+	start := s.pos
+	for start > 0 && isIdentChar(s.buf[start-1]) {
+		start--
+	}
+	word := string(s.buf[start:s.pos])
+	candidates := e.complete(word)
+	if len(candidates) == 0 {
+		io.WriteString(e.out, "\a")
+		return
+	}
+
+	if rest := commonPrefix(candidates)[len(word):]; rest != "" {
+		s.insert([]rune(rest))
+		return
+	}
+	if len(candidates) > 1 {
+		fmt.Fprintf(e.out, "\n%s\n", strings.Join(candidates, "  "))
+	}
+}
+
+// refresh 重新绘制当前行并把光标移动到正确的位置
+// 从历史记录中取出的多行输入在一行中编辑, 换行显示为 ↵
+func (e *lineEditor) refresh(s *lineState) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", s.prompt, strings.ReplaceAll(string(s.buf), "\n", "↵"))
+	if n := len(s.buf) - s.pos; n > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", n)
+	}
+}
+
+func (s *lineState) insert(runes []rune) {
+	buf := make([]rune, 0, len(s.buf)+len(runes))
+	buf = append(buf, s.buf[:s.pos]...)
+	buf = append(buf, runes...)
+	buf = append(buf, s.buf[s.pos:]...)
+	s.buf = buf
+	s.pos += len(runes)
+}
+
+func (s *lineState) deleteBackward() {
+	if s.pos == 0 {
+		return
+	}
+	s.buf = append(s.buf[:s.pos-1], s.buf[s.pos:]...)
+	s.pos--
+}
+
+func (s *lineState) deleteForward() {
+	if s.pos == len(s.buf) {
+		return
+	}
+	s.buf = append(s.buf[:s.pos], s.buf[s.pos+1:]...)
+}
+
+func (s *lineState) moveLeft() {
+	if s.pos > 0 {
+		s.pos--
+	}
+}
+
+func (s *lineState) moveRight() {
+	if s.pos < len(s.buf) {
+		s.pos++
+	}
+}
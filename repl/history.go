@@ -0,0 +1,116 @@
+package repl
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HISTORY_FILE 历史记录文件的名字, 保存在用户的主目录下
+const HISTORY_FILE = ".monkey_history"
+
+// maxHistory 最多保留的历史记录条数
+const maxHistory = 1000
+
+// History 输入历史, 每条记录占文件中的一行, 记录中的换行和反斜杠在文件中转义为 \n 和 \\
+type History struct {
+	entries []string
+	path    string // 为空时只保存在内存中
+}
+
+// defaultHistoryPath 返回主目录下的历史记录文件, 找不到主目录时返回空字符串
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, HISTORY_FILE)
+}
+
+// LoadHistory 从文件中读取历史记录, 文件不存在时返回空的历史
+func LoadHistory(path string) (*History, error) {
+	h := &History{path: path}
+	if path == "" {
+		return h, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return h, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, unescapeHistory(line))
+		}
+	}
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+	return h, scanner.Err()
+}
+
+// Add 添加一条记录并追加到文件中
+// 记录保存原样的输入, 只有首尾的空白不同的输入视为与上一条相同, 不重复记录
+func (h *History) Add(input string) error {
+	entry := strings.TrimSpace(input)
+	if entry == "" || (len(h.entries) > 0 && strings.TrimSpace(h.entries[len(h.entries)-1]) == entry) {
+		return nil
+	}
+	h.entries = append(h.entries, input)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[1:]
+	}
+	if h.path == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(escapeHistory(input) + "\n")
+	return err
+}
+
+// historyEscaper 把一条记录转义成文件中的一行
+var historyEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r")
+
+func escapeHistory(entry string) string {
+	return historyEscaper.Replace(entry)
+}
+
+// unescapeHistory 还原 escapeHistory 转义的记录, 不认识的转义保持不变
+func unescapeHistory(line string) string {
+	var out strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] != '\\' || i+1 == len(line) {
+			out.WriteByte(line[i])
+			continue
+		}
+		switch line[i+1] {
+		case '\\':
+			out.WriteByte('\\')
+		case 'n':
+			out.WriteByte('\n')
+		case 'r':
+			out.WriteByte('\r')
+		default:
+			out.WriteByte(line[i])
+			continue
+		}
+		i++
+	}
+	return out.String()
+}
+
+// Entries 返回所有记录, 最早的在前
+func (h *History) Entries() []string {
+	return h.entries
+}
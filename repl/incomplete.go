@@ -0,0 +1,52 @@
+package repl
+
+import (
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/token"
+)
+
+// continuationTokens 以这些词法单元结尾的输入一定还没有写完
+var continuationTokens = map[token.TokenType]bool{
+	token.ASSIGN:   true,
+	token.PLUS:     true,
+	token.MINUS:    true,
+	token.BANG:     true,
+	token.ASTERISK: true,
+	token.SLASH:    true,
+	token.PERCENT:  true,
+	token.LT:       true,
+	token.GT:       true,
+	token.EQ:       true,
+	token.NOT_EQ:   true,
+	token.COMMA:    true,
+	token.COLON:    true,
+	token.DOT:      true,
+	token.ELLIPSIS: true,
+	token.ARROW:    true,
+}
+
+// isIncomplete 判断输入是否还需要继续读取下一行:
+// 括号没有闭合、字符串没有结束, 或者以运算符结尾
+func isIncomplete(input string) bool {
+	depth := 0
+	last := token.Token{Type: token.EOF}
+	l := lexer.New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		case token.STRING:
+			// 没有结束的字符串会一直读到输入末尾之后, 它的结束位置超出了输入
+			if tok.End.Offset > len(input) {
+				return true
+			}
+		}
+		last = tok
+	}
+	if depth > 0 {
+		return true
+	}
+	return continuationTokens[last.Type]
+}
@@ -4,36 +4,54 @@ import (
 	"bufio"
 	"fmt"
 	"github.com/fanyeke/monkey/evaluator"
-	"github.com/fanyeke/monkey/lexer"
 	"github.com/fanyeke/monkey/object"
-	"github.com/fanyeke/monkey/parser"
 	"io"
+	"os"
+	"strings"
 )
 
 const PROMPT = ">>"
 
+// CONTINUATION_PROMPT 输入还没有结束时显示的提示符
+const CONTINUATION_PROMPT = ".."
+
+// lineReader 逐行读取输入
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+	AddHistory(input string)
+}
+
 func Start(in io.Reader, out io.Writer) {
 	// 输入和变量储存环境
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	// repl 和脚本中的 input 共享同一个缓冲读取器, 脚本的输出也写到 out 中
+	reader := bufio.NewReader(in)
+	s := newSession(func() *object.Context { return object.NewContext(reader, out, out) }, out)
+	lines := newLineReader(in, reader, out, s)
 	for {
-		fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		input, err := readInput(lines)
+		if err == errInterrupt {
+			continue
+		}
+		if err != nil && input == "" {
 			return
 		}
-		// 每次取一行, 每一行都建立一颗 ast 树, 进行解释
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-		// 建树
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		lines.AddHistory(input)
+		// ":"开头的是 repl 命令, 不交给语法分析器
+		if isCommand(input) {
+			s.runCommand(input)
+			continue
+		}
+		// 每次取一段完整的输入, 建立一颗 ast 树, 进行解释
+		program, ok := s.parse(input)
+		if !ok {
 			continue
 		}
 		// 解析求值
-		evaluated := evaluator.Eval(program, env)
+		evaluated := evaluator.Eval(program, s.env)
+		// exit() 结束 repl
+		if _, ok := evaluator.ExitCode(evaluated); ok {
+			return
+		}
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
@@ -41,6 +59,84 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
+// readInput 读取一段完整的输入, 括号没有闭合或者以运算符结尾时继续读取下一行
+func readInput(lines lineReader) (string, error) {
+	input, err := lines.ReadLine(PROMPT)
+	if err != nil {
+		return input, err
+	}
+	for needsMore(input) {
+		line, err := lines.ReadLine(CONTINUATION_PROMPT)
+		if err == errInterrupt {
+			return "", err
+		}
+		if err != nil && line == "" {
+			// 输入提前结束, 交给语法分析器报告错误
+			break
+		}
+		input += "\n" + line
+	}
+	return input, nil
+}
+
+func needsMore(input string) bool {
+	if isCommand(input) {
+		return commandIncomplete(input)
+	}
+	return isIncomplete(input)
+}
+
+// newLineReader 输入是终端时使用支持历史记录和补全的行编辑器, 否则逐行读取
+func newLineReader(in io.Reader, reader *bufio.Reader, out io.Writer, s *session) lineReader {
+	if f, ok := in.(*os.File); ok && isTerminal(int(f.Fd())) {
+		history, err := LoadHistory(defaultHistoryPath())
+		if err != nil {
+			fmt.Fprintf(out, "could not load history: %s\n", err)
+		}
+		editor := &lineEditor{
+			in:       reader,
+			out:      out,
+			history:  history,
+			complete: func(prefix string) []string { return Complete(prefix, s.env) },
+		}
+		return &terminalReader{editor: editor, fd: int(f.Fd())}
+	}
+	return &plainReader{in: reader, out: out}
+}
+
+// plainReader 从管道或文件中逐行读取, 不记录历史
+type plainReader struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (r *plainReader) ReadLine(prompt string) (string, error) {
+	io.WriteString(r.out, prompt)
+	line, err := r.in.ReadString('\n')
+	return strings.TrimSuffix(line, "\n"), err
+}
+
+func (r *plainReader) AddHistory(input string) {}
+
+// terminalReader 读取每一行时把终端切换到 raw 模式, 求值期间恢复原来的模式, 脚本中的 input 可以正常使用
+type terminalReader struct {
+	editor *lineEditor
+	fd     int
+}
+
+func (r *terminalReader) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(r.fd)
+	if err != nil {
+		return (&plainReader{in: r.editor.in, out: r.editor.out}).ReadLine(prompt)
+	}
+	defer restore()
+	return r.editor.ReadLine(prompt)
+}
+
+func (r *terminalReader) AddHistory(input string) {
+	r.editor.AddHistory(input)
+}
+
 const MONKEY_FACE = `            __,__
    .--.  .-"     "-.  .--.
   / .. \/  .-. .-.  \/ .. \
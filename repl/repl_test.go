@@ -0,0 +1,232 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/fanyeke/monkey/object"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStartWritesScriptOutput(t *testing.T) {
+	in := strings.NewReader("puts(1 + 2);\nprint(\"a\", \"b\");\nlet name = input(\"name? \");\nmonkey\nname\n")
+	var out bytes.Buffer
+
+	Start(in, &out)
+
+	expected := ">>3\nnull\n>>a bnull\n>>name? >>monkey\n>>"
+	if out.String() != expected {
+		t.Errorf("wrong repl output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestStartReadsMultiLineInput(t *testing.T) {
+	in := strings.NewReader("let add = fn(a, b) {\n  a +\n  b\n};\nadd(1,\n2)\n")
+	var out bytes.Buffer
+
+	Start(in, &out)
+
+	expected := ">>......>>..3\n>>"
+	if out.String() != expected {
+		t.Errorf("wrong repl output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 1;", false},
+		{"fn(a) {", true},
+		{"fn(a) { a }", false},
+		{"[1, 2,", true},
+		{"let x = 1 +", true},
+		{"let x =", true},
+		{`"abc`, true},
+		{`"a\"b"`, false},
+		{`"a\"`, true},
+		{"let s = \"a\nb", true},
+		{`let r = /"/;`, false},
+		{`puts(1) // say "hi`, false},
+		{`puts("a") // "`, false},
+		{"match (x) { 0 =>", true},
+		{"}", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestLineEditor(t *testing.T) {
+	history := &History{}
+	history.Add("first")
+	history.Add("let x = fn(a,\n b) { a }")
+
+	env := object.NewEnvironment()
+	env.Set("counter", &object.Integer{Value: 1})
+	newEditor := func(keys string) *lineEditor {
+		return &lineEditor{
+			in:       bufio.NewReader(strings.NewReader(keys)),
+			out:      io.Discard,
+			history:  history,
+			complete: func(prefix string) []string { return Complete(prefix, env) },
+		}
+	}
+
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"abc\r", "abc"},
+		{"abd\x7fc\r", "abc"},
+		{"ac\x1b[Db\r", "abc"},
+		{"bc\x01a\x05d\r", "abcd"},
+		{"abc\x1b[D\x1b[D\x1b[3~\r", "ac"},
+		{"abc\x02\x02\x0b\r", "a"},
+		{"\x1b[A\r", "let x = fn(a,\n b) { a }"},
+		{"\x1b[A\x1b[A\r", "first"},
+		{"draft\x1b[A\x1b[B\r", "draft"},
+		{"cou\t + 1\r", "counter + 1"},
+		{"put\t(1)\r", "puts(1)"},
+		{"pu\t\r", "pu"},
+		{"re\t\r", "re"},
+	}
+
+	for _, tt := range tests {
+		line, err := newEditor(tt.keys).ReadLine(PROMPT)
+		if err != nil {
+			t.Fatalf("ReadLine(%q) returned error: %s", tt.keys, err)
+		}
+		if line != tt.expected {
+			t.Errorf("ReadLine(%q) wrong. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+
+	if _, err := newEditor("\x04").ReadLine(PROMPT); err != io.EOF {
+		t.Errorf("expected io.EOF on Ctrl-D, got %v", err)
+	}
+	if _, err := newEditor("abc\x03").ReadLine(PROMPT); err != errInterrupt {
+		t.Errorf("expected errInterrupt on Ctrl-C, got %v", err)
+	}
+}
+
+func TestHistoryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HISTORY_FILE)
+
+	history, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("LoadHistory returned error: %s", err)
+	}
+	inputs := []string{
+		"let a = 1;", "let a = 1; ", "", "  ",
+		"fn(x) {\n  x\n}",
+		`puts("a  b")`,
+		"let a = 1 // c\nputs(a)",
+		`puts("a\nb\\")`,
+		"\\n\\\\",
+	}
+	for _, input := range inputs {
+		if err := history.Add(input); err != nil {
+			t.Fatalf("Add returned error: %s", err)
+		}
+	}
+
+	loaded, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("LoadHistory returned error: %s", err)
+	}
+	expected := []string{"let a = 1;", "fn(x) {\n  x\n}", `puts("a  b")`, "let a = 1 // c\nputs(a)", `puts("a\nb\\")`, "\\n\\\\"}
+	if strings.Join(loaded.Entries(), "|") != strings.Join(expected, "|") {
+		t.Errorf("wrong history entries. expected=%q, got=%q", expected, loaded.Entries())
+	}
+	if strings.Join(history.Entries(), "|") != strings.Join(expected, "|") {
+		t.Errorf("wrong history entries in memory. expected=%q, got=%q", expected, history.Entries())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != len(expected) {
+		t.Errorf("history file should have one line per entry, got %d lines: %q", lines, data)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("format_name", &object.Integer{Value: 1})
+	inner := object.NewEnclosedEnvironment(env)
+	inner.Set("fib", &object.Integer{Value: 1})
+
+	tests := []struct {
+		prefix   string
+		expected []string
+	}{
+		{"fi", []string{"fib", "finally", "find_all", "first"}},
+		{"fo", []string{"format_name"}},
+		{"le", []string{"len", "let"}},
+		{"zzz", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		got := Complete(tt.prefix, inner)
+		if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("Complete(%q) wrong. expected=%q, got=%q", tt.prefix, tt.expected, got)
+		}
+	}
+}
+
+func TestMetaCommands(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "lib.mk")
+	if err := os.WriteFile(file, []byte("let double = fn(x) { x * 2 };"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := strings.Join([]string{
+		":tokens let x",
+		":ast -a + b",
+		":load " + file,
+		"let n = 3;",
+		":env",
+		":type double(n)",
+		":type let m = 1; m",
+		":reset",
+		":env",
+		":nope",
+		":type",
+		"",
+	}, "\n")
+	var out bytes.Buffer
+
+	Start(strings.NewReader(script), &out)
+
+	expected := strings.Join([]string{
+		`>>LET        "let"`,
+		`IDENT      "x"`,
+		`>>Program`,
+		`└─ statements[0]: ExpressionStatement`,
+		`   └─ expression: InfixExpression +`,
+		`      ├─ left: PrefixExpression -`,
+		`      │  └─ right: Identifier a`,
+		`      └─ right: Identifier b`,
+		`>>loaded ` + file,
+		`>>>>double: FUNCTION = fn(x)`,
+		`n: INTEGER = 3`,
+		`>>INTEGER`,
+		`>>INTEGER`,
+		`>>>>no bindings`,
+		`>>unknown command :nope, type :help for a list of commands`,
+		`>>usage: :type <expr>`,
+		`>>`,
+	}, "\n")
+	if out.String() != expected {
+		t.Errorf("wrong repl output.\nexpected=%s\ngot=%s", expected, out.String())
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package repl
+
+import "errors"
+
+// 其他平台上不支持行编辑, repl 按普通输入逐行读取
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
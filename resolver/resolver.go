@@ -0,0 +1,135 @@
+// Package resolver 在求值之前静态地解析程序中的名字, 作用域与求值器创建 Environment 的方式一致,
+// 报告未定义的名字、没有使用的 let 绑定和参数、遮蔽内置函数的绑定以及重复的参数名
+package resolver
+
+import (
+	"fmt"
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/evaluator"
+	"github.com/fanyeke/monkey/token"
+	"sort"
+	"strings"
+)
+
+// Severity 诊断的严重程度
+type Severity int
+
+const (
+	Error   Severity = iota + 1 // 程序运行到这里一定会出错
+	Warning                     // 可能是错误, 但不影响运行
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// 诊断的种类
+const (
+	Undefined          = "undefined"
+	Unused             = "unused"
+	ShadowedBuiltin    = "shadowed-builtin"
+	DuplicateParameter = "duplicate-parameter"
+)
+
+// PREDECLARED 运行脚本时预先定义在最外层环境中的名字
+var PREDECLARED = []string{"ARGS"}
+
+// Diagnostic 解析名字时发现的一个问题
+type Diagnostic struct {
+	Kind     string
+	Severity Severity
+	Message  string
+	Span     token.Span
+}
+
+// String 返回 "行:列: 严重程度: 信息" 形式的描述
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", d.Span.Start, d.Severity, d.Message)
+}
+
+// Resolve 解析程序中的名字并返回发现的问题, 按位置排序
+func Resolve(program *ast.Program) []Diagnostic {
+	return Analyze(program).Diagnostics()
+}
+
+// Diagnostics 根据名字解析的结果返回发现的问题, 按位置排序
+func (a *Analysis) Diagnostics() []Diagnostic {
+	builtins := make(map[string]bool)
+	for _, name := range evaluator.BuiltinNames() {
+		builtins[name] = true
+	}
+	predeclared := make(map[string]bool)
+	for _, name := range PREDECLARED {
+		predeclared[name] = true
+	}
+
+	var diagnostics []Diagnostic
+	report := func(kind string, severity Severity, ident *ast.Identifier, format string, args ...interface{}) {
+		diagnostics = append(diagnostics, Diagnostic{
+			Kind:     kind,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+			Span:     token.Span{Start: ident.Token.Pos, End: ident.Token.End},
+		})
+	}
+
+	for _, ident := range a.Unresolved {
+		if !builtins[ident.Value] && !predeclared[ident.Value] {
+			report(Undefined, Error, ident, "identifier not found: %s", ident.Value)
+		}
+	}
+
+	exported := a.exported()
+	// 同一个函数的参数中后出现的同名参数遮蔽先出现的
+	params := make(map[*ast.FunctionLiteral]map[string]*Binding)
+	shadowed := make(map[*Binding]bool)
+	for _, b := range a.Bindings {
+		if builtins[b.Name] {
+			report(ShadowedBuiltin, Warning, b.Decl, "%s %s shadows the builtin function %s", b.Kind, b.Name, b.Name)
+		}
+		if fn, ok := b.Node.(*ast.FunctionLiteral); ok && b.Kind == ParameterBinding {
+			if params[fn] == nil {
+				params[fn] = make(map[string]*Binding)
+			}
+			if previous, ok := params[fn][b.Name]; ok {
+				report(DuplicateParameter, Warning, b.Decl, "duplicate parameter %s", b.Name)
+				// 前一个同名参数无法被引用, 不再报告它没有使用
+				shadowed[previous] = true
+			}
+			params[fn][b.Name] = b
+		}
+	}
+
+	for _, b := range a.Bindings {
+		if len(b.References) != 0 || shadowed[b] || strings.HasPrefix(b.Name, "_") {
+			continue
+		}
+		switch b.Kind {
+		case LetBinding:
+			if !exported[b.Node] {
+				report(Unused, Warning, b.Decl, "%s declared and not used", b.Name)
+			}
+		case ParameterBinding:
+			report(Unused, Warning, b.Decl, "parameter %s is not used", b.Name)
+		}
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Span.Start.Before(diagnostics[j].Span.Start)
+	})
+	return diagnostics
+}
+
+// exported 返回顶层被 export 的 let 语句, 它们由导入模块的程序使用
+func (a *Analysis) exported() map[ast.Node]bool {
+	exported := make(map[ast.Node]bool)
+	for _, stmt := range a.program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok && export.Statement != nil {
+			exported[export.Statement] = true
+		}
+	}
+	return exported
+}
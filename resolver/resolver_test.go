@@ -0,0 +1,59 @@
+package resolver
+
+import (
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/parser"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; puts(x, ARGS);", nil},
+		{"puts(y); let y = 1; y", []string{"1:6: error: identifier not found: y"}},
+		{
+			// 函数体中可以使用之后才定义的名字, 只有执行到的分支才会在运行时报错
+			"let f = fn(n) { if (n) { g(n) } else { nope } }; let g = fn(m) { m }; f(1)",
+			[]string{"1:40: error: identifier not found: nope"},
+		},
+		{
+			"let unused = 1; let f = fn(a, b) { a }; f(1, 2)",
+			[]string{"1:5: warning: unused declared and not used", "1:31: warning: parameter b is not used"},
+		},
+		{"let _skip = 1; let f = fn(_a) { 1 }; f(1); export let api = 2;", nil},
+		{
+			"let len = fn(xs) { xs }; len([])",
+			[]string{"1:5: warning: let len shadows the builtin function len"},
+		},
+		{
+			"let f = fn(first) { first }; f(1)",
+			[]string{"1:12: warning: parameter first shadows the builtin function first"},
+		},
+		{
+			"let f = fn(a, [b, a]) { a + b }; f(1, [2, 3])",
+			[]string{"1:19: warning: duplicate parameter a"},
+		},
+		{
+			`match (v) { [h, ...t] => h, _ => 0 }; try { throw "x" } catch (e) { 1 }`,
+			[]string{"1:8: error: identifier not found: v"},
+		},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+		var got []string
+		for _, d := range Resolve(program) {
+			got = append(got, d.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong diagnostics for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
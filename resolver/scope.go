@@ -0,0 +1,272 @@
+package resolver
+
+import (
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/token"
+	"reflect"
+	"sort"
+)
+
+// BindingKind 引入名字的语法结构
+type BindingKind string
+
+const (
+	LetBinding       BindingKind = "let"
+	ParameterBinding BindingKind = "parameter"
+	ImportBinding    BindingKind = "import"
+	CatchBinding     BindingKind = "catch"
+	MatchBinding     BindingKind = "match"
+)
+
+// Binding 一个名字的定义及其所有引用
+type Binding struct {
+	Name string
+	Kind BindingKind
+	Decl *ast.Identifier
+	// Node 引入绑定的节点: *ast.LetStatement、*ast.FunctionLiteral、*ast.ImportStatement、*ast.TryExpression 或 *ast.MatchExpression
+	Node       ast.Node
+	References []*ast.Identifier
+}
+
+// scope 作用域, 与求值器创建 Environment 的位置一致: 顶层、函数调用、match 分支和 catch 各有一个作用域, 普通代码块没有
+type scope struct {
+	parent   *scope
+	span     token.Span
+	function bool
+	bindings []*Binding
+}
+
+// lookup 在作用域链中查找 name, 同一作用域中后定义的绑定覆盖先定义的
+func (s *scope) lookup(name string) *Binding {
+	for ; s != nil; s = s.parent {
+		for i := len(s.bindings) - 1; i >= 0; i-- {
+			if s.bindings[i].Name == name {
+				return s.bindings[i]
+			}
+		}
+	}
+	return nil
+}
+
+// insideFunction 判断作用域是否位于某个函数中
+func (s *scope) insideFunction() bool {
+	for ; s != nil; s = s.parent {
+		if s.function {
+			return true
+		}
+	}
+	return false
+}
+
+// Analysis 对一个程序做名字解析的结果
+type Analysis struct {
+	Bindings []*Binding
+	// Unresolved 没有找到定义的引用, 包括内置函数和未定义的名字
+	Unresolved []*ast.Identifier
+
+	program *ast.Program
+	idents  map[*ast.Identifier]*Binding // 定义和引用所在的标识符到绑定的映射
+	scopes  []*scope
+}
+
+// pendingRef 函数体中暂时找不到定义的引用, 函数被调用时外层可能已经定义了这个名字, 例如相互递归的函数
+type pendingRef struct {
+	ident *ast.Identifier
+	scope *scope
+}
+
+type analyzer struct {
+	*Analysis
+	pending []pendingRef
+}
+
+// Analyze 解析程序中所有的名字, 把每个引用关联到它的定义
+func Analyze(program *ast.Program) *Analysis {
+	a := &analyzer{Analysis: &Analysis{program: program, idents: make(map[*ast.Identifier]*Binding)}}
+	root := a.newScope(nil, token.Span{}, false)
+	for _, stmt := range program.Statements {
+		a.visit(stmt, root)
+	}
+	// 整个程序都看完之后, 再在外层作用域中查找函数体里提前使用的名字
+	for _, ref := range a.pending {
+		a.resolve(ref.ident, ref.scope, false)
+	}
+	return a.Analysis
+}
+
+func (a *analyzer) newScope(parent *scope, span token.Span, function bool) *scope {
+	s := &scope{parent: parent, span: span, function: function}
+	a.scopes = append(a.scopes, s)
+	return s
+}
+
+func (a *analyzer) declare(s *scope, ident *ast.Identifier, kind BindingKind, node ast.Node) {
+	if ident == nil {
+		return
+	}
+	b := &Binding{Name: ident.Value, Kind: kind, Decl: ident, Node: node}
+	s.bindings = append(s.bindings, b)
+	a.Bindings = append(a.Bindings, b)
+	a.idents[ident] = b
+}
+
+// resolve 把引用关联到定义, defer 为 true 时函数体中找不到的引用留到最后再查找
+func (a *analyzer) resolve(ident *ast.Identifier, s *scope, deferred bool) {
+	if b := s.lookup(ident.Value); b != nil {
+		b.References = append(b.References, ident)
+		a.idents[ident] = b
+		return
+	}
+	if deferred && s.insideFunction() {
+		a.pending = append(a.pending, pendingRef{ident, s})
+		return
+	}
+	a.Unresolved = append(a.Unresolved, ident)
+}
+
+// declarePattern 先求出模式中默认值里的引用, 再定义模式绑定的名字
+func (a *analyzer) declarePattern(pattern ast.Pattern, s *scope, kind BindingKind, node ast.Node) {
+	a.visitDefaults(pattern, s)
+	for _, ident := range ast.PatternNames(pattern) {
+		a.declare(s, ident, kind, node)
+	}
+}
+
+func (a *analyzer) visitDefaults(pattern ast.Pattern, s *scope) {
+	switch pattern := pattern.(type) {
+	case *ast.DefaultPattern:
+		a.visitDefaults(pattern.Target, s)
+		a.visit(pattern.Default, s)
+	case *ast.TypedPattern:
+		a.visitDefaults(pattern.Target, s)
+	case *ast.ArrayPattern:
+		for _, el := range pattern.Elements {
+			a.visitDefaults(el, s)
+		}
+	case *ast.HashPattern:
+		for _, pair := range pattern.Pairs {
+			a.visitDefaults(pair.Value, s)
+		}
+	}
+}
+
+func (a *analyzer) visit(node ast.Node, s *scope) {
+	// 有语法错误时部分子节点可能是 nil 指针
+	if node == nil || reflect.ValueOf(node).IsNil() {
+		return
+	}
+	switch node := node.(type) {
+	case *ast.Identifier:
+		a.resolve(node, s, true)
+	case *ast.LetStatement:
+		a.visit(node.Value, s)
+		if node.Pattern != nil {
+			a.declarePattern(node.Pattern, s, LetBinding, node)
+		} else {
+			a.declare(s, node.Name, LetBinding, node)
+		}
+	case *ast.ImportStatement:
+		a.declare(s, node.Alias, ImportBinding, node)
+	case *ast.FunctionLiteral:
+		fs := a.newScope(s, ast.SpanOf(node), true)
+		for _, param := range node.Parameters {
+			a.declarePattern(param, fs, ParameterBinding, node)
+		}
+		a.visit(node.Body, fs)
+	case *ast.MatchExpression:
+		a.visit(node.Subject, s)
+		for _, arm := range node.Arms {
+			span := ast.SpanOf(arm.Pattern)
+			span.End = ast.SpanOf(arm.Body).End
+			as := a.newScope(s, span, false)
+			a.declarePattern(arm.Pattern, as, MatchBinding, node)
+			a.visit(arm.Guard, as)
+			a.visit(arm.Body, as)
+		}
+	case *ast.TryExpression:
+		a.visit(node.Block, s)
+		if node.Catch != nil {
+			span := ast.SpanOf(node.Catch)
+			if node.Param != nil {
+				span.Start = node.Param.Token.Pos
+			}
+			cs := a.newScope(s, span, false)
+			a.declare(cs, node.Param, CatchBinding, node)
+			a.visit(node.Catch, cs)
+		}
+		a.visit(node.Finally, s)
+	case *ast.MemberExpression:
+		// 成员名不是对变量的引用
+		a.visit(node.Object, s)
+	case *ast.NamedArgument:
+		a.visit(node.Value, s)
+	default:
+		for _, child := range ast.Children(node) {
+			a.visit(child.Node, s)
+		}
+	}
+}
+
+// BindingOf 返回标识符对应的绑定, 标识符既可以是定义也可以是引用
+func (a *Analysis) BindingOf(ident *ast.Identifier) *Binding {
+	return a.idents[ident]
+}
+
+// IdentifierAt 返回位于 pos 的标识符, 光标紧跟在标识符之后时也算
+func (a *Analysis) IdentifierAt(pos token.Position) *ast.Identifier {
+	positions := []token.Position{pos}
+	if pos.Column > 1 {
+		positions = append(positions, token.Position{Offset: pos.Offset - 1, Line: pos.Line, Column: pos.Column - 1})
+	}
+	for _, p := range positions {
+		path := ast.Path(a.program, p)
+		if len(path) == 0 {
+			continue
+		}
+		if ident, ok := path[len(path)-1].(*ast.Identifier); ok {
+			return ident
+		}
+	}
+	return nil
+}
+
+// VisibleAt 返回在 pos 处可以使用的绑定, 内层的绑定遮蔽外层的同名绑定, 结果按名字排序
+// 同一函数内只能使用 pos 之前定义的名字, 外层函数中的名字在调用时可能已经定义, 不受这个限制
+func (a *Analysis) VisibleAt(pos token.Position) []*Binding {
+	// 作用域按先序记录, 包含 pos 的最后一个作用域就是最内层的作用域
+	innermost := a.scopes[0]
+	for _, s := range a.scopes[1:] {
+		if s.span.Contains(pos) {
+			innermost = s
+		}
+	}
+
+	seen := make(map[string]bool)
+	var visible []*Binding
+	crossedFunction := false
+	for s := innermost; s != nil; s = s.parent {
+		for i := len(s.bindings) - 1; i >= 0; i-- {
+			b := s.bindings[i]
+			if seen[b.Name] || !crossedFunction && !b.Decl.Token.Pos.Before(pos) || defining(b, pos) {
+				continue
+			}
+			seen[b.Name] = true
+			visible = append(visible, b)
+		}
+		if s.function {
+			crossedFunction = true
+		}
+	}
+	sort.Slice(visible, func(i, j int) bool { return visible[i].Name < visible[j].Name })
+	return visible
+}
+
+// defining 判断 pos 是否位于绑定自己的 let 语句中, 此时名字还没有定义, 只有函数可以递归引用自己
+func defining(b *Binding, pos token.Position) bool {
+	let, ok := b.Node.(*ast.LetStatement)
+	if !ok || !ast.SpanOf(let).Contains(pos) {
+		return false
+	}
+	_, isFunction := let.Value.(*ast.FunctionLiteral)
+	return !isFunction
+}
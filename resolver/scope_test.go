@@ -0,0 +1,66 @@
+package resolver
+
+import (
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/parser"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestAnalyze 每个用例列出所有引用 "名字@引用所在行:列 -> 定义所在行:列", 找不到定义的引用写作 "名字@行:列 -> ?"
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let x = 1; let x = x + 1; x",
+			[]string{"x@1:20 -> 1:5", "x@1:27 -> 1:16"},
+		},
+		{
+			// 相互递归的函数可以引用后面定义的名字
+			"let even = fn(n) { odd(n) }; let odd = fn(n) { even(n) };",
+			[]string{"even@1:48 -> 1:5", "n@1:24 -> 1:15", "n@1:53 -> 1:43", "odd@1:20 -> 1:34"},
+		},
+		{
+			"let f = fn(a, b = a) { f(b) }",
+			[]string{"a@1:19 -> 1:12", "b@1:26 -> 1:15", "f@1:24 -> 1:5"},
+		},
+		{
+			"match (v) { [h, ...t] if h > 0 => t, n => n }",
+			[]string{"h@1:26 -> 1:14", "n@1:43 -> 1:38", "t@1:35 -> 1:20", "v@1:8 -> ?"},
+		},
+		{
+			`import "m" as m; try { m.f(y) } catch (e) { e.message }`,
+			[]string{"e@1:45 -> 1:40", "m@1:24 -> 1:15", "y@1:28 -> ?"},
+		},
+		{
+			"let {name, age: years} = p; f(name, key: years)",
+			[]string{"f@1:29 -> ?", "name@1:31 -> 1:6", "p@1:26 -> ?", "years@1:42 -> 1:17"},
+		},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+		a := Analyze(program)
+
+		var got []string
+		for _, b := range a.Bindings {
+			for _, ref := range b.References {
+				got = append(got, ref.Value+"@"+ref.Token.Pos.String()+" -> "+b.Decl.Token.Pos.String())
+			}
+		}
+		for _, ref := range a.Unresolved {
+			got = append(got, ref.Value+"@"+ref.Token.Pos.String()+" -> ?")
+		}
+		sort.Strings(got)
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong resolution for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
@@ -1,10 +1,73 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // 第一个字符的位置
+	End     Position // 最后一个字符之后的位置
+}
+
+// Position 源代码中的位置, 行和列从 1 开始, 列按字节计算
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// IsValid 手工构造的词法单元没有位置信息, 行号为 0
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// Before 按行列比较两个位置, 只有行列信息的位置(例如命令行参数)也可以比较
+func (p Position) Before(q Position) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Column < q.Column
+}
+
+// ParsePosition 解析 "行:列" 形式的位置, 结果没有 Offset
+func ParsePosition(s string) (Position, error) {
+	var p Position
+	if _, err := fmt.Sscanf(s, "%d:%d", &p.Line, &p.Column); err != nil || !p.IsValid() || p.Column < 1 {
+		return p, fmt.Errorf("invalid position %q, want line:column", s)
+	}
+	return p, nil
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Span 源代码中的一段区间, 不包含 End
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Contains 判断位置是否在区间内, 按行列比较
+func (s Span) Contains(p Position) bool {
+	return !p.Before(s.Start) && p.Before(s.End)
+}
+
+// Covers 判断另一个区间是否完全在这个区间内
+func (s Span) Covers(other Span) bool {
+	return !other.Start.Before(s.Start) && !s.End.Before(other.End)
+}
+
+func (s Span) String() string {
+	return s.Start.String() + "-" + s.End.String()
+}
+
+// Comment 源代码中的一行注释, Text 包含开头的 "//", 不包含行尾的换行符
+type Comment struct {
+	Text string `json:"text"`
+	Span Span   `json:"span"`
 }
 
 const (
@@ -14,6 +77,8 @@ const (
 	// 标识符 + 字面量
 	IDENT = "IDENT" // add, foobar, x, y, ...
 	INT   = "INT"   // 1343456
+	FLOAT = "FLOAT" // 3.14
+	REGEX = "REGEX" // /[a-z]+/i
 
 	// 运算法
 	ASSIGN   = "="
@@ -22,6 +87,7 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 
 	LT = "<"
 	GT = ">"
@@ -30,6 +96,10 @@ const (
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	DOT       = "."
+	ELLIPSIS  = "..."
+	ARROW     = "=>"
+	RARROW    = "->" // 函数类型注解中的返回类型
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -47,6 +117,13 @@ const (
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
 	STRING   = "STRING"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+	AS       = "AS"
+	THROW    = "THROW"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
 
 	// 比较字符
 	EQ     = "=="
@@ -54,13 +131,30 @@ const (
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":      FUNCTION,
+	"let":     LET,
+	"true":    TRUE,
+	"false":   FALSE,
+	"if":      IF,
+	"else":    ELSE,
+	"return":  RETURN,
+	"import":  IMPORT,
+	"export":  EXPORT,
+	"as":      AS,
+	"throw":   THROW,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+}
+
+// Keywords 返回所有关键字, 按字母顺序排列
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
 }
 
 func LookupIdent(ident string) TokenType {
@@ -0,0 +1,105 @@
+package types
+
+// builtins 内置函数的类型, 参数或结果的类型取决于参数的值时用 any
+var builtins = map[string]*Function{
+	"abs":            fn(Any, Float),
+	"append_file":    fn(Null, String, String),
+	"captures":       fn(Any, Any, String),
+	"ceil":           fn(Int, Float),
+	"clamp":          fn(Any, Float, Float, Float),
+	"eprint":         variadic(Null, Any),
+	"exists":         fn(Bool, String),
+	"exit":           optional(fn(Any, Int), 0),
+	"find_all":       fn(&Array{Element: String}, Any, String),
+	"first":          fn(Any, &Array{Element: Any}),
+	"floor":          fn(Int, Float),
+	"gcd":            variadic(Int, Int, Int, Int),
+	"input":          optional(fn(Any, Any), 0),
+	"json_parse":     fn(Any, String),
+	"json_stringify": optional(fn(String, Any, Any), 1),
+	"len":            fn(Int, String),
+	"list_dir":       optional(fn(&Array{Element: String}, String), 0),
+	"match":          fn(Bool, Any, String),
+	"max":            variadic(Any, Any, Any),
+	"min":            variadic(Any, Any, Any),
+	"pow":            fn(Any, Float, Float),
+	"print":          variadic(Null, Any),
+	"push":           fn(&Array{Element: Any}, &Array{Element: Any}, Any),
+	"puts":           variadic(Null, Any),
+	"rand_int":       optional(fn(Int, Int, Int), 1),
+	"read_file":      fn(String, String),
+	"read_lines":     fn(&Array{Element: String}, String),
+	"regex":          fn(Regex, String),
+	"replace_all":    fn(String, Any, String, String),
+	"rest":           fn(Any, &Array{Element: Any}),
+	"round":          fn(Int, Float),
+	"shuffle":        fn(&Array{Element: Any}, &Array{Element: Any}),
+	"sqrt":           fn(Any, Float),
+	"sum":            variadic(Any, Any),
+	"write_file":     fn(Null, String, String),
+}
+
+// fn 参数都必须传入的函数类型
+func fn(result Type, params ...Type) *Function {
+	return &Function{Params: params, Required: len(params), Return: result}
+}
+
+// optional 只有前 required 个参数必须传入的函数类型
+func optional(f *Function, required int) *Function {
+	f.Required = required
+	return f
+}
+
+// variadic 最后一个参数类型为剩余参数的函数类型, 之前的参数都必须传入
+func variadic(result Type, params ...Type) *Function {
+	last := len(params) - 1
+	return &Function{Params: params[:last], Required: last, Rest: params[last], Return: result}
+}
+
+// alpha 是内置函数类型方案中的类型变量, 每次使用内置函数时换成新的变量
+var alpha = &Var{id: -1}
+
+// schemes Infer 使用的内置函数的类型方案
+// 与 builtins 不同, 只对数组操作的函数用类型变量表示参数和结果之间的关系, 数字参数统一为 float
+var schemes = map[string]*Scheme{
+	"abs":            generic(fn(Float, Float)),
+	"append_file":    generic(fn(Null, String, String)),
+	"captures":       generic(fn(Any, Any, String)),
+	"ceil":           generic(fn(Int, Float)),
+	"clamp":          generic(fn(Float, Float, Float, Float)),
+	"eprint":         generic(variadic(Null, Any)),
+	"exists":         generic(fn(Bool, String)),
+	"exit":           generic(optional(fn(alpha, Int), 0)),
+	"find_all":       generic(fn(&Array{Element: String}, Any, String)),
+	"first":          generic(fn(alpha, &Array{Element: alpha})),
+	"floor":          generic(fn(Int, Float)),
+	"gcd":            generic(variadic(Int, Int, Int, Int)),
+	"input":          generic(optional(fn(String, Any), 0)),
+	"json_parse":     generic(fn(Any, String)),
+	"json_stringify": generic(optional(fn(String, Any, Any), 1)),
+	"len":            generic(fn(Int, String)),
+	"list_dir":       generic(optional(fn(&Array{Element: String}, String), 0)),
+	"match":          generic(fn(Bool, Any, String)),
+	"max":            generic(variadic(Any, Any, Any)),
+	"min":            generic(variadic(Any, Any, Any)),
+	"pow":            generic(fn(Float, Float, Float)),
+	"print":          generic(variadic(Null, Any)),
+	"push":           generic(fn(&Array{Element: alpha}, &Array{Element: alpha}, alpha)),
+	"puts":           generic(variadic(Null, Any)),
+	"rand_int":       generic(optional(fn(Int, Int, Int), 1)),
+	"read_file":      generic(fn(String, String)),
+	"read_lines":     generic(fn(&Array{Element: String}, String)),
+	"regex":          generic(fn(Regex, String)),
+	"replace_all":    generic(fn(String, Any, String, String)),
+	"rest":           generic(fn(&Array{Element: alpha}, &Array{Element: alpha})),
+	"round":          generic(fn(Int, Float)),
+	"shuffle":        generic(fn(&Array{Element: alpha}, &Array{Element: alpha})),
+	"sqrt":           generic(fn(Float, Float)),
+	"sum":            generic(variadic(Any, Any)),
+	"write_file":     generic(fn(Null, String, String)),
+}
+
+// generic 对类型中出现的所有类型变量全称量化
+func generic(t Type) *Scheme {
+	return &Scheme{Vars: freeVars(t, nil), Type: t}
+}
@@ -0,0 +1,606 @@
+package types
+
+import (
+	"fmt"
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/resolver"
+	"sort"
+)
+
+// Mismatch 类型错误的诊断种类
+const Mismatch = "type"
+
+// never 不会产生值的语句的类型, 例如 return 和 throw, 它与任何类型合并后都是另一个类型
+const never Basic = "never"
+
+// Check 检查程序中的类型错误, 按位置排序返回
+// 名字通过 resolver 解析, 未定义的名字的类型是 any, 由 resolver 自己报告
+func Check(program *ast.Program) []resolver.Diagnostic {
+	c := &checker{
+		analysis:   resolver.Analyze(program),
+		types:      make(map[*resolver.Binding]Type),
+		signatures: make(map[*ast.FunctionLiteral]*signature),
+		exprs:      make(map[ast.Expression]Type),
+	}
+	c.statements(program.Statements)
+	return c.sorted()
+}
+
+type checker struct {
+	analysis   *resolver.Analysis
+	types      map[*resolver.Binding]Type // 每个绑定的类型, 没有记录的是 any
+	signatures map[*ast.FunctionLiteral]*signature
+	exprs      map[ast.Expression]Type // 已经检查过的表达式的类型
+	fn         *function               // 正在检查的函数, 顶层为 nil
+	reporter
+}
+
+// signature 函数字面量的注解, 每个函数只转换一次, 注解中的错误只报告一次
+type signature struct {
+	fn     *Function
+	params []Type // 与 Parameters 一一对应, 剩余参数为数组类型
+}
+
+// function 正在检查的函数体的上下文
+type function struct {
+	name     string
+	declared Type   // 返回值的类型注解, 没有注解时为 nil
+	returns  []Type // 函数体中 return 语句的类型
+}
+
+// reporter 收集类型错误, 由 Check 和 Infer 共用
+type reporter struct {
+	diagnostics []resolver.Diagnostic
+}
+
+func (r *reporter) errorf(node ast.Node, format string, args ...interface{}) {
+	r.diagnostics = append(r.diagnostics, resolver.Diagnostic{
+		Kind:     Mismatch,
+		Severity: resolver.Error,
+		Message:  fmt.Sprintf(format, args...),
+		Span:     ast.SpanOf(node),
+	})
+}
+
+// sorted 按位置排序后的诊断
+func (r *reporter) sorted() []resolver.Diagnostic {
+	sort.SliceStable(r.diagnostics, func(i, j int) bool {
+		return r.diagnostics[i].Span.Start.Before(r.diagnostics[j].Span.Start)
+	})
+	return r.diagnostics
+}
+
+// annotation 把类型注解转换成类型, 不认识的类型名报错并当作 any
+func (r *reporter) annotation(t ast.TypeExpr) Type {
+	switch t := t.(type) {
+	case *ast.NamedType:
+		if b, ok := basics[t.Name]; ok {
+			return b
+		}
+		r.errorf(t, "unknown type %s", t.Name)
+	case *ast.ArrayType:
+		return &Array{Element: r.annotation(t.Element)}
+	case *ast.HashType:
+		key := r.annotation(t.Key)
+		if !hashable(key) {
+			r.errorf(t.Key, "unusable as hash key: %s", key)
+		}
+		return &Hash{Key: key, Value: r.annotation(t.Value)}
+	case *ast.FunctionType:
+		fn := &Function{Required: len(t.Parameters), Return: Any}
+		for _, p := range t.Parameters {
+			fn.Params = append(fn.Params, r.annotation(p))
+		}
+		if t.Return != nil {
+			fn.Return = r.annotation(t.Return)
+		}
+		return fn
+	}
+	return Any
+}
+
+// statements 检查一组语句, 返回最后一条语句的值的类型, 没有语句时为 null
+func (c *checker) statements(stmts []ast.Statement) Type {
+	var result Type = Null
+	for _, stmt := range stmts {
+		result = c.statement(stmt)
+	}
+	return result
+}
+
+func (c *checker) statement(stmt ast.Statement) Type {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.let(stmt)
+		return Null
+	case *ast.ExportStatement:
+		if stmt.Statement != nil {
+			c.let(stmt.Statement)
+		}
+		return Null
+	case *ast.ReturnStatement:
+		var t Type = Null
+		var node ast.Node = stmt
+		if stmt.ReturnValue != nil {
+			t, node = c.expression(stmt.ReturnValue), stmt.ReturnValue
+		}
+		if c.fn != nil {
+			c.fn.returns = append(c.fn.returns, t)
+			c.checkReturn(node, t)
+		}
+		return never
+	case *ast.ThrowStatement:
+		c.expression(stmt.Value)
+		return never
+	case *ast.ExpressionStatement:
+		return c.expression(stmt.Expression)
+	}
+	return Null
+}
+
+// checkReturn 检查函数的结果是否符合返回值的类型注解, node 是结果的表达式, 或者没有结果时的语句
+func (c *checker) checkReturn(node ast.Node, t Type) {
+	if c.fn.declared == nil {
+		return
+	}
+	if e, ok := node.(ast.Expression); ok {
+		c.assign(e, c.fn.declared, "return value of "+c.fn.name)
+	} else if !Assignable(c.fn.declared, t) {
+		c.errorf(node, "cannot use %s as %s in return value of %s", t, c.fn.declared, c.fn.name)
+	}
+}
+
+// assign 检查已经检查过的表达式 e 的值能否用在需要 to 类型的地方, context 描述这个地方
+func (c *checker) assign(e ast.Expression, to Type, context string) {
+	if node, from, want := c.mismatch(e, to); node != nil {
+		c.errorf(node, "cannot use %s as %s in %s", from, want, context)
+	}
+}
+
+// mismatch 找出表达式中不符合类型 to 的部分, 返回这部分的表达式、它的类型和需要的类型
+// 字面量的元素类型不同时会合并为 any, 所以数组和哈希字面量要逐个检查其中的元素
+func (c *checker) mismatch(e ast.Expression, to Type) (ast.Expression, Type, Type) {
+	from, ok := c.exprs[e]
+	if !ok {
+		from = Any
+	}
+	if !Assignable(to, from) {
+		return e, from, to
+	}
+	switch e := e.(type) {
+	case *ast.ArrayLiteral:
+		if arr, ok := to.(*Array); ok {
+			for _, el := range e.Elements {
+				if node, from, want := c.mismatch(el, arr.Element); node != nil {
+					return node, from, want
+				}
+			}
+		}
+	case *ast.HashLiteral:
+		if hash, ok := to.(*Hash); ok {
+			for _, k := range e.OrderedKeys() {
+				if node, from, want := c.mismatch(k, hash.Key); node != nil {
+					return node, from, want
+				}
+				if node, from, want := c.mismatch(e.Pairs[k], hash.Value); node != nil {
+					return node, from, want
+				}
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+func (c *checker) let(stmt *ast.LetStatement) {
+	var declared Type
+	if stmt.Type != nil {
+		declared = c.annotation(stmt.Type)
+	}
+	// 先记下函数的签名, 函数体中的递归调用才能用上参数和返回值的注解
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok && stmt.Name != nil {
+		c.bind(stmt.Name, c.signature(fl).fn)
+	}
+	t := c.expression(stmt.Value)
+	if declared != nil {
+		c.assign(stmt.Value, declared, "let "+stmt.Target().String())
+		t = declared
+	}
+	c.bind(stmt.Target(), t)
+}
+
+// bind 按模式的结构把类型记到模式绑定的名字上
+func (c *checker) bind(pattern ast.Pattern, t Type) {
+	if t == never {
+		t = Any
+	}
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if b := c.analysis.BindingOf(pattern); b != nil {
+			c.types[b] = t
+		}
+	case *ast.ArrayPattern:
+		var element Type = Any
+		if arr, ok := t.(*Array); ok {
+			element = arr.Element
+		}
+		for _, el := range pattern.Elements {
+			if rest, ok := el.(*ast.RestPattern); ok {
+				c.bind(rest.Name, &Array{Element: element})
+				continue
+			}
+			c.bind(el, element)
+		}
+	case *ast.HashPattern:
+		var value Type = Any
+		if hash, ok := t.(*Hash); ok {
+			value = hash.Value
+		}
+		for _, pair := range pattern.Pairs {
+			c.bind(pair.Value, value)
+		}
+		if pattern.Rest != nil {
+			c.bind(pattern.Rest.Name, t)
+		}
+	case *ast.RestPattern:
+		c.bind(pattern.Name, t)
+	case *ast.DefaultPattern:
+		c.bind(pattern.Target, t)
+	case *ast.TypedPattern:
+		c.bind(pattern.Target, t)
+	}
+}
+
+// signature 根据参数和返回值的注解得到函数的类型, 没有注解的部分是 any
+func (c *checker) signature(fl *ast.FunctionLiteral) *signature {
+	if sig, ok := c.signatures[fl]; ok {
+		return sig
+	}
+	sig := &signature{fn: &Function{Return: Any}}
+	for _, param := range fl.Parameters {
+		var t Type = Any
+		hasDefault := false
+		if def, ok := param.(*ast.DefaultPattern); ok {
+			param, hasDefault = def.Target, true
+		}
+		if typed, ok := param.(*ast.TypedPattern); ok {
+			param, t = typed.Target, c.annotation(typed.Type)
+		}
+		if _, isRest := param.(*ast.RestPattern); isRest {
+			sig.fn.Rest = Any
+			if arr, ok := t.(*Array); ok {
+				sig.fn.Rest = arr.Element
+			} else if t != Any {
+				c.errorf(param, "rest parameter %s must have an array type, got %s", param, t)
+			}
+			sig.params = append(sig.params, &Array{Element: sig.fn.Rest})
+			continue
+		}
+		sig.fn.Params = append(sig.fn.Params, t)
+		if !hasDefault {
+			sig.fn.Required = len(sig.fn.Params)
+		}
+		sig.params = append(sig.params, t)
+	}
+	if fl.ReturnType != nil {
+		sig.fn.Return = c.annotation(fl.ReturnType)
+	}
+	c.signatures[fl] = sig
+	return sig
+}
+
+// function 检查函数字面量, 没有返回值注解时根据 return 语句和最后一条语句推断返回值的类型
+func (c *checker) function(fl *ast.FunctionLiteral) Type {
+	sig := c.signature(fl)
+	for i, param := range fl.Parameters {
+		t := sig.params[i]
+		if def, ok := param.(*ast.DefaultPattern); ok {
+			c.expression(def.Default)
+			c.assign(def.Default, t, "default value of parameter "+def.Target.String())
+		}
+		c.bind(param, t)
+	}
+
+	outer := c.fn
+	name := fl.Name
+	if name == "" {
+		name = "anonymous function"
+	}
+	c.fn = &function{name: name}
+	if fl.ReturnType != nil {
+		c.fn.declared = sig.fn.Return
+	}
+	// 最后一条语句的值也是函数的结果, 最后是 let 或者函数体为空时结果是 null
+	result := c.statements(fl.Body.Statements)
+	var last ast.Node = fl.Body
+	if n := len(fl.Body.Statements); n > 0 {
+		if es, ok := fl.Body.Statements[n-1].(*ast.ExpressionStatement); ok {
+			last = es.Expression
+		}
+	}
+	c.checkReturn(last, result)
+	fn := sig.fn
+	if c.fn.declared == nil {
+		for _, t := range c.fn.returns {
+			result = Join(result, t)
+		}
+		if result == never {
+			result = Any
+		}
+		// 推断出的返回值类型不修改签名, 函数体中的递归调用看到的仍然是 any
+		fn = &Function{Params: fn.Params, Required: fn.Required, Rest: fn.Rest, Return: result}
+	}
+	c.fn = outer
+	return fn
+}
+
+// expression 检查表达式并返回它的类型
+func (c *checker) expression(e ast.Expression) Type {
+	t := c.infer(e)
+	c.exprs[e] = t
+	return t
+}
+
+func (c *checker) infer(e ast.Expression) Type {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.FloatLiteral:
+		return Float
+	case *ast.StringLiteral:
+		return String
+	case *ast.Boolean:
+		return Bool
+	case *ast.RegexLiteral:
+		return Regex
+	case *ast.Identifier:
+		return c.identifier(e)
+	case *ast.PrefixExpression:
+		return c.prefix(e)
+	case *ast.InfixExpression:
+		return c.infix(e)
+	case *ast.IfExpression:
+		c.expression(e.Condition)
+		t := c.block(e.Consequence)
+		if e.Alternative == nil {
+			return Join(t, Null)
+		}
+		return Join(t, c.block(e.Alternative))
+	case *ast.FunctionLiteral:
+		return c.function(e)
+	case *ast.CallExpression:
+		return c.call(e)
+	case *ast.NamedArgument:
+		return c.expression(e.Value)
+	case *ast.ArrayLiteral:
+		var element Type = never
+		for _, el := range e.Elements {
+			element = Join(element, c.expression(el))
+		}
+		if element == never {
+			element = Any
+		}
+		return &Array{Element: element}
+	case *ast.HashLiteral:
+		return c.hash(e)
+	case *ast.IndexExpression:
+		return c.index(e)
+	case *ast.SliceExpression:
+		left := c.expression(e.Left)
+		for _, bound := range []ast.Expression{e.Start, e.End, e.Step} {
+			if bound != nil {
+				if t := c.expression(bound); !Assignable(Int, t) {
+					c.errorf(bound, "slice index must be int, got %s", t)
+				}
+			}
+		}
+		switch left.(type) {
+		case *Array:
+			return left
+		}
+		if left == String {
+			return String
+		}
+		return Any
+	case *ast.MemberExpression:
+		// 哈希的成员就是对应的键, 其他值的成员只有运行时才知道
+		if hash, ok := c.expression(e.Object).(*Hash); ok && Assignable(hash.Key, String) {
+			return hash.Value
+		}
+		return Any
+	case *ast.MatchExpression:
+		subject := c.expression(e.Subject)
+		var t Type = never
+		for _, arm := range e.Arms {
+			c.bind(arm.Pattern, subject)
+			if arm.Guard != nil {
+				c.expression(arm.Guard)
+			}
+			t = Join(t, c.expression(arm.Body))
+		}
+		// 没有分支匹配时结果是 null
+		return Join(t, Null)
+	case *ast.TryExpression:
+		t := c.block(e.Block)
+		if e.Param != nil {
+			c.bind(e.Param, &Hash{Key: String, Value: Any})
+		}
+		if e.Catch != nil {
+			t = Join(t, c.block(e.Catch))
+		}
+		if e.Finally != nil {
+			c.block(e.Finally)
+		}
+		return t
+	}
+	return Any
+}
+
+func (c *checker) block(block *ast.BlockStatement) Type {
+	if block == nil {
+		return Null
+	}
+	return c.statements(block.Statements)
+}
+
+func (c *checker) identifier(ident *ast.Identifier) Type {
+	if b := c.analysis.BindingOf(ident); b != nil {
+		if t, ok := c.types[b]; ok {
+			return t
+		}
+		return Any
+	}
+	if fn, ok := builtins[ident.Value]; ok {
+		return fn
+	}
+	return Any
+}
+
+func (c *checker) prefix(e *ast.PrefixExpression) Type {
+	right := c.expression(e.Right)
+	switch e.Operator {
+	case "!":
+		return Bool
+	case "-":
+		if isNumeric(right) || isDynamic(right) {
+			return right
+		}
+	}
+	c.errorf(e, "unknown operator: %s%s", e.Operator, right)
+	return Any
+}
+
+// infix 按求值器的规则检查中缀表达式: 数字之间可以做算术和比较, 字符串只能相加, 任何值都可以用 == 和 != 比较
+func (c *checker) infix(e *ast.InfixExpression) Type {
+	left, right := c.expression(e.Left), c.expression(e.Right)
+	comparison := e.Operator == "<" || e.Operator == ">" || e.Operator == "==" || e.Operator == "!="
+	switch {
+	case isNumeric(left) && isNumeric(right):
+		if comparison {
+			return Bool
+		}
+		if left == Int && right == Int {
+			return Int
+		}
+		return Float
+	case e.Operator == "==" || e.Operator == "!=":
+		return Bool
+	case isDynamic(left) || isDynamic(right):
+		if comparison {
+			return Bool
+		}
+		// 另一边是字符串时结果只能是字符串, 是数字时可能是 int 或 float
+		if e.Operator == "+" && (left == String || right == String) {
+			return String
+		}
+		return Any
+	case !Identical(left, right):
+		c.errorf(e, "type mismatch: %s %s %s", left, e.Operator, right)
+	case left == String && e.Operator == "+":
+		return String
+	default:
+		c.errorf(e, "unknown operator: %s %s %s", left, e.Operator, right)
+	}
+	return Any
+}
+
+func isDynamic(t Type) bool {
+	return t == Any || t == never
+}
+
+func (c *checker) call(e *ast.CallExpression) Type {
+	// obj.f(args) 在运行时才知道调用的是成员还是 f(obj, args)
+	if member, ok := e.Function.(*ast.MemberExpression); ok {
+		c.expression(member.Object)
+		for _, arg := range e.Arguments {
+			c.expression(arg)
+		}
+		return Any
+	}
+	callee := c.expression(e.Function)
+	args := make([]Type, len(e.Arguments))
+	named := false
+	for i, arg := range e.Arguments {
+		args[i] = c.expression(arg)
+		if _, ok := arg.(*ast.NamedArgument); ok {
+			named = true
+		}
+	}
+
+	fn, ok := callee.(*Function)
+	if !ok {
+		if !isDynamic(callee) {
+			c.errorf(e.Function, "not a function: %s", callee)
+		}
+		return Any
+	}
+	// 具名参数按名字对应到参数, 这里只检查全部是位置参数的调用
+	if named {
+		return fn.Return
+	}
+	name := callName(e.Function)
+	switch {
+	case len(args) < fn.Required:
+		c.errorf(e, "%s: not enough arguments, want at least %d, got %d", name, fn.Required, len(args))
+	case !fn.accepts(len(args)):
+		c.errorf(e, "%s: too many arguments, want at most %d, got %d", name, len(fn.Params), len(args))
+	default:
+		for i, arg := range e.Arguments {
+			c.assign(arg, fn.param(i), fmt.Sprintf("argument %d to %s", i+1, name))
+		}
+	}
+	return fn.Return
+}
+
+// callName 用于错误信息的被调用函数的名字
+func callName(e ast.Expression) string {
+	if ident, ok := e.(*ast.Identifier); ok {
+		return ident.Value
+	}
+	return "function"
+}
+
+func (c *checker) hash(e *ast.HashLiteral) Type {
+	var key, value Type = never, never
+	for _, k := range e.OrderedKeys() {
+		kt := c.expression(k)
+		if !hashable(kt) && kt != never {
+			c.errorf(k, "unusable as hash key: %s", kt)
+			kt = Any
+		}
+		key = Join(key, kt)
+		value = Join(value, c.expression(e.Pairs[k]))
+	}
+	if key == never {
+		key = Any
+	}
+	if value == never {
+		value = Any
+	}
+	return &Hash{Key: key, Value: value}
+}
+
+func (c *checker) index(e *ast.IndexExpression) Type {
+	left, index := c.expression(e.Left), c.expression(e.Index)
+	switch left := left.(type) {
+	case *Array:
+		if !Assignable(Int, index) {
+			c.errorf(e.Index, "cannot index %s with %s", left, index)
+		}
+		return left.Element
+	case *Hash:
+		if !Assignable(left.Key, index) {
+			c.errorf(e.Index, "cannot index %s with %s", left, index)
+		}
+		return left.Value
+	}
+	switch {
+	case left == String:
+		if !Assignable(Int, index) {
+			c.errorf(e.Index, "cannot index %s with %s", left, index)
+		}
+		return String
+	case !isDynamic(left):
+		c.errorf(e, "index operation not supported: %s", left)
+	}
+	return Any
+}
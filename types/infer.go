@@ -0,0 +1,851 @@
+package types
+
+import (
+	"fmt"
+	"github.com/fanyeke/monkey/ast"
+	"github.com/fanyeke/monkey/resolver"
+	"strconv"
+	"strings"
+)
+
+// Var Hindley-Milner 推断中的类型变量, 合一时绑定到它代表的类型
+type Var struct {
+	id       int
+	level    int        // 引入变量时 let 的嵌套深度, 深度比当前 let 大的变量可以泛化
+	instance Type       // 绑定到的类型, 还没有绑定时为 nil
+	class    constraint // 运算符对变量的约束, 变量只能绑定到满足约束的类型
+}
+
+// constraint 类似类型类的约束, 记录类型变量做过的运算
+// 约束跟着类型方案实例化, 所以 let sub = fn(a, b) { a - b } 不能用于字符串
+type constraint string
+
+const (
+	unconstrained constraint = ""
+	numeric       constraint = "number"          // 用于 - * / % < > 和前缀 -
+	addable       constraint = "number | string" // 用于 +
+)
+
+// satisfies 判断已知的类型 t 是否满足约束
+func (c constraint) satisfies(t Type) bool {
+	switch {
+	case c == unconstrained || t == Any || t == never:
+		return true
+	case c == addable && t == String:
+		return true
+	}
+	return isNumeric(t)
+}
+
+// merge 合一两个带约束的变量时约束取交集
+func (c constraint) merge(other constraint) constraint {
+	switch {
+	case c == unconstrained:
+		return other
+	case other == unconstrained:
+		return c
+	case c == numeric || other == numeric:
+		return numeric
+	}
+	return addable
+}
+
+func (v *Var) String() string {
+	if v.instance != nil {
+		return v.instance.String()
+	}
+	return "'t" + strconv.Itoa(v.id)
+}
+
+// Scheme 类型方案, 即对 Vars 全称量化的类型, 例如 let id = fn(x) { x } 中 id 的类型 fn('a) -> 'a
+// 每次使用名字时 Vars 都换成新的类型变量, 所以 id 可以同时用于不同类型的参数
+type Scheme struct {
+	Vars []*Var
+	Type Type
+}
+
+func (s *Scheme) String() string {
+	n := namer{}
+	return n.apply(s.Type).String() + n.where(s.Type)
+}
+
+// Signature 顶层函数推断出的类型
+type Signature struct {
+	Name *ast.Identifier
+	Type *Scheme
+}
+
+func (s Signature) String() string { return s.Name.Value + ": " + s.Type.String() }
+
+// Infer 不依赖注解, 用 Hindley-Milner 算法推断程序中的类型, 返回顶层函数的签名和合一失败的诊断
+// 绑定到函数字面量的 let 会被泛化; 注解、any 和取值不确定的表达式(成员调用、模块等)与任何类型合一,
+// 整数和浮点数可以互相合一, 数组和哈希字面量的元素类型不同时为 any, 以便表示 JSON 那样的数据
+func Infer(program *ast.Program) ([]Signature, []resolver.Diagnostic) {
+	in := &inferer{
+		analysis: resolver.Analyze(program),
+		schemes:  make(map[*resolver.Binding]*Scheme),
+	}
+	var signatures []Signature
+	for _, stmt := range program.Statements {
+		in.statement(stmt)
+		let, ok := stmt.(*ast.LetStatement)
+		if export, isExport := stmt.(*ast.ExportStatement); isExport {
+			let, ok = export.Statement, export.Statement != nil
+		}
+		if !ok || let.Name == nil {
+			continue
+		}
+		if _, isFn := let.Value.(*ast.FunctionLiteral); isFn {
+			signatures = append(signatures, Signature{Name: let.Name, Type: in.schemes[in.analysis.BindingOf(let.Name)]})
+		}
+	}
+	return signatures, in.sorted()
+}
+
+type inferer struct {
+	analysis *resolver.Analysis
+	schemes  map[*resolver.Binding]*Scheme // 每个绑定的类型方案, 没有记录的是 any
+	level    int                           // 当前 let 的嵌套深度
+	vars     int                           // 已经创建的类型变量个数
+	trail    []change                      // 合一对类型变量的修改, 用于撤销尝试性的合一
+	fn       *frame                        // 正在推断的函数, 顶层为 nil
+	reporter
+}
+
+// change 合一修改类型变量之前它的深度和约束, 撤销时还原它们并解除绑定
+type change struct {
+	v     *Var
+	level int
+	class constraint
+}
+
+// frame 正在推断的函数体的上下文
+type frame struct {
+	name   string
+	result Type // 函数的结果类型, 所有 return 语句和最后一条语句的类型都与它合一
+}
+
+func (in *inferer) fresh() *Var {
+	in.vars++
+	return &Var{id: in.vars, level: in.level}
+}
+
+// prune 沿着已经绑定的类型变量找到它们代表的类型
+func prune(t Type) Type {
+	for {
+		v, ok := t.(*Var)
+		if !ok || v.instance == nil {
+			return t
+		}
+		t = v.instance
+	}
+}
+
+// unify 合一两个类型, 失败时返回 false, 已经做出的绑定由调用者决定是否撤销
+func (in *inferer) unify(a, b Type) bool {
+	a, b = prune(a), prune(b)
+	if a == b || a == never || b == never {
+		return true
+	}
+	if v, ok := a.(*Var); ok {
+		return in.bind(v, b)
+	}
+	if v, ok := b.(*Var); ok {
+		return in.bind(v, a)
+	}
+	if a == Any || b == Any {
+		return true
+	}
+	switch a := a.(type) {
+	case Basic:
+		return isNumeric(a) && isNumeric(b)
+	case *Array:
+		b, ok := b.(*Array)
+		return ok && in.unify(a.Element, b.Element)
+	case *Hash:
+		b, ok := b.(*Hash)
+		return ok && in.unify(a.Key, b.Key) && in.unify(a.Value, b.Value)
+	case *Function:
+		b, ok := b.(*Function)
+		// 参数个数不同时, 一边要能用另一边的参数个数调用, 多出的参数有默认值或由剩余参数接收
+		if !ok || !a.accepts(len(b.Params)) && !b.accepts(len(a.Params)) {
+			return false
+		}
+		n := len(a.Params)
+		if len(b.Params) > n {
+			n = len(b.Params)
+		}
+		for i := 0; i < n; i++ {
+			if !in.unify(a.param(i), b.param(i)) {
+				return false
+			}
+		}
+		if a.Rest != nil && b.Rest != nil && !in.unify(a.Rest, b.Rest) {
+			return false
+		}
+		return in.unify(a.Return, b.Return)
+	}
+	return false
+}
+
+// bind 把类型变量绑定到类型 t, t 中包含这个变量(无限类型)或者 t 不满足 v 的约束时失败
+// t 中的变量的深度降到 v 的深度, 这样 v 不能泛化时它们也不会被泛化
+func (in *inferer) bind(v *Var, t Type) bool {
+	if in.occurs(v, t) || !in.constrain(t, v.class) {
+		return false
+	}
+	in.trail = append(in.trail, change{v: v, level: v.level, class: v.class})
+	v.instance = t
+	return true
+}
+
+// constrain 给类型加上约束: 变量记下约束, 以后绑定时检查; 已知的类型直接检查
+func (in *inferer) constrain(t Type, c constraint) bool {
+	v, ok := prune(t).(*Var)
+	if !ok {
+		return c.satisfies(prune(t))
+	}
+	if merged := v.class.merge(c); merged != v.class {
+		in.trail = append(in.trail, change{v: v, level: v.level, class: v.class})
+		v.class = merged
+	}
+	return true
+}
+
+func (in *inferer) occurs(v *Var, t Type) bool {
+	switch t := prune(t).(type) {
+	case *Var:
+		if t == v {
+			return true
+		}
+		if t.level > v.level {
+			in.trail = append(in.trail, change{v: t, level: t.level, class: t.class})
+			t.level = v.level
+		}
+	case *Array:
+		return in.occurs(v, t.Element)
+	case *Hash:
+		return in.occurs(v, t.Key) || in.occurs(v, t.Value)
+	case *Function:
+		for _, p := range t.Params {
+			if in.occurs(v, p) {
+				return true
+			}
+		}
+		return t.Rest != nil && in.occurs(v, t.Rest) || in.occurs(v, t.Return)
+	}
+	return false
+}
+
+// attempt 尝试合一, 失败时撤销这次合一做出的所有绑定
+func (in *inferer) attempt(a, b Type) bool {
+	mark := len(in.trail)
+	if in.unify(a, b) {
+		return true
+	}
+	for len(in.trail) > mark {
+		last := in.trail[len(in.trail)-1]
+		last.v.level, last.v.class, last.v.instance = last.level, last.class, nil
+		in.trail = in.trail[:len(in.trail)-1]
+	}
+	return false
+}
+
+// join 合并两个可能不同的类型, 不能合一时为 any
+func (in *inferer) join(a, b Type) Type {
+	if a == never {
+		return b
+	}
+	if in.attempt(a, b) {
+		return a
+	}
+	return Any
+}
+
+// expect 把表达式 e 的类型 got 与需要的类型 want 合一, 失败时报告 context 描述的位置
+func (in *inferer) expect(e ast.Node, want, got Type, context string) {
+	if !in.attempt(want, got) {
+		n := namer{}
+		in.errorf(e, "cannot use %s as %s in %s%s", n.apply(got), n.apply(want), context, n.where(got, want))
+	}
+}
+
+// generalize 对比当前 let 更深的类型变量全称量化
+func (in *inferer) generalize(t Type) *Scheme {
+	var vars []*Var
+	for _, v := range freeVars(t, nil) {
+		if v.level > in.level {
+			vars = append(vars, v)
+		}
+	}
+	return &Scheme{Vars: vars, Type: t}
+}
+
+// instantiate 把类型方案中量化的变量换成新的类型变量
+func (in *inferer) instantiate(s *Scheme) Type {
+	if len(s.Vars) == 0 {
+		return s.Type
+	}
+	fresh := make(map[*Var]Type, len(s.Vars))
+	for _, v := range s.Vars {
+		instance := in.fresh()
+		instance.class = v.class
+		fresh[v] = instance
+	}
+	return substitute(s.Type, fresh)
+}
+
+// substitute 按 m 替换类型中的类型变量, 返回新的类型
+func substitute(t Type, m map[*Var]Type) Type {
+	switch t := prune(t).(type) {
+	case *Var:
+		if r, ok := m[t]; ok {
+			return r
+		}
+		return t
+	case *Array:
+		return &Array{Element: substitute(t.Element, m)}
+	case *Hash:
+		return &Hash{Key: substitute(t.Key, m), Value: substitute(t.Value, m)}
+	case *Function:
+		fn := &Function{Required: t.Required, Return: substitute(t.Return, m)}
+		for _, p := range t.Params {
+			fn.Params = append(fn.Params, substitute(p, m))
+		}
+		if t.Rest != nil {
+			fn.Rest = substitute(t.Rest, m)
+		}
+		return fn
+	default:
+		return t
+	}
+}
+
+// freeVars 按出现的顺序把类型中没有绑定的类型变量加到 vars 中
+func freeVars(t Type, vars []*Var) []*Var {
+	switch t := prune(t).(type) {
+	case *Var:
+		for _, v := range vars {
+			if v == t {
+				return vars
+			}
+		}
+		return append(vars, t)
+	case *Array:
+		return freeVars(t.Element, vars)
+	case *Hash:
+		return freeVars(t.Value, freeVars(t.Key, vars))
+	case *Function:
+		for _, p := range t.Params {
+			vars = freeVars(p, vars)
+		}
+		if t.Rest != nil {
+			vars = freeVars(t.Rest, vars)
+		}
+		return freeVars(t.Return, vars)
+	}
+	return vars
+}
+
+// namer 按出现的顺序把类型变量命名为 'a, 'b, ..., 同一条消息中的类型共用一个 namer
+type namer map[*Var]Type
+
+// apply 返回把类型变量换成名字后的类型, 用于打印
+func (n namer) apply(t Type) Type {
+	for _, v := range freeVars(t, nil) {
+		if _, ok := n[v]; !ok {
+			name := string(rune('a' + len(n)%26))
+			if len(n) >= 26 {
+				name += strconv.Itoa(len(n) / 26)
+			}
+			n[v] = Basic("'" + name)
+		}
+	}
+	return substitute(t, n)
+}
+
+// where 列出已经命名的类型变量中带约束的变量, 例如 " where 'a: number", 没有时为空
+func (n namer) where(ts ...Type) string {
+	var vars []*Var
+	for _, t := range ts {
+		vars = freeVars(t, vars)
+	}
+	var clauses []string
+	for _, v := range vars {
+		if v.class != unconstrained {
+			clauses = append(clauses, n[v].String()+": "+string(v.class))
+		}
+	}
+	if len(clauses) == 0 {
+		return ""
+	}
+	return " where " + strings.Join(clauses, ", ")
+}
+
+// statements 推断一组语句, 返回最后一条语句的类型; return 或 throw 之后的语句执行不到, 不影响结果
+func (in *inferer) statements(stmts []ast.Statement) Type {
+	var result Type = Null
+	for _, stmt := range stmts {
+		if t := in.statement(stmt); result != never {
+			result = t
+		}
+	}
+	return result
+}
+
+func (in *inferer) statement(stmt ast.Statement) Type {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		in.let(stmt)
+	case *ast.ExportStatement:
+		if stmt.Statement != nil {
+			in.let(stmt.Statement)
+		}
+	case *ast.ReturnStatement:
+		var t Type = Null
+		var node ast.Node = stmt
+		if stmt.ReturnValue != nil {
+			t, node = in.expression(stmt.ReturnValue), stmt.ReturnValue
+		}
+		if in.fn != nil {
+			in.expect(node, in.fn.result, t, "return value of "+in.fn.name)
+		}
+		return never
+	case *ast.ThrowStatement:
+		in.expression(stmt.Value)
+		return never
+	case *ast.ExpressionStatement:
+		return in.expression(stmt.Expression)
+	}
+	return Null
+}
+
+// let 推断 let 的值, 值是函数字面量时把名字的类型泛化为类型方案
+// 名字在推断函数体之前先绑定到一个类型变量, 递归调用会与函数的类型合一
+func (in *inferer) let(stmt *ast.LetStatement) {
+	fl, isFn := stmt.Value.(*ast.FunctionLiteral)
+	in.level++
+	var t Type
+	if isFn && stmt.Name != nil {
+		self := in.fresh()
+		in.bindPattern(stmt.Name, self, true)
+		t = in.expression(fl)
+		in.expect(fl, self, t, "recursive use of "+stmt.Name.Value)
+	} else {
+		t = in.expression(stmt.Value)
+	}
+	if stmt.Type != nil {
+		in.expect(stmt.Value, in.annotation(stmt.Type), t, "let "+stmt.Target().String())
+	}
+	in.level--
+	if isFn && stmt.Name != nil {
+		if b := in.analysis.BindingOf(stmt.Name); b != nil {
+			in.schemes[b] = in.generalize(t)
+		}
+		return
+	}
+	// 不泛化的类型中的变量属于外层, 之后的 let 也不能泛化它们
+	for _, v := range freeVars(t, nil) {
+		if v.level > in.level {
+			v.level = in.level
+		}
+	}
+	if !in.bindPattern(stmt.Target(), t, true) {
+		in.errorf(stmt.Value, "cannot destructure %s with %s", namer{}.apply(t), stmt.Target())
+	}
+}
+
+// bindPattern 按模式的结构把类型绑定到模式中的名字, 类型与模式的结构不符时返回 false, 不符的部分的名字为 any
+// refine 为 true 时, 还不知道结构的类型变量会被细化成数组或哈希; match 的分支不一定匹配, 所以不细化
+func (in *inferer) bindPattern(pattern ast.Pattern, t Type, refine bool) bool {
+	t = prune(t)
+	if t == never {
+		t = Any
+	}
+	ok := true
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if b := in.analysis.BindingOf(pattern); b != nil {
+			in.schemes[b] = &Scheme{Type: t}
+		}
+	case *ast.ArrayPattern:
+		var element Type = Any
+		switch t := t.(type) {
+		case *Array:
+			element = t.Element
+		case *Var:
+			if refine {
+				element = in.fresh()
+				in.unify(t, &Array{Element: element})
+			}
+		default:
+			ok = t == Any
+		}
+		for _, el := range pattern.Elements {
+			if rest, isRest := el.(*ast.RestPattern); isRest {
+				in.bindPattern(rest.Name, &Array{Element: element}, refine)
+				continue
+			}
+			ok = in.bindPattern(el, element, refine) && ok
+		}
+	case *ast.HashPattern:
+		var value Type = Any
+		switch h := t.(type) {
+		case *Hash:
+			value = h.Value
+		case *Var:
+			if refine {
+				value = in.fresh()
+				in.unify(h, &Hash{Key: String, Value: value})
+			}
+		default:
+			ok = t == Any
+		}
+		for _, pair := range pattern.Pairs {
+			ok = in.bindPattern(pair.Value, value, refine) && ok
+		}
+		if pattern.Rest != nil {
+			in.bindPattern(pattern.Rest.Name, t, refine)
+		}
+	case *ast.RestPattern:
+		return in.bindPattern(pattern.Name, t, refine)
+	case *ast.DefaultPattern:
+		in.expect(pattern.Default, t, in.expression(pattern.Default), "default value of "+pattern.Target.String())
+		return in.bindPattern(pattern.Target, t, refine)
+	case *ast.TypedPattern:
+		return in.bindPattern(pattern.Target, t, refine)
+	}
+	return ok
+}
+
+// function 推断函数字面量的类型: 每个参数是一个新的类型变量, 有注解时与注解合一
+func (in *inferer) function(fl *ast.FunctionLiteral) Type {
+	fn := &Function{}
+	for _, param := range fl.Parameters {
+		var def ast.Expression
+		if d, ok := param.(*ast.DefaultPattern); ok {
+			param, def = d.Target, d.Default
+		}
+		var declared Type
+		if typed, ok := param.(*ast.TypedPattern); ok {
+			param, declared = typed.Target, in.annotation(typed.Type)
+		}
+		var t Type
+		if _, isRest := param.(*ast.RestPattern); isRest {
+			fn.Rest = in.fresh()
+			t = &Array{Element: fn.Rest}
+			if declared != nil && !in.attempt(declared, t) {
+				in.errorf(param, "rest parameter %s must have an array type, got %s", param, declared)
+			}
+		} else {
+			t = in.fresh()
+			if declared != nil {
+				in.unify(t, declared)
+			}
+			fn.Params = append(fn.Params, t)
+			if def == nil {
+				fn.Required = len(fn.Params)
+			}
+		}
+		if def != nil {
+			in.expect(def, t, in.expression(def), "default value of parameter "+param.String())
+		}
+		in.bindPattern(param, t, true)
+	}
+
+	outer := in.fn
+	name := fl.Name
+	if name == "" {
+		name = "anonymous function"
+	}
+	in.fn = &frame{name: name, result: in.fresh()}
+	if fl.ReturnType != nil {
+		in.fn.result = in.annotation(fl.ReturnType)
+	}
+	// 最后一条语句的值也是函数的结果, 最后是 let 或者函数体为空时结果是 null
+	result := in.statements(fl.Body.Statements)
+	var last ast.Node = fl.Body
+	if n := len(fl.Body.Statements); n > 0 {
+		if es, ok := fl.Body.Statements[n-1].(*ast.ExpressionStatement); ok {
+			last = es.Expression
+		}
+	}
+	in.expect(last, in.fn.result, result, "return value of "+name)
+	fn.Return = in.fn.result
+	in.fn = outer
+	return fn
+}
+
+func (in *inferer) expression(e ast.Expression) Type {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.FloatLiteral:
+		return Float
+	case *ast.StringLiteral:
+		return String
+	case *ast.Boolean:
+		return Bool
+	case *ast.RegexLiteral:
+		return Regex
+	case *ast.Identifier:
+		if b := in.analysis.BindingOf(e); b != nil {
+			if s, ok := in.schemes[b]; ok {
+				return in.instantiate(s)
+			}
+			return Any
+		}
+		if s, ok := schemes[e.Value]; ok {
+			return in.instantiate(s)
+		}
+		return Any
+	case *ast.PrefixExpression:
+		right := in.expression(e.Right)
+		if e.Operator == "!" {
+			return Bool
+		}
+		if in.constrain(right, numeric) {
+			return right
+		}
+		in.errorf(e, "unknown operator: %s%s", e.Operator, namer{}.apply(right))
+		return Any
+	case *ast.InfixExpression:
+		return in.infix(e)
+	case *ast.IfExpression:
+		in.expression(e.Condition)
+		t := in.block(e.Consequence)
+		if e.Alternative == nil {
+			// 条件不成立时结果是 null, 只有两个分支都可能是 null 时才知道结果的类型
+			if t = prune(t); t == Null || t == never {
+				return Null
+			}
+			return Any
+		}
+		alt := in.block(e.Alternative)
+		if t == never {
+			return alt
+		}
+		in.expect(e.Alternative, t, alt, "else branch")
+		return t
+	case *ast.FunctionLiteral:
+		return in.function(e)
+	case *ast.CallExpression:
+		return in.call(e)
+	case *ast.NamedArgument:
+		return in.expression(e.Value)
+	case *ast.ArrayLiteral:
+		var element Type = never
+		for _, el := range e.Elements {
+			element = in.join(element, in.expression(el))
+		}
+		if element == never {
+			element = in.fresh()
+		}
+		return &Array{Element: element}
+	case *ast.HashLiteral:
+		var key, value Type = never, never
+		for _, k := range e.OrderedKeys() {
+			key = in.join(key, in.expression(k))
+			value = in.join(value, in.expression(e.Pairs[k]))
+		}
+		if key == never {
+			key, value = in.fresh(), in.fresh()
+		}
+		return &Hash{Key: key, Value: value}
+	case *ast.IndexExpression:
+		return in.index(e)
+	case *ast.SliceExpression:
+		left := in.expression(e.Left)
+		for _, bound := range []ast.Expression{e.Start, e.End, e.Step} {
+			if bound != nil {
+				if t := in.expression(bound); !in.attempt(Int, t) {
+					in.errorf(bound, "slice index must be int, got %s", namer{}.apply(t))
+				}
+			}
+		}
+		if prune(left) == String {
+			return String
+		}
+		if !in.attempt(left, &Array{Element: in.fresh()}) {
+			in.errorf(e, "cannot slice %s", namer{}.apply(left))
+			return Any
+		}
+		return left
+	case *ast.MemberExpression:
+		// 其他值的成员只有运行时才知道, 还不知道类型的值当作以字符串为键的哈希
+		switch object := prune(in.expression(e.Object)).(type) {
+		case *Hash:
+			return object.Value
+		case *Var:
+			value := in.fresh()
+			in.unify(object, &Hash{Key: String, Value: value})
+			return value
+		}
+		return Any
+	case *ast.MatchExpression:
+		subject := in.expression(e.Subject)
+		var t Type = never
+		for _, arm := range e.Arms {
+			in.bindPattern(arm.Pattern, subject, false)
+			if arm.Guard != nil {
+				in.expression(arm.Guard)
+			}
+			body := in.expression(arm.Body)
+			if t == never {
+				t = body
+				continue
+			}
+			in.expect(arm.Body, t, body, "match arm")
+		}
+		if t == never {
+			return Null
+		}
+		return t
+	case *ast.TryExpression:
+		t := in.block(e.Block)
+		if e.Param != nil {
+			in.bindPattern(e.Param, &Hash{Key: String, Value: Any}, false)
+		}
+		if e.Catch != nil {
+			catch := in.block(e.Catch)
+			if t == never {
+				t = catch
+			} else {
+				in.expect(e.Catch, t, catch, "catch block")
+			}
+		}
+		if e.Finally != nil {
+			in.block(e.Finally)
+		}
+		return t
+	}
+	return Any
+}
+
+func isVar(t Type) bool {
+	_, ok := prune(t).(*Var)
+	return ok
+}
+
+func (in *inferer) block(block *ast.BlockStatement) Type {
+	if block == nil {
+		return Null
+	}
+	return in.statements(block.Statements)
+}
+
+// infix 推断中缀表达式: 两边的类型要能合一, 结果按求值器的规则取决于运算符
+// 还不知道类型的操作数加上运算符的约束, 所以 fn(a, b) { a + b } 的类型是 fn('a, 'a) -> 'a where 'a: number | string
+func (in *inferer) infix(e *ast.InfixExpression) Type {
+	left, right := in.expression(e.Left), in.expression(e.Right)
+	if e.Operator == "==" || e.Operator == "!=" {
+		return Bool
+	}
+	if !in.attempt(left, right) {
+		n := namer{}
+		in.errorf(e, "type mismatch: %s %s %s", n.apply(left), e.Operator, n.apply(right))
+		return Any
+	}
+	t := prune(left)
+	if prune(right) == Float {
+		t = Float
+	}
+	c := numeric
+	if e.Operator == "+" {
+		c = addable
+	}
+	if in.constrain(t, c) {
+		if e.Operator == "<" || e.Operator == ">" {
+			return Bool
+		}
+		return t
+	}
+	n := namer{}
+	in.errorf(e, "unknown operator: %s %s %s", n.apply(left), e.Operator, n.apply(right))
+	return Any
+}
+
+func (in *inferer) call(e *ast.CallExpression) Type {
+	// obj.f(args) 在运行时才知道调用的是成员还是 f(obj, args)
+	if member, ok := e.Function.(*ast.MemberExpression); ok {
+		in.expression(member.Object)
+		for _, arg := range e.Arguments {
+			in.expression(arg)
+		}
+		return Any
+	}
+	callee := in.expression(e.Function)
+	args := make([]Type, len(e.Arguments))
+	named := false
+	for i, arg := range e.Arguments {
+		args[i] = in.expression(arg)
+		if _, ok := arg.(*ast.NamedArgument); ok {
+			named = true
+		}
+	}
+
+	switch fn := prune(callee).(type) {
+	case *Var:
+		// 还不知道类型的函数按这次调用的参数确定类型
+		result := in.fresh()
+		in.expect(e.Function, &Function{Params: args, Required: len(args), Return: result}, fn, "call")
+		return result
+	case *Function:
+		// 具名参数按名字对应到参数, 这里只推断全部是位置参数的调用
+		if named {
+			return fn.Return
+		}
+		name := callName(e.Function)
+		switch {
+		case len(args) < fn.Required:
+			in.errorf(e, "%s: not enough arguments, want at least %d, got %d", name, fn.Required, len(args))
+		case !fn.accepts(len(args)):
+			in.errorf(e, "%s: too many arguments, want at most %d, got %d", name, len(fn.Params), len(args))
+		default:
+			for i, arg := range e.Arguments {
+				in.expect(arg, fn.param(i), args[i], fmt.Sprintf("argument %d to %s", i+1, name))
+			}
+		}
+		return fn.Return
+	default:
+		if fn != Any {
+			in.errorf(e.Function, "not a function: %s", namer{}.apply(fn))
+		}
+		return Any
+	}
+}
+
+// index 推断下标表达式, 还不知道类型的值用字符串取下标时当作哈希, 否则当作数组
+func (in *inferer) index(e *ast.IndexExpression) Type {
+	left, index := in.expression(e.Left), in.expression(e.Index)
+	var want, element Type
+	switch l := prune(left).(type) {
+	case *Array:
+		want, element = Int, l.Element
+	case *Hash:
+		want, element = l.Key, l.Value
+	case *Var:
+		element = in.fresh()
+		if prune(index) == String {
+			in.unify(l, &Hash{Key: String, Value: element})
+			return element
+		}
+		in.unify(l, &Array{Element: element})
+		want = Int
+	default:
+		switch {
+		case l == String:
+			want, element = Int, String
+		case l == Any:
+			return Any
+		default:
+			in.errorf(e, "index operation not supported: %s", namer{}.apply(l))
+			return Any
+		}
+	}
+	if !in.attempt(want, index) {
+		n := namer{}
+		in.errorf(e.Index, "cannot index %s with %s", n.apply(left), n.apply(index))
+	}
+	return element
+}
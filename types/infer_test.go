@@ -0,0 +1,108 @@
+package types
+
+import (
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/parser"
+	"strings"
+	"testing"
+)
+
+func TestInfer(t *testing.T) {
+	tests := []struct {
+		input       string
+		signatures  []string
+		diagnostics []string
+	}{
+		{"let id = fn(x) { x }; let a = id(1) + 1; let b = id(\"s\") + \"t\";", []string{"id: fn('a) -> 'a"}, nil},
+		{"let add = fn(a, b) { a + b }; add(1, 2.5); add(\"a\", \"b\"); add(1, \"b\")",
+			[]string{"add: fn('a, 'a) -> 'a where 'a: number | string"},
+			[]string{"1:66: error: cannot use string as int in argument 2 to add"}},
+		// 运算符的约束跟着类型方案实例化, 每次调用都要满足
+		{"let sub = fn(a, b) { a - b }; sub(1, 2.5); sub(\"x\", \"y\")",
+			[]string{"sub: fn('a, 'a) -> 'a where 'a: number"},
+			[]string{
+				"1:48: error: cannot use string as 'a in argument 1 to sub where 'a: number",
+				"1:53: error: cannot use string as 'a in argument 2 to sub where 'a: number",
+			}},
+		{"let add = fn(a, b) { a + b }; add(fn(x) { x }, fn(y) { y }); add([1], [2])",
+			[]string{"add: fn('a, 'a) -> 'a where 'a: number | string"},
+			[]string{
+				"1:35: error: cannot use fn('a) -> 'a as 'b in argument 1 to add where 'b: number | string",
+				"1:48: error: cannot use fn('a) -> 'a as 'b in argument 2 to add where 'b: number | string",
+				"1:66: error: cannot use [int] as 'a in argument 1 to add where 'a: number | string",
+				"1:71: error: cannot use [int] as 'a in argument 2 to add where 'a: number | string",
+			}},
+		{"let f = fn(a, b) { if (a < b) { a + b } else { b } }; let g = fn(x, y) { let s = x + y; -s }; g(\"a\", 1)",
+			[]string{"f: fn('a, 'a) -> 'a where 'a: number", "g: fn('a, 'a) -> 'a where 'a: number"},
+			[]string{"1:97: error: cannot use string as 'a in argument 1 to g where 'a: number"}},
+		{"let compose = fn(f, g) { fn(x) { f(g(x)) } };", []string{"compose: fn(fn('a) -> 'b, fn('c) -> 'a) -> fn('c) -> 'b"}, nil},
+		{"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(\"x\")",
+			[]string{"fact: fn(int) -> int"},
+			[]string{"1:70: error: cannot use string as int in argument 1 to fact"}},
+		{"let nth = fn(xs, i) { xs[i] }; let name = fn(p) { p.name }; let get = fn(h) { h[\"k\"] };",
+			[]string{"nth: fn(['a], int) -> 'a", "name: fn({string: 'a}) -> 'a", "get: fn({string: 'a}) -> 'a"}, nil},
+		{"let second = fn(xs) { first(rest(xs)) }; let more = fn(xs) { push(xs, 1) }; second([\"a\"]) - 1",
+			[]string{"second: fn(['a]) -> 'a", "more: fn([int]) -> [int]"},
+			[]string{"1:77: error: type mismatch: string - int"}},
+		{"let f = fn(a, b = 1, ...r) { a + b }; export let g = fn([x, y], {k}) { x + y + k };",
+			[]string{"f: fn(int, int, ...'a) -> int", "g: fn(['a], {string: 'a}) -> 'a where 'a: number | string"}, nil},
+		{"let f = fn(a: string, b: [int]) -> bool { len(a) > b[0] };", []string{"f: fn(string, [int]) -> bool"}, nil},
+		{"let f = fn(x) { let g = fn(y) { [x, y] }; g(1) };", []string{"f: fn(int) -> [int]"}, nil},
+		// 不泛化的 let 中的类型变量被所有使用共享
+		{"let xs = []; let f = fn() { push(xs, 1) }; push(xs, \"a\")",
+			[]string{"f: fn() -> [int]"},
+			[]string{"1:53: error: cannot use string as int in argument 2 to push"}},
+		{"let f = fn(x) { x - \"a\" }; let g = fn(x) { -x }; -\"a\"; !\"a\"",
+			[]string{"f: fn(string) -> any", "g: fn('a) -> 'a where 'a: number"},
+			[]string{"1:17: error: unknown operator: string - string", "1:50: error: unknown operator: -string"}},
+		{"let self = fn(f) { f(f) };", []string{"self: fn('a) -> 'b"}, []string{"1:20: error: cannot use 'a as fn('a) -> 'b in call"}},
+		{"let f = fn(n) { if (n) { return 1 } \"a\" }; let g = fn(n) { if (n) { 1 } else { throw \"x\" } };",
+			[]string{"f: fn('a) -> int", "g: fn('a) -> int"},
+			[]string{"1:37: error: cannot use string as int in return value of f"}},
+		{"if (true) { 1 } else { \"a\" }; match (1) { 0 => true, n => n }; try { 1 } catch (e) { \"x\" }", nil, []string{
+			"1:22: error: cannot use string as int in else branch",
+			"1:59: error: cannot use int as bool in match arm",
+			"1:84: error: cannot use string as int in catch block",
+		}},
+		{"let data = {\"name\": \"x\", \"tags\": [1, \"a\"]}; data.name + 1; let {name} = data; len(name)", nil, nil},
+		{"let [a, ...r] = [1, 2]; a + r; let [b] = 5;", nil, []string{
+			"1:25: error: type mismatch: int + [int]",
+			"1:42: error: cannot destructure int with [b]",
+		}},
+		{"let x: int = \"a\"; let xs = [1, 2]; xs[\"a\"]; xs[1:\"b\"]; 1[0]; 2(3); len(1, 2)", nil, []string{
+			"1:14: error: cannot use string as int in let x",
+			"1:39: error: cannot index [int] with string",
+			"1:50: error: slice index must be int, got string",
+			"1:56: error: index operation not supported: int",
+			"1:62: error: not a function: int",
+			"1:68: error: len: too many arguments, want at most 1, got 2",
+		}},
+		{"max(); min(1, 2.5)", nil, []string{"1:1: error: max: not enough arguments, want at least 1, got 0"}},
+		{"let apply = fn(f, x) { f(x) }; apply(fn(a, b = 2) { a + b }, 1); apply(fn(a, b) { a }, 1)",
+			[]string{"apply: fn(fn('a) -> 'b, 'a) -> 'b"},
+			[]string{"1:72: error: cannot use fn('a, 'b) -> 'a as fn('c) -> 'd in argument 1 to apply"}},
+		{"import \"m.mk\" as m; m.f(1) + m.x; json_parse(\"1\") + 1; puts(1, \"a\")", nil, nil},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+		signatures, diagnostics := Infer(program)
+		var gotSignatures, gotDiagnostics []string
+		for _, s := range signatures {
+			gotSignatures = append(gotSignatures, s.String())
+		}
+		for _, d := range diagnostics {
+			gotDiagnostics = append(gotDiagnostics, d.String())
+		}
+		if strings.Join(gotSignatures, "\n") != strings.Join(tt.signatures, "\n") {
+			t.Errorf("wrong signatures for %q.\nexpected=%q\ngot=%q", tt.input, tt.signatures, gotSignatures)
+		}
+		if strings.Join(gotDiagnostics, "\n") != strings.Join(tt.diagnostics, "\n") {
+			t.Errorf("wrong diagnostics for %q.\nexpected=%q\ngot=%q", tt.input, tt.diagnostics, gotDiagnostics)
+		}
+	}
+}
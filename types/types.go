@@ -0,0 +1,172 @@
+// Package types 在运行之前检查程序中的类型错误, 例如 "a" - 1
+//
+// 类型是渐进的: 没有注解、也推断不出类型的值的类型是 any, any 与任何类型兼容,
+// 所以没有注解的程序照常运行, 注解越多能发现的错误越多
+package types
+
+import "strings"
+
+// Type 值的静态类型
+type Type interface {
+	String() string
+}
+
+// Basic 基本类型和 any
+type Basic string
+
+const (
+	Int    Basic = "int"
+	Float  Basic = "float"
+	String Basic = "string"
+	Bool   Basic = "bool"
+	Null   Basic = "null"
+	Regex  Basic = "regex"
+	Any    Basic = "any" // 动态类型, 与任何类型兼容
+)
+
+func (b Basic) String() string { return string(b) }
+
+// basics 可以在注解中使用的基本类型
+var basics = map[string]Basic{
+	"int": Int, "float": Float, "string": String, "bool": Bool, "null": Null, "regex": Regex, "any": Any,
+}
+
+// Array 元素类型相同的数组
+type Array struct {
+	Element Type
+}
+
+func (a *Array) String() string { return "[" + a.Element.String() + "]" }
+
+// Hash 键和值的类型各自相同的哈希
+type Hash struct {
+	Key   Type
+	Value Type
+}
+
+func (h *Hash) String() string { return "{" + h.Key.String() + ": " + h.Value.String() + "}" }
+
+// Function 函数类型
+type Function struct {
+	Params   []Type
+	Required int  // 必须传入的参数个数, 之后的参数有默认值
+	Rest     Type // 剩余参数中每个元素的类型, 没有剩余参数时为 nil
+	Return   Type
+}
+
+func (f *Function) String() string {
+	params := []string{}
+	for _, p := range f.Params {
+		params = append(params, p.String())
+	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
+	}
+	return "fn(" + strings.Join(params, ", ") + ") -> " + f.Return.String()
+}
+
+// Identical 判断两个类型是否相同
+func Identical(a, b Type) bool {
+	switch a := a.(type) {
+	case Basic:
+		return a == b
+	case *Array:
+		b, ok := b.(*Array)
+		return ok && Identical(a.Element, b.Element)
+	case *Hash:
+		b, ok := b.(*Hash)
+		return ok && Identical(a.Key, b.Key) && Identical(a.Value, b.Value)
+	case *Function:
+		b, ok := b.(*Function)
+		if !ok || len(a.Params) != len(b.Params) || a.Required != b.Required || (a.Rest == nil) != (b.Rest == nil) {
+			return false
+		}
+		for i := range a.Params {
+			if !Identical(a.Params[i], b.Params[i]) {
+				return false
+			}
+		}
+		return (a.Rest == nil || Identical(a.Rest, b.Rest)) && Identical(a.Return, b.Return)
+	}
+	return false
+}
+
+// Assignable 判断 from 类型的值能否用在需要 to 类型的地方
+// any 与任何类型兼容, 整数可以用在 float 的位置, 数组和哈希按元素类型比较
+func Assignable(to, from Type) bool {
+	if to == Any || from == Any || from == never {
+		return true
+	}
+	switch to := to.(type) {
+	case Basic:
+		return to == from || to == Float && from == Int
+	case *Array:
+		from, ok := from.(*Array)
+		return ok && Assignable(to.Element, from.Element)
+	case *Hash:
+		from, ok := from.(*Hash)
+		return ok && Assignable(to.Key, from.Key) && Assignable(to.Value, from.Value)
+	case *Function:
+		from, ok := from.(*Function)
+		if !ok || !from.accepts(len(to.Params)) {
+			return false
+		}
+		// 参数反过来比较: from 要能接收 to 的调用者传入的参数
+		for i, p := range to.Params {
+			if !Assignable(from.param(i), p) {
+				return false
+			}
+		}
+		return Assignable(to.Return, from.Return)
+	}
+	return false
+}
+
+// accepts 判断函数能否用 n 个位置参数调用
+func (f *Function) accepts(n int) bool {
+	return n >= f.Required && (n <= len(f.Params) || f.Rest != nil)
+}
+
+// param 第 i 个位置参数的类型, 超出参数列表的由剩余参数接收
+func (f *Function) param(i int) Type {
+	if i < len(f.Params) {
+		return f.Params[i]
+	}
+	if f.Rest != nil {
+		return f.Rest
+	}
+	return Any
+}
+
+// Join 合并两个分支的类型: 相同时不变, 整数和浮点数合并为 float, 数组和哈希按元素合并, 其余为 any
+func Join(a, b Type) Type {
+	if a == never {
+		return b
+	}
+	if b == never || Identical(a, b) {
+		return a
+	}
+	if isNumeric(a) && isNumeric(b) {
+		return Float
+	}
+	switch a := a.(type) {
+	case *Array:
+		if b, ok := b.(*Array); ok {
+			return &Array{Element: Join(a.Element, b.Element)}
+		}
+	case *Hash:
+		if b, ok := b.(*Hash); ok {
+			return &Hash{Key: Join(a.Key, b.Key), Value: Join(a.Value, b.Value)}
+		}
+	}
+	return Any
+}
+
+func isNumeric(t Type) bool {
+	return t == Int || t == Float
+}
+
+// hashable 判断类型的值能否作为哈希的键
+func hashable(t Type) bool {
+	return t == Int || t == String || t == Bool || t == Any
+}
@@ -0,0 +1,142 @@
+package types
+
+import (
+	"github.com/fanyeke/monkey/evaluator"
+	"github.com/fanyeke/monkey/lexer"
+	"github.com/fanyeke/monkey/parser"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// 没有注解的代码照常通过检查
+		{"let f = fn(a, b) { a - b }; f(\"x\", [1]); let h = {}; h[1] + h.x", nil},
+		{`"a" - 1`, []string{"1:1: error: type mismatch: string - int"}},
+		{`"a" * "b"; !"a"; -true`, []string{"1:1: error: unknown operator: string * string", "1:18: error: unknown operator: -bool"}},
+		{`1 + 2.5 == 3; "a" + "b" == [1]; true != 1`, nil},
+		{"let x: int = 1.5;", []string{"1:14: error: cannot use float as int in let x"}},
+		{"let x: float = 1; let y: [float] = [1, 2.5]; let z: {string: any} = {\"a\": 1, \"b\": true};", nil},
+		{"let h: {string: [int]} = {\"a\": [1], \"b\": [2, true]};", []string{"1:46: error: cannot use bool as int in let h"}},
+		{"let x: [int] = [1, \"a\"];", []string{"1:20: error: cannot use string as int in let x"}},
+		{"let x: int = 1; x + \"a\"", []string{"1:17: error: type mismatch: int + string"}},
+		{
+			"let add = fn(a: int, b: int = 1) -> int { a + b }; add(\"1\"); add(1, 2, 3); add(); add(b: \"x\")",
+			[]string{
+				"1:56: error: cannot use string as int in argument 1 to add",
+				"1:62: error: add: too many arguments, want at most 2, got 3",
+				"1:76: error: add: not enough arguments, want at least 1, got 0",
+			},
+		},
+		{"let f = fn() -> string { 1 };", []string{"1:26: error: cannot use int as string in return value of f"}},
+		{"let f = fn(n) -> int { if (n) { return \"a\" } 1 };", []string{"1:40: error: cannot use string as int in return value of f"}},
+		{"let f = fn() -> int { let x = 1; };", []string{"1:21: error: cannot use null as int in return value of f"}},
+		{"let f = fn(n) -> int { if (n) { return 1 } else { throw \"x\" } };", nil},
+		{"let f = fn(n) { if (n) { return \"a\" } \"b\" }; f(1) - 1", []string{"1:46: error: type mismatch: string - int"}},
+		{"let fact = fn(n: int) -> int { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(true)", []string{"1:82: error: cannot use bool as int in argument 1 to fact"}},
+		{"let f = fn(...xs: [int]) { xs }; f(1, \"a\")[0] + 1", []string{"1:39: error: cannot use string as int in argument 2 to f"}},
+		{"let f = fn(...xs: int) { xs };", []string{"1:12: error: rest parameter ...xs must have an array type, got int"}},
+		{"let f = fn(g: fn(int) -> int) { g(1) }; f(fn(s: string) { s }); f(fn(n: int) -> int { n })", []string{"1:43: error: cannot use fn(string) -> string as fn(int) -> int in argument 1 to f"}},
+		{"let x: foo = 1; let y: {[int]: int} = {};", []string{"1:8: error: unknown type foo", "1:25: error: unusable as hash key: [int]"}},
+		{"let xs = [1, 2]; xs[\"a\"]; let h = {\"a\": 1}; h[1]; 1[0]; {[1]: 2}", []string{
+			"1:21: error: cannot index [int] with string",
+			"1:47: error: cannot index {string: int} with int",
+			"1:51: error: index operation not supported: int",
+			"1:58: error: unusable as hash key: [int]",
+		}},
+		{"let h = {\"n\": 1}; h.n + \"a\"; h[\"n\"] - 1", []string{"1:19: error: type mismatch: int + string"}},
+		{"len(1); sqrt(\"4\"); puts(1, \"a\"); 1(2)", []string{
+			"1:5: error: cannot use int as string in argument 1 to len",
+			"1:14: error: cannot use string as float in argument 1 to sqrt",
+			"1:34: error: not a function: int",
+		}},
+		{"let len = fn(x) { x }; len([1])", nil},
+		{"let [a, ...r] = [1, 2]; let {k} = {\"k\": \"v\"}; a + r[0]; k - 1", []string{"1:57: error: type mismatch: string - int"}},
+		{"match (1) { 0 => \"zero\", n => n - \"a\" }", []string{"1:31: error: type mismatch: int - string"}},
+		{"try { 1 } catch (e) { e.message - 1 }", nil},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+		var got []string
+		for _, d := range Check(program) {
+			got = append(got, d.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong diagnostics for %q.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestAssignableAndJoin(t *testing.T) {
+	intToInt := &Function{Params: []Type{Int}, Required: 1, Return: Int}
+	tests := []struct {
+		to, from   Type
+		assignable bool
+		join       string
+	}{
+		{Int, Int, true, "int"},
+		{Float, Int, true, "float"},
+		{Int, Float, false, "float"},
+		{Any, String, true, "any"},
+		{String, Any, true, "any"},
+		{String, Bool, false, "any"},
+		{&Array{Element: Float}, &Array{Element: Int}, true, "[float]"},
+		{&Array{Element: Int}, &Array{Element: String}, false, "[any]"},
+		{&Hash{Key: String, Value: Int}, &Hash{Key: String, Value: Any}, true, "{string: any}"},
+		{&Hash{Key: String, Value: Int}, &Array{Element: Int}, false, "any"},
+		{intToInt, &Function{Params: []Type{Float}, Required: 1, Return: Int}, true, "any"},
+		{intToInt, &Function{Params: []Type{Int, Int}, Required: 2, Return: Int}, false, "any"},
+		{intToInt, &Function{Params: []Type{Int, Int}, Required: 1, Return: Any}, true, "any"},
+		{intToInt, &Function{Rest: String, Return: Int}, false, "any"},
+		{intToInt, intToInt, true, "fn(int) -> int"},
+	}
+
+	for _, tt := range tests {
+		if got := Assignable(tt.to, tt.from); got != tt.assignable {
+			t.Errorf("Assignable(%s, %s) = %t, expected %t", tt.to, tt.from, got, tt.assignable)
+		}
+		if got := Join(tt.to, tt.from).String(); got != tt.join {
+			t.Errorf("Join(%s, %s) = %s, expected %s", tt.to, tt.from, got, tt.join)
+		}
+	}
+}
+
+// TestBuiltinArities 每个内置函数都要有类型, 类型接受的参数个数和 evaluator.BuiltinArity 一致
+func TestBuiltinArities(t *testing.T) {
+	check := func(table, name string, f *Function) {
+		min, max, ok := evaluator.BuiltinArity(name)
+		if !ok {
+			t.Errorf("%s has an entry for unknown builtin %s", table, name)
+			return
+		}
+		gotMax := len(f.Params)
+		if f.Rest != nil {
+			gotMax = -1
+		}
+		if f.Required != min || gotMax != max {
+			t.Errorf("%s[%s] takes [%d, %d] arguments, want [%d, %d]", table, name, f.Required, gotMax, min, max)
+		}
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		if _, ok := builtins[name]; !ok {
+			t.Errorf("builtin %s has no entry in builtins", name)
+		}
+		if _, ok := schemes[name]; !ok {
+			t.Errorf("builtin %s has no entry in schemes", name)
+		}
+	}
+	for name, f := range builtins {
+		check("builtins", name, f)
+	}
+	for name, scheme := range schemes {
+		check("schemes", name, scheme.Type.(*Function))
+	}
+}